@@ -3,6 +3,7 @@ module badge-service
 go 1.21
 
 require (
+	github.com/boombuler/barcode v1.1.0
 	github.com/chai2010/webp v1.4.0
 	github.com/disintegration/imaging v1.6.2
 	github.com/gofiber/fiber/v2 v2.52.0
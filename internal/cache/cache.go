@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/binary"
 	"fmt"
 	"image"
+	"image/color"
+	"image/jpeg"
 	"io"
 	"net/http"
 	"os"
@@ -189,25 +192,59 @@ func PreloadImages(urls []string) map[string]string {
 
 // ============ DIRECT IMAGE DATA CACHING (RAW BYTES) ============
 
+// DefaultJPEGQuality is the quality resized photos are re-encoded at when the
+// request doesn't set one
+const DefaultJPEGQuality = 90
+
 // ImageRequest represents an image to be loaded with specific dimensions
 type ImageRequest struct {
-	URL    string
-	Width  float64 // in mm
-	Height float64 // in mm
-	DPI    int     // DPI for size calculation
+	URL     string
+	Width   float64 // in mm
+	Height  float64 // in mm
+	DPI     int     // DPI for size calculation
+	Fit     string  // fill, contain, cover or none (see FitFill etc.)
+	FocusX  float64 // focal point, 0-1 from the left
+	FocusY  float64 // focal point, 0-1 from the top
+	Quality int     // JPEG quality (1-100) for resized photos, DefaultJPEGQuality when 0
+}
+
+// quality returns the JPEG quality, defaulting to DefaultJPEGQuality
+func (r ImageRequest) quality() int {
+	if r.Quality < 1 || r.Quality > 100 {
+		return DefaultJPEGQuality
+	}
+	return r.Quality
+}
+
+// Key identifies the processed image: the URL plus everything that changes its
+// pixels or encoding
+func (r ImageRequest) Key() string {
+	hash := md5.Sum([]byte(r.URL))
+	
+	// The focal point only changes the pixels of cropped images
+	focusX, focusY := r.FocusX, r.FocusY
+	if fit := r.fit(); fit == FitFill || fit == FitContain {
+		focusX, focusY = 0, 0
+	}
+	
+	return fmt.Sprintf("%s_%.1f_%.1f_%d_%s_%.2f_%.2f_q%d",
+		hex.EncodeToString(hash[:]), r.Width, r.Height, r.DPI, r.fit(), focusX, focusY, r.quality())
 }
 
-// GetImageDataDirect downloads an image, resizes to exact size, and returns raw PNG bytes
+// GetImageDataDirect downloads an image, fits it to the requested size and returns raw image bytes
 // All processing is done in memory - zero file I/O
-func GetImageDataDirect(url string, widthMM, heightMM float64, dpi int) ([]byte, error) {
+// JPEGs that need no resizing are returned as they are, so the PDF embeds the original DCT data.
+// Resized photos (JPEG and lossy WebP) are re-encoded as JPEG at the request's quality; images
+// with transparency and lossless sources (PNG, GIF) become PNG. See ImageType.
+// SVG documents are returned as they are: the generator draws them as vector paths
+func GetImageDataDirect(req ImageRequest) ([]byte, error) {
+	url := req.URL
 	if url == "" {
 		return nil, fmt.Errorf("empty URL")
 	}
 	
-	// Generate cache key with dimensions for size-specific caching
-	hash := md5.Sum([]byte(url))
-	urlHash := hex.EncodeToString(hash[:])
-	cacheKey := fmt.Sprintf("img_data:%s_%.1f_%.1f_%d", urlHash, widthMM, heightMM, dpi)
+	// Cache key includes dimensions and fit mode for size-specific caching
+	cacheKey := "img_data:" + req.Key()
 	
 	// Check cache first (fast path)
 	if cached, found := imageDataCache.Get(cacheKey); found {
@@ -215,8 +252,8 @@ func GetImageDataDirect(url string, widthMM, heightMM float64, dpi int) ([]byte,
 	}
 	
 	// Calculate exact pixel dimensions
-	pixelWidth := int(widthMM * float64(dpi) / 25.4)
-	pixelHeight := int(heightMM * float64(dpi) / 25.4)
+	pixelWidth := int(req.Width * float64(req.DPI) / 25.4)
+	pixelHeight := int(req.Height * float64(req.DPI) / 25.4)
 	
 	// Download image
 	resp, err := httpClient.Get(url)
@@ -235,6 +272,25 @@ func GetImageDataDirect(url string, widthMM, heightMM float64, dpi int) ([]byte,
 		return nil, fmt.Errorf("failed to read image data: %w", err)
 	}
 	
+	// SVG needs no decoding or resizing
+	if IsSVG(imageData) {
+		imageDataCache.Set(cacheKey, imageData, gocache.DefaultExpiration)
+		return imageData, nil
+	}
+	
+	// A JPEG the fit mode leaves as it is goes into the PDF untouched. CMYK
+	// and RGB JPEGs are re-encoded, as PDF readers don't agree on their colors.
+	if isJPEG(imageData) {
+		config, err := jpeg.DecodeConfig(bytes.NewReader(imageData))
+		if err == nil && (config.ColorModel == color.YCbCrModel || config.ColorModel == color.GrayModel) {
+			bounds := image.Rect(0, 0, config.Width, config.Height)
+			if planFit(bounds, req, pixelWidth, pixelHeight).keeps(bounds) {
+				imageDataCache.Set(cacheKey, imageData, gocache.DefaultExpiration)
+				return imageData, nil
+			}
+		}
+	}
+	
 	// Detect WebP format and use appropriate decoder
 	var img image.Image
 	if isWebP(imageData) {
@@ -251,36 +307,15 @@ func GetImageDataDirect(url string, widthMM, heightMM float64, dpi int) ([]byte,
 		}
 	}
 	
-	// Get original dimensions
-	bounds := img.Bounds()
-	origWidth := bounds.Dx()
-	origHeight := bounds.Dy()
-	
-	// Helper function to calculate absolute difference percentage
-	absDiff := func(a, b int) int {
-		if a > b {
-			return a - b
-		}
-		return b - a
-	}
-	
-	// Only resize if dimensions are significantly different (>10% difference)
-	// Skip resize if dimensions match or are very close
-	widthDiff := absDiff(origWidth, pixelWidth)
-	heightDiff := absDiff(origHeight, pixelHeight)
-	
-	if widthDiff > pixelWidth/10 || heightDiff > pixelHeight/10 {
-		// Use NearestNeighbor for speed (faster than Lanczos)
-		// For better quality, use imaging.Lanczos, but NearestNeighbor is much faster
-		img = imaging.Resize(img, pixelWidth, pixelHeight, imaging.NearestNeighbor)
-	}
+	// Resize/crop according to the fit mode
+	img = fitImage(img, req, pixelWidth, pixelHeight)
 	
 	// Normalize to 8-bit NRGBA (gofpdf requirement)
 	nrgba := imaging.Clone(img)
 	
 	// Get buffer from pool and pre-allocate to avoid reallocations
 	// Estimate: width * height * 4 bytes for RGBA
-	estimatedSize := pixelWidth * pixelHeight * 4
+	estimatedSize := nrgba.Bounds().Dx() * nrgba.Bounds().Dy() * 4
 	if estimatedSize < 1024 {
 		estimatedSize = 1024 // Minimum buffer size
 	}
@@ -294,10 +329,18 @@ func GetImageDataDirect(url string, widthMM, heightMM float64, dpi int) ([]byte,
 		bufferPool.Put(buf)
 	}()
 	
-	// Encode as PNG in memory
-	err = imaging.Encode(buf, nrgba, imaging.PNG)
-	if err != nil {
-		return nil, fmt.Errorf("failed to encode PNG: %w", err)
+	// Photos stay JPEG, which is far smaller than PNG; PNG keeps transparency
+	// and the sharp edges of logos and line art
+	if (isJPEG(imageData) || isLossyWebP(imageData)) && nrgba.Opaque() {
+		err = imaging.Encode(buf, nrgba, imaging.JPEG, imaging.JPEGQuality(req.quality()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode JPEG: %w", err)
+		}
+	} else {
+		err = imaging.Encode(buf, nrgba, imaging.PNG)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode PNG: %w", err)
+		}
 	}
 	
 	// Get processed bytes (copy since buffer will be returned to pool)
@@ -311,7 +354,7 @@ func GetImageDataDirect(url string, widthMM, heightMM float64, dpi int) ([]byte,
 }
 
 // PreloadImagesDirect downloads and processes multiple images in parallel
-// Returns map of URL -> raw PNG bytes (not base64, not file paths)
+// Returns map of ImageRequest.Key() -> raw JPEG, PNG or SVG bytes (not base64, not file paths)
 func PreloadImagesDirect(requests []ImageRequest) map[string][]byte {
 	results := make(map[string][]byte)
 	var mu sync.Mutex
@@ -331,10 +374,10 @@ func PreloadImagesDirect(requests []ImageRequest) map[string][]byte {
 			sem <- struct{}{}
 			defer func() { <-sem }()
 			
-			imageData, err := GetImageDataDirect(r.URL, r.Width, r.Height, r.DPI)
+			imageData, err := GetImageDataDirect(r)
 			if err == nil {
 				mu.Lock()
-				results[r.URL] = imageData
+				results[r.Key()] = imageData
 				mu.Unlock()
 			}
 			// Errors are silently ignored in production for performance
@@ -393,6 +436,61 @@ func isWebP(data []byte) bool {
 	return string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP"
 }
 
+// isLossyWebP detects a WebP image compressed with VP8 (lossy) rather than VP8L
+func isLossyWebP(data []byte) bool {
+	if !isWebP(data) {
+		return false
+	}
+	// Walk the chunks after the header; extended files (VP8X) put the image
+	// data after metadata chunks
+	for off := 12; off+8 <= len(data); {
+		switch string(data[off : off+4]) {
+		case "VP8 ":
+			return true
+		case "VP8L":
+			return false
+		}
+		size := int(binary.LittleEndian.Uint32(data[off+4 : off+8]))
+		if size < 0 || size > len(data) {
+			return false
+		}
+		off += 8 + size + size&1
+	}
+	return false
+}
+
+// isJPEG detects JPEG data by its start of image marker
+func isJPEG(data []byte) bool {
+	return len(data) >= 3 && data[0] == 0xFF && data[1] == 0xD8 && data[2] == 0xFF
+}
+
+// ImageType returns the gofpdf image type of processed image data: "JPG" for
+// JPEGs, "PNG" otherwise
+func ImageType(data []byte) string {
+	if isJPEG(data) {
+		return "JPG"
+	}
+	return "PNG"
+}
+
+// IsSVG detects an SVG document: markup (after any byte order mark, XML
+// declaration, comments and doctype) with an <svg> element near the start
+func IsSVG(data []byte) bool {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '<' {
+		return false
+	}
+	if len(data) > 4096 {
+		data = data[:4096]
+	}
+	head := bytes.ToLower(data)
+	if bytes.HasPrefix(head, []byte("<!doctype html")) || bytes.HasPrefix(head, []byte("<html")) {
+		return false
+	}
+	return bytes.Contains(head, []byte("<svg"))
+}
+
 func downloadFile(url, destPath string) error {
 	resp, err := httpClient.Get(url)
 	if err != nil {
@@ -0,0 +1,30 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsSVG(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"bare svg", `<svg xmlns="http://www.w3.org/2000/svg"/>`, true},
+		{"xml declaration", `<?xml version="1.0"?>` + "\n" + `<svg></svg>`, true},
+		{"byte order mark and whitespace", "\xef\xbb\xbf  \n<svg></svg>", true},
+		{"comment and doctype", `<!-- logo --><!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "x"><SVG></SVG>`, true},
+		{"html page", `<!DOCTYPE html><html><body><svg></svg></body></html>`, false},
+		{"html without doctype", `<html><svg></svg></html>`, false},
+		{"svg too far from the start", `<?xml version="1.0"?><!--` + strings.Repeat("x", 5000) + `--><svg/>`, false},
+		{"png", "\x89PNG\r\n\x1a\n", false},
+		{"jpeg", "\xff\xd8\xff\xe0", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		if got := IsSVG([]byte(tt.data)); got != tt.want {
+			t.Errorf("%s: IsSVG = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
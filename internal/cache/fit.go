@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// Image fit modes, named after CSS object-fit
+const (
+	FitFill    = "fill"    // stretch to the box (default)
+	FitContain = "contain" // scale to fit inside the box, keeping the aspect ratio
+	FitCover   = "cover"   // scale to cover the box, cropping around the focal point
+	FitNone    = "none"    // keep the natural size at the template DPI, cropping around the focal point
+)
+
+// fit returns the fit mode, defaulting to FitFill
+func (r ImageRequest) fit() string {
+	switch r.Fit {
+	case FitContain, FitCover, FitNone:
+		return r.Fit
+	}
+	return FitFill
+}
+
+// fitting is how an image is fitted to a box: a window is cut out of it (all
+// of it when nothing is cropped), then resized to w x h unless w is 0
+type fitting struct {
+	crop image.Rectangle
+	w, h int
+}
+
+// keeps reports whether the fitting leaves an image with these bounds as it is
+func (f fitting) keeps(bounds image.Rectangle) bool {
+	return f.crop == bounds && f.w == 0
+}
+
+// resize sets the target size unless curW x curH is already within 10% of it
+func (f *fitting) resize(curW, curH, w, h int) {
+	if absInt(curW-w) > w/10 || absInt(curH-h) > h/10 {
+		f.w, f.h = w, h
+	}
+}
+
+// planFit works out how an image with the given bounds is cropped and resized
+// for a box of boxW x boxH pixels. Only fill and cover give the box's aspect
+// ratio; the generator places contain and none images inside the box at the
+// focal point.
+func planFit(bounds image.Rectangle, req ImageRequest, boxW, boxH int) fitting {
+	plan := fitting{crop: bounds}
+	origW, origH := bounds.Dx(), bounds.Dy()
+	if boxW <= 0 || boxH <= 0 || origW == 0 || origH == 0 {
+		return plan
+	}
+
+	switch req.fit() {
+	case FitContain:
+		scale := math.Min(float64(boxW)/float64(origW), float64(boxH)/float64(origH))
+		plan.resize(origW, origH, scaled(origW, scale), scaled(origH, scale))
+
+	case FitCover:
+		// Crop the source to the box aspect ratio first so less is resized
+		scale := math.Max(float64(boxW)/float64(origW), float64(boxH)/float64(origH))
+		cropW := minInt(origW, scaled(boxW, 1/scale))
+		cropH := minInt(origH, scaled(boxH, 1/scale))
+		plan.crop = cropRect(bounds, cropW, cropH, req.FocusX, req.FocusY)
+		plan.resize(plan.crop.Dx(), plan.crop.Dy(), boxW, boxH)
+
+	case FitNone:
+		plan.crop = cropRect(bounds, minInt(origW, boxW), minInt(origH, boxH), req.FocusX, req.FocusY)
+
+	default:
+		plan.resize(origW, origH, boxW, boxH)
+	}
+	return plan
+}
+
+// fitImage crops and resizes img for a box of boxW x boxH pixels (see planFit)
+func fitImage(img image.Image, req ImageRequest, boxW, boxH int) image.Image {
+	bounds := img.Bounds()
+	plan := planFit(bounds, req, boxW, boxH)
+	if plan.crop != bounds {
+		img = imaging.Crop(img, plan.crop)
+	}
+	if plan.w > 0 {
+		// Use NearestNeighbor for speed (faster than Lanczos)
+		// For better quality, use imaging.Lanczos, but NearestNeighbor is much faster
+		img = imaging.Resize(img, plan.w, plan.h, imaging.NearestNeighbor)
+	}
+	return img
+}
+
+// cropRect returns a w x h window of bounds, positioned so that the focal
+// point (fx, fy) sits at the same relative position in the window as in the image
+func cropRect(bounds image.Rectangle, w, h int, fx, fy float64) image.Rectangle {
+	if w >= bounds.Dx() && h >= bounds.Dy() {
+		return bounds
+	}
+	left := bounds.Min.X + int(math.Round(float64(bounds.Dx()-w)*fx))
+	top := bounds.Min.Y + int(math.Round(float64(bounds.Dy()-h)*fy))
+	return image.Rect(left, top, left+w, top+h)
+}
+
+func scaled(n int, scale float64) int {
+	v := int(math.Round(float64(n) * scale))
+	if v < 1 {
+		return 1
+	}
+	return v
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"image"
+	"testing"
+)
+
+func TestPlanFit(t *testing.T) {
+	bounds := image.Rect(0, 0, 1000, 500)
+	tests := []struct {
+		name       string
+		req        ImageRequest
+		boxW, boxH int
+		want       fitting
+	}{
+		{"fill", ImageRequest{}, 200, 200, fitting{crop: bounds, w: 200, h: 200}},
+		{"fill at size", ImageRequest{Fit: FitFill}, 1000, 500, fitting{crop: bounds}},
+		{"fill within 10%", ImageRequest{}, 950, 480, fitting{crop: bounds}},
+		{"unknown mode fills", ImageRequest{Fit: "stretch"}, 200, 200, fitting{crop: bounds, w: 200, h: 200}},
+		{"contain", ImageRequest{Fit: FitContain}, 200, 200, fitting{crop: bounds, w: 200, h: 100}},
+		{"cover centered", ImageRequest{Fit: FitCover, FocusX: 0.5, FocusY: 0.5}, 200, 200, fitting{crop: image.Rect(250, 0, 750, 500), w: 200, h: 200}},
+		{"cover focused left", ImageRequest{Fit: FitCover}, 200, 200, fitting{crop: image.Rect(0, 0, 500, 500), w: 200, h: 200}},
+		{"cover larger than image", ImageRequest{Fit: FitCover, FocusX: 0.5, FocusY: 0.5}, 2000, 500, fitting{crop: image.Rect(0, 125, 1000, 375), w: 2000, h: 500}},
+		{"none", ImageRequest{Fit: FitNone, FocusX: 1, FocusY: 1}, 300, 300, fitting{crop: image.Rect(700, 200, 1000, 500)}},
+		{"none in a larger box", ImageRequest{Fit: FitNone}, 2000, 2000, fitting{crop: bounds}},
+		{"empty box", ImageRequest{Fit: FitCover}, 0, 100, fitting{crop: bounds}},
+	}
+	for _, tt := range tests {
+		if got := planFit(bounds, tt.req, tt.boxW, tt.boxH); got != tt.want {
+			t.Errorf("%s: planFit = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCropRect(t *testing.T) {
+	bounds := image.Rect(10, 10, 110, 60)
+	tests := []struct {
+		w, h   int
+		fx, fy float64
+		want   image.Rectangle
+	}{
+		{100, 50, 0.5, 0.5, bounds},
+		{50, 50, 0, 0, image.Rect(10, 10, 60, 60)},
+		{50, 50, 1, 0, image.Rect(60, 10, 110, 60)},
+		{20, 10, 0.5, 0.5, image.Rect(50, 30, 70, 40)},
+	}
+	for _, tt := range tests {
+		if got := cropRect(bounds, tt.w, tt.h, tt.fx, tt.fy); got != tt.want {
+			t.Errorf("cropRect(%dx%d at %v,%v) = %v, want %v", tt.w, tt.h, tt.fx, tt.fy, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package expr
+
+import (
+	"container/list"
+	"sync"
+)
+
+// cacheSize bounds each cache of compiled sources. Sources come from request
+// templates, so an unbounded cache would grow with every distinct string a
+// client sends; a batch only uses a few hundred.
+const cacheSize = 1024
+
+// lru is a fixed-size cache that drops the least recently used entry
+type lru struct {
+	mu    sync.Mutex
+	max   int
+	order *list.List // most recently used at the front
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+func newLRU(max int) *lru {
+	return &lru{max: max, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+// get returns the value cached for key and marks it as recently used
+func (c *lru) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry).value, true
+}
+
+// add caches value for key, dropping the least recently used entry when full
+func (c *lru) add(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruEntry).value = value
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, value: value})
+	if c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// len returns the number of cached entries
+func (c *lru) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,58 @@
+package expr
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLRU(t *testing.T) {
+	c := newLRU(2)
+	c.add("a", 1)
+	c.add("b", 2)
+	c.get("a") // b is now the least recently used
+	c.add("c", 3)
+
+	tests := []struct {
+		key   string
+		value interface{}
+		ok    bool
+	}{
+		{"a", 1, true},
+		{"b", nil, false},
+		{"c", 3, true},
+	}
+	for _, tt := range tests {
+		if v, ok := c.get(tt.key); v != tt.value || ok != tt.ok {
+			t.Errorf("get(%q) = %v, %v; want %v, %v", tt.key, v, ok, tt.value, tt.ok)
+		}
+	}
+}
+
+func TestCachesAreBounded(t *testing.T) {
+	for i := 0; i < 2*cacheSize; i++ {
+		Render(fmt.Sprintf("{{firstName}} %d", i), func(string) string { return "" })
+		if _, err := Compile(fmt.Sprintf("ticketCount >= %d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := templates.len(); n > cacheSize {
+		t.Errorf("%d templates cached, want at most %d", n, cacheSize)
+	}
+	if n := compiled.len(); n > cacheSize {
+		t.Errorf("%d expressions cached, want at most %d", n, cacheSize)
+	}
+}
+
+func TestCompileCachesErrors(t *testing.T) {
+	src := "ticketCount >= (1"
+	_, err := Compile(src)
+	if err == nil {
+		t.Fatal("no error for an unbalanced parenthesis")
+	}
+	if cached, ok := compiled.get(src); !ok || cached != err {
+		t.Errorf("cached %v, %v; want the error %v", cached, ok, err)
+	}
+	if _, again := Compile(src); again != err {
+		t.Errorf("second compile returned %v, want the cached %v", again, err)
+	}
+}
@@ -0,0 +1,515 @@
+// Package expr evaluates the small expression language used in templates:
+// visibleIf conditions that decide, from attendee data, whether a layer is
+// drawn, and the {{ }} placeholders in text, QR content and image URLs
+// (see Template).
+//
+// A condition compares values and combines the results with boolean logic:
+//
+//	badgeCategory == "VIP" && !empty(photo)
+//	customFields.3f2a-9c1d != "" or email endsWith "@example.com"
+//	ticketCount >= 2 and not (country in ["EG", "SA"])
+//
+// Bare names are resolved by the caller (a user field, a custom field ID or a
+// custom field name); names with spaces can be written field("Badge Category").
+// Strings are single- or double-quoted. Comparisons are numeric when both
+// sides are numbers and case-insensitive otherwise.
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Resolver returns the value of a named field, or "" when it is unknown
+type Resolver func(name string) string
+
+// Expr is a compiled expression
+type Expr struct {
+	root node
+}
+
+// compiled caches expressions, or the error they failed with, by source;
+// templates reuse the same few conditions for every badge in a batch
+var compiled = newLRU(cacheSize)
+
+// Compile parses an expression, returning a cached result for sources it has seen
+func Compile(src string) (*Expr, error) {
+	if cached, ok := compiled.get(src); ok {
+		if err, failed := cached.(error); failed {
+			return nil, err
+		}
+		return cached.(*Expr), nil
+	}
+
+	e, err := compile(src)
+	if err != nil {
+		compiled.add(src, err)
+		return nil, err
+	}
+	compiled.add(src, e)
+	return e, nil
+}
+
+// compile parses an expression
+func compile(src string) (*Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+	}
+	return &Expr{root: root}, nil
+}
+
+// Bool evaluates the expression and reports whether its result is truthy
+func (e *Expr) Bool(resolve Resolver) bool {
+	return e.root.eval(resolve).truthy()
+}
+
+// Eval compiles and evaluates a condition. An empty condition is true.
+func Eval(src string, resolve Resolver) (bool, error) {
+	if strings.TrimSpace(src) == "" {
+		return true, nil
+	}
+	e, err := Compile(src)
+	if err != nil {
+		return false, err
+	}
+	return e.Bool(resolve), nil
+}
+
+// ============ VALUES ============
+
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindBool
+	kindList
+)
+
+// value is the result of evaluating a node. Field values are strings;
+// comparisons produce booleans; list literals only appear on the right of "in".
+type value struct {
+	kind valueKind
+	str  string
+	b    bool
+	list []value
+}
+
+func stringValue(s string) value { return value{kind: kindString, str: s} }
+func boolValue(b bool) value     { return value{kind: kindBool, b: b} }
+
+// String returns the value as text; booleans are "true" or "false"
+func (v value) String() string {
+	switch v.kind {
+	case kindBool:
+		return strconv.FormatBool(v.b)
+	case kindList:
+		parts := make([]string, len(v.list))
+		for i, item := range v.list {
+			parts[i] = item.String()
+		}
+		return strings.Join(parts, ", ")
+	}
+	return v.str
+}
+
+// truthy treats empty text, "false", "no", "0" and empty lists as false
+func (v value) truthy() bool {
+	switch v.kind {
+	case kindBool:
+		return v.b
+	case kindList:
+		return len(v.list) > 0
+	}
+	switch strings.ToLower(strings.TrimSpace(v.str)) {
+	case "", "false", "no", "0":
+		return false
+	}
+	return true
+}
+
+func (v value) empty() bool {
+	if v.kind == kindList {
+		return len(v.list) == 0
+	}
+	return v.kind == kindString && strings.TrimSpace(v.str) == ""
+}
+
+func (v value) number() (float64, bool) {
+	if v.kind != kindString {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(v.str), 64)
+	return n, err == nil
+}
+
+// compare orders two values: numerically when both are numbers, otherwise as
+// case-insensitive text
+func compare(a, b value) int {
+	if x, ok := a.number(); ok {
+		if y, ok := b.number(); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fold(a.String()), fold(b.String()))
+}
+
+func fold(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// ============ NODES ============
+
+type node interface {
+	eval(resolve Resolver) value
+}
+
+type literal struct{ v value }
+
+func (n literal) eval(Resolver) value { return n.v }
+
+type fieldRef struct{ name string }
+
+func (n fieldRef) eval(resolve Resolver) value {
+	if resolve == nil {
+		return stringValue("")
+	}
+	return stringValue(resolve(n.name))
+}
+
+type listNode struct{ items []node }
+
+func (n listNode) eval(resolve Resolver) value {
+	v := value{kind: kindList}
+	for _, item := range n.items {
+		v.list = append(v.list, item.eval(resolve))
+	}
+	return v
+}
+
+type notNode struct{ operand node }
+
+func (n notNode) eval(resolve Resolver) value {
+	return boolValue(!n.operand.eval(resolve).truthy())
+}
+
+type logicNode struct {
+	and         bool
+	left, right node
+}
+
+func (n logicNode) eval(resolve Resolver) value {
+	left := n.left.eval(resolve).truthy()
+	if n.and && !left || !n.and && left {
+		return boolValue(left)
+	}
+	return boolValue(n.right.eval(resolve).truthy())
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n compareNode) eval(resolve Resolver) value {
+	left := n.left.eval(resolve)
+	right := n.right.eval(resolve)
+
+	switch n.op {
+	case "==":
+		return boolValue(compare(left, right) == 0)
+	case "!=":
+		return boolValue(compare(left, right) != 0)
+	case "<":
+		return boolValue(compare(left, right) < 0)
+	case "<=":
+		return boolValue(compare(left, right) <= 0)
+	case ">":
+		return boolValue(compare(left, right) > 0)
+	case ">=":
+		return boolValue(compare(left, right) >= 0)
+	case "contains":
+		return boolValue(strings.Contains(fold(left.String()), fold(right.String())))
+	case "startswith":
+		return boolValue(strings.HasPrefix(fold(left.String()), fold(right.String())))
+	case "endswith":
+		return boolValue(strings.HasSuffix(fold(left.String()), fold(right.String())))
+	case "in":
+		candidates := right.list
+		if right.kind != kindList {
+			// "x in field" checks a comma-separated field value
+			for _, part := range strings.Split(right.String(), ",") {
+				candidates = append(candidates, stringValue(part))
+			}
+		}
+		for _, candidate := range candidates {
+			if compare(left, candidate) == 0 {
+				return boolValue(true)
+			}
+		}
+		return boolValue(false)
+	}
+	return boolValue(false)
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+// functions maps the callable names (lower case) to their implementation and
+// number of arguments
+var functions = map[string]struct {
+	arity int
+	fn    func(args []value, resolve Resolver) value
+}{
+	"empty": {1, func(args []value, _ Resolver) value {
+		return boolValue(args[0].empty())
+	}},
+	"notempty": {1, func(args []value, _ Resolver) value {
+		return boolValue(!args[0].empty())
+	}},
+	"field": {1, func(args []value, resolve Resolver) value {
+		return fieldRef{name: args[0].String()}.eval(resolve)
+	}},
+	"lower": {1, func(args []value, _ Resolver) value {
+		return stringValue(strings.ToLower(args[0].String()))
+	}},
+	"upper": {1, func(args []value, _ Resolver) value {
+		return stringValue(strings.ToUpper(args[0].String()))
+	}},
+	"len": {1, func(args []value, _ Resolver) value {
+		if args[0].kind == kindList {
+			return stringValue(strconv.Itoa(len(args[0].list)))
+		}
+		return stringValue(strconv.Itoa(len([]rune(strings.TrimSpace(args[0].String())))))
+	}},
+}
+
+func (n callNode) eval(resolve Resolver) value {
+	args := make([]value, len(n.args))
+	for i, arg := range n.args {
+		args[i] = arg.eval(resolve)
+	}
+	return functions[n.name].fn(args, resolve)
+}
+
+// ============ PARSER ============
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	tok := p.next()
+	if tok.kind != kind || tok.text != text {
+		return fmt.Errorf("expected %q at offset %d, found %q", text, tok.pos, tok.text)
+	}
+	return nil
+}
+
+// isWord reports whether the next token is the operator or keyword word,
+// written either as a symbol or case-insensitively as a word
+func (p *parser) isWord(words ...string) bool {
+	tok := p.peek()
+	if tok.kind != tokOp && tok.kind != tokIdent {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(tok.text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isWord("||", "or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicNode{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isWord("&&", "and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.isWord("!", "not") {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+// comparisonOps are the binary comparison operators; "=" is accepted for "=="
+var comparisonOps = []string{"==", "=", "!=", "<", "<=", ">", ">=", "contains", "startsWith", "endsWith", "in"}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	// "x not in [...]" reads better than "!(x in [...])"
+	negate := false
+	if p.isWord("not") && p.pos+1 < len(p.tokens) && strings.EqualFold(p.tokens[p.pos+1].text, "in") {
+		p.next()
+		negate = true
+	}
+	if !p.isWord(comparisonOps...) {
+		return left, nil
+	}
+
+	op := strings.ToLower(p.next().text)
+	if op == "=" {
+		op = "=="
+	}
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	var n node = compareNode{op: op, left: left, right: right}
+	if negate {
+		n = notNode{operand: n}
+	}
+	return n, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokString:
+		return literal{stringValue(tok.text)}, nil
+	case tokNumber:
+		return literal{stringValue(tok.text)}, nil
+	case tokIdent:
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return literal{boolValue(true)}, nil
+		case "false":
+			return literal{boolValue(false)}, nil
+		case "null", "nil":
+			return literal{stringValue("")}, nil
+		}
+		if p.peek().kind == tokOp && p.peek().text == "(" {
+			return p.parseCall(tok)
+		}
+		return fieldRef{name: tok.text}, nil
+	case tokOp:
+		switch tok.text {
+		case "(":
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(tokOp, ")")
+		case "[":
+			return p.parseList()
+		}
+	}
+	if tok.kind == tokEOF {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := functions[strings.ToLower(name.text)]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at offset %d", name.text, name.pos)
+	}
+	p.next() // (
+
+	var args []node
+	for !(p.peek().kind == tokOp && p.peek().text == ")") {
+		if len(args) > 0 {
+			if err := p.expect(tokOp, ","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next() // )
+
+	if len(args) != fn.arity {
+		return nil, fmt.Errorf("%s() takes %d argument(s), got %d", name.text, fn.arity, len(args))
+	}
+	return callNode{name: strings.ToLower(name.text), args: args}, nil
+}
+
+func (p *parser) parseList() (node, error) {
+	var list listNode
+	for !(p.peek().kind == tokOp && p.peek().text == "]") {
+		if len(list.items) > 0 {
+			if err := p.expect(tokOp, ","); err != nil {
+				return nil, err
+			}
+		}
+		item, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		list.items = append(list.items, item)
+	}
+	p.next() // ]
+	return list, nil
+}
@@ -0,0 +1,117 @@
+package expr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		src   string
+		kinds []tokenKind
+		texts []string
+	}{
+		{`a == "b"`, []tokenKind{tokIdent, tokOp, tokString, tokEOF}, []string{"a", "==", "b", ""}},
+		{`customFields.3f2a-9c1d != ''`, []tokenKind{tokIdent, tokOp, tokString, tokEOF}, []string{"customFields.3f2a-9c1d", "!=", "", ""}},
+		{`addons[0].name`, []tokenKind{tokIdent, tokEOF}, []string{"addons[0].name", ""}},
+		{`n >= -1.5`, []tokenKind{tokIdent, tokOp, tokNumber, tokEOF}, []string{"n", ">=", "-1.5", ""}},
+		{`x in [1, 2]`, []tokenKind{tokIdent, tokIdent, tokOp, tokNumber, tokOp, tokNumber, tokOp, tokEOF}, []string{"x", "in", "[", "1", ",", "2", "]", ""}},
+		{`'it\'s'`, []tokenKind{tokString, tokEOF}, []string{"it's", ""}},
+		{`a&&!b||c`, []tokenKind{tokIdent, tokOp, tokOp, tokIdent, tokOp, tokIdent, tokEOF}, []string{"a", "&&", "!", "b", "||", "c", ""}},
+	}
+	for _, tt := range tests {
+		tokens, err := lex(tt.src)
+		if err != nil {
+			t.Errorf("lex(%q): %v", tt.src, err)
+			continue
+		}
+		if len(tokens) != len(tt.kinds) {
+			t.Errorf("lex(%q) = %v, want %d tokens", tt.src, tokens, len(tt.kinds))
+			continue
+		}
+		for i, tok := range tokens {
+			if tok.kind != tt.kinds[i] || tok.text != tt.texts[i] {
+				t.Errorf("lex(%q) token %d = %d %q, want %d %q", tt.src, i, tok.kind, tok.text, tt.kinds[i], tt.texts[i])
+			}
+		}
+	}
+}
+
+func TestLexErrors(t *testing.T) {
+	for _, src := range []string{`"open`, `a # b`, `a + b`} {
+		if _, err := lex(src); err == nil {
+			t.Errorf("lex(%q) succeeded", src)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	fields := map[string]string{
+		"badgeCategory":          "VIP",
+		"email":                  "jane@Example.com",
+		"ticketCount":            "3",
+		"country":                "EG",
+		"tags":                   "speaker, press",
+		"photo":                  "",
+		"Badge Category":         "Staff",
+		"customFields.3f2a-9c1d": "yes",
+	}
+	resolve := func(name string) string { return fields[name] }
+
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{``, true},
+		{`badgeCategory == "VIP"`, true},
+		{`badgeCategory = 'vip'`, true},
+		{`badgeCategory != "VIP"`, false},
+		{`ticketCount >= 2`, true},
+		{`ticketCount > 10`, false},
+		{`ticketCount < 10`, true},
+		{`email endsWith "@example.com"`, true},
+		{`email startsWith "jane" and email contains "@"`, true},
+		{`country in ["EG", "SA"]`, true},
+		{`country not in ["EG", "SA"]`, false},
+		{`"press" in tags`, true},
+		{`empty(photo)`, true},
+		{`notEmpty(photo) || badgeCategory == "VIP"`, true},
+		{`!(ticketCount >= 2) or false`, false},
+		{`not empty(email) && true`, true},
+		{`field("Badge Category") == "Staff"`, true},
+		{`customFields.3f2a-9c1d`, true},
+		{`len(country) == 2`, true},
+		{`lower(badgeCategory) == "vip"`, true},
+		{`unknownField`, false},
+	}
+	for _, tt := range tests {
+		got, err := Eval(tt.src, resolve)
+		if err != nil {
+			t.Errorf("Eval(%q): %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{`a ==`, "unexpected end of expression"},
+		{`(a == b`, ""},
+		{`a == b c`, `unexpected "c"`},
+		{`nope(a)`, `unknown function "nope"`},
+		{`empty(a, b)`, "takes 1 argument(s), got 2"},
+		{`a in [b c]`, ""},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Compile(%q) error %v, want %q", tt.src, err, tt.err)
+		}
+	}
+}
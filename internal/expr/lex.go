@@ -0,0 +1,105 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// twoCharOps are matched before their one-character prefixes
+var twoCharOps = []string{"==", "!=", "<=", ">=", "&&", "||"}
+
+// lex splits an expression into tokens. Identifiers may contain dots, hyphens
+// and index suffixes, so customFields.3f2a-9c1d and addons[0].name are single
+// field names.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '"' || r == '\'':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, token{kind: tokString, text: sb.String(), pos: i})
+			i = j + 1
+
+		case unicode.IsDigit(r) || (r == '-' || r == '.') && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: string(runes[i:j]), pos: i})
+			i = j
+
+		case unicode.IsLetter(r) || r == '_' || r == '$':
+			j := i + 1
+			for j < len(runes) {
+				c := runes[j]
+				if unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '.' || c == '-' || c == '$' {
+					j++
+					continue
+				}
+				// Index suffix such as addons[0]
+				if c == '[' {
+					k := j + 1
+					for k < len(runes) && unicode.IsDigit(runes[k]) {
+						k++
+					}
+					if k > j+1 && k < len(runes) && runes[k] == ']' {
+						j = k + 1
+						continue
+					}
+				}
+				break
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: string(runes[i:j]), pos: i})
+			i = j
+
+		default:
+			op := string(r)
+			for _, two := range twoCharOps {
+				if strings.HasPrefix(string(runes[i:]), two) {
+					op = two
+					break
+				}
+			}
+			if !strings.Contains("=!<>()[],|:", string(r)) && len(op) == 1 {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", r, i)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+			i += len([]rune(op))
+		}
+	}
+
+	return append(tokens, token{kind: tokEOF, pos: len(runes)}), nil
+}
@@ -0,0 +1,369 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Template is text with {{ }} placeholders. A placeholder holds an expression
+// (usually a field name) followed by optional filters:
+//
+//	{{firstName}} {{lastName | upper}}
+//	{{customFields.3f2a-9c1d | default:"Guest"}}
+//	{{field("Company Name") | truncate:24}}
+//	{{registeredAt | date:"DD MMM YYYY"}}
+type Template struct {
+	parts []templatePart
+}
+
+// templatePart is literal text, or a placeholder when expr is set
+type templatePart struct {
+	text    string
+	expr    node
+	filters []filterCall
+}
+
+type filterCall struct {
+	name string
+	args []node
+}
+
+// filters maps filter names (lower case) to their implementation and the
+// number of arguments they accept
+var filters = map[string]struct {
+	minArgs, maxArgs int
+	fn               func(in string, args []string) string
+}{
+	"upper": {0, 0, func(in string, _ []string) string { return strings.ToUpper(in) }},
+	"lower": {0, 0, func(in string, _ []string) string { return strings.ToLower(in) }},
+	"title": {0, 0, func(in string, _ []string) string { return titleCase(in) }},
+	"trim":  {0, 0, func(in string, _ []string) string { return strings.TrimSpace(in) }},
+	"default": {1, 1, func(in string, args []string) string {
+		if strings.TrimSpace(in) == "" {
+			return args[0]
+		}
+		return in
+	}},
+	"truncate": {1, 2, truncate},
+	"date":     {0, 1, formatDate},
+}
+
+// templates caches parsed templates by source, like compiled
+var templates = newLRU(cacheSize)
+
+// CompileTemplate parses the placeholders in src, returning a cached result
+// for sources it has seen. A placeholder that does not parse is kept as
+// literal text, so a typo shows up on the badge instead of disappearing.
+func CompileTemplate(src string) *Template {
+	if t, ok := templates.get(src); ok {
+		return t.(*Template)
+	}
+
+	t := &Template{}
+	rest := src
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			break
+		}
+		end := placeholderEnd(rest, start+2)
+		if end < 0 {
+			break
+		}
+
+		if start > 0 {
+			t.parts = append(t.parts, templatePart{text: rest[:start]})
+		}
+		raw := rest[start : end+2]
+		if part, err := parsePlaceholder(rest[start+2 : end]); err == nil {
+			t.parts = append(t.parts, part)
+		} else {
+			t.parts = append(t.parts, templatePart{text: raw})
+		}
+		rest = rest[end+2:]
+	}
+	if rest != "" {
+		t.parts = append(t.parts, templatePart{text: rest})
+	}
+
+	templates.add(src, t)
+	return t
+}
+
+// Render resolves every placeholder in src
+func Render(src string, resolve Resolver) string {
+	if !strings.Contains(src, "{{") {
+		return src
+	}
+	return CompileTemplate(src).Render(resolve)
+}
+
+// Render resolves the template's placeholders. Unknown fields are empty
+// unless a default filter supplies a value.
+func (t *Template) Render(resolve Resolver) string {
+	var sb strings.Builder
+	for _, part := range t.parts {
+		if part.expr == nil {
+			sb.WriteString(part.text)
+			continue
+		}
+
+		out := part.expr.eval(resolve).String()
+		for _, f := range part.filters {
+			args := make([]string, len(f.args))
+			for i, arg := range f.args {
+				args[i] = arg.eval(resolve).String()
+			}
+			out = filters[f.name].fn(out, args)
+		}
+		sb.WriteString(out)
+	}
+	return sb.String()
+}
+
+// placeholderEnd returns the index of the "}}" closing a placeholder whose
+// body starts at from, skipping braces inside quoted strings, or -1
+func placeholderEnd(s string, from int) int {
+	var quote byte
+	for i := from; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '}' && i+1 < len(s) && s[i+1] == '}':
+			return i
+		}
+	}
+	return -1
+}
+
+// parsePlaceholder parses "expression | filter:arg,arg | filter"
+func parsePlaceholder(body string) (templatePart, error) {
+	tokens, err := lex(body)
+	if err != nil {
+		return templatePart{}, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return templatePart{}, err
+	}
+	part := templatePart{expr: root}
+
+	for p.peek().kind == tokOp && p.peek().text == "|" {
+		p.next()
+		name := p.next()
+		if name.kind != tokIdent {
+			return templatePart{}, fmt.Errorf("expected filter name at offset %d", name.pos)
+		}
+		spec, ok := filters[strings.ToLower(name.text)]
+		if !ok {
+			return templatePart{}, fmt.Errorf("unknown filter %q", name.text)
+		}
+
+		f := filterCall{name: strings.ToLower(name.text)}
+		if p.peek().kind == tokOp && p.peek().text == ":" {
+			p.next()
+			for {
+				arg, err := p.parsePrimary()
+				if err != nil {
+					return templatePart{}, err
+				}
+				f.args = append(f.args, arg)
+				if p.peek().kind != tokOp || p.peek().text != "," {
+					break
+				}
+				p.next()
+			}
+		}
+		if len(f.args) < spec.minArgs || len(f.args) > spec.maxArgs {
+			return templatePart{}, fmt.Errorf("filter %s takes %d-%d argument(s), got %d", name.text, spec.minArgs, spec.maxArgs, len(f.args))
+		}
+		part.filters = append(part.filters, f)
+	}
+
+	if tok := p.peek(); tok.kind != tokEOF {
+		return templatePart{}, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+	}
+	return part, nil
+}
+
+// ============ FILTERS ============
+
+// titleCase capitalizes the first letter of every word and lowers the rest
+func titleCase(s string) string {
+	runes := []rune(strings.ToLower(s))
+	start := true
+	for i, r := range runes {
+		if unicode.IsLetter(r) {
+			if start {
+				runes[i] = unicode.ToUpper(r)
+			}
+			start = false
+		} else {
+			start = unicode.IsSpace(r) || r == '-'
+		}
+	}
+	return string(runes)
+}
+
+// truncate cuts text to at most n characters (counting the suffix), ending it
+// with the suffix ("…" unless a second argument is given)
+func truncate(in string, args []string) string {
+	n, err := strconv.Atoi(strings.TrimSpace(args[0]))
+	if err != nil || n < 0 {
+		return in
+	}
+	suffix := "…"
+	if len(args) > 1 {
+		suffix = args[1]
+	}
+
+	runes := []rune(in)
+	if len(runes) <= n {
+		return in
+	}
+	keep := n - len([]rune(suffix))
+	if keep <= 0 {
+		return string(runes[:n])
+	}
+	return strings.TrimRightFunc(string(runes[:keep]), unicode.IsSpace) + suffix
+}
+
+// dateInputLayouts are the formats date values are accepted in
+var dateInputLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// minTimestampDigits is the length below which a number is not read as a
+// Unix timestamp: nine digits reach back to 1973, so years and small counts
+// stay what they are
+const minTimestampDigits = 9
+
+// formatDate reformats a date value. The layout is either a Go layout
+// ("02 Jan 2006") or uses the common tokens YYYY, YY, MMMM, MMM, MM, M, DD, D,
+// dddd, ddd, HH, hh, h, mm, ss, A, with other words and [bracketed] text kept
+// literally. A word made of tokens alone is always replaced, so a lone "A" or
+// "a" is the AM/PM marker; write "[A]" for the letter. Values that are not
+// dates pass through.
+func formatDate(in string, args []string) string {
+	value := strings.TrimSpace(in)
+	if value == "" {
+		return in
+	}
+
+	var t time.Time
+	parsed := false
+	for _, layout := range dateInputLayouts {
+		if v, err := time.Parse(layout, value); err == nil {
+			t, parsed = v, true
+			break
+		}
+	}
+	if !parsed {
+		// Unix timestamps, in seconds or milliseconds
+		if len(value) < minTimestampDigits {
+			return in
+		}
+		secs, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return in
+		}
+		if len(value) > 11 {
+			t = time.UnixMilli(secs).UTC()
+		} else {
+			t = time.Unix(secs, 0).UTC()
+		}
+	}
+
+	if len(args) == 0 || args[0] == "" {
+		return t.Format("02 Jan 2006")
+	}
+	if isGoLayout(args[0]) {
+		return t.Format(args[0])
+	}
+	return formatTokens(t, args[0])
+}
+
+// dateTokens translates display tokens to Go layout elements, longest first
+var dateTokens = []struct{ token, layout string }{
+	{"YYYY", "2006"}, {"YY", "06"},
+	{"MMMM", "January"}, {"MMM", "Jan"}, {"MM", "01"}, {"M", "1"},
+	{"dddd", "Monday"}, {"ddd", "Mon"},
+	{"DD", "02"}, {"D", "2"},
+	{"HH", "15"}, {"hh", "03"}, {"h", "3"},
+	{"mm", "04"}, {"ss", "05"},
+	{"A", "PM"}, {"a", "pm"},
+}
+
+// isGoLayout reports whether a date format is a Go layout rather than tokens
+func isGoLayout(format string) bool {
+	return strings.Contains(format, "2006") || strings.Contains(format, "Jan") || strings.Contains(format, "01")
+}
+
+// formatTokens formats t with a display-token format. Tokens are only
+// recognized in words made of nothing but tokens ("DD", "MMM", "hhA"), so
+// other words such as "at" are kept as they are; text in [brackets] is always
+// literal.
+func formatTokens(t time.Time, format string) string {
+	var sb strings.Builder
+	runes := []rune(format)
+	for i := 0; i < len(runes); {
+		switch {
+		case runes[i] == '[':
+			end := i + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			sb.WriteString(string(runes[i+1 : end]))
+			i = end + 1
+		case unicode.IsLetter(runes[i]):
+			end := i
+			for end < len(runes) && unicode.IsLetter(runes[end]) {
+				end++
+			}
+			sb.WriteString(formatWord(t, string(runes[i:end])))
+			i = end
+		default:
+			sb.WriteRune(runes[i])
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// formatWord formats a word that is a sequence of date tokens, or returns it
+// unchanged when any part of it is not a token
+func formatWord(t time.Time, word string) string {
+	var sb strings.Builder
+	for i := 0; i < len(word); {
+		matched := false
+		for _, dt := range dateTokens {
+			if strings.HasPrefix(word[i:], dt.token) {
+				sb.WriteString(t.Format(dt.layout))
+				i += len(dt.token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return word
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,72 @@
+package expr
+
+import "testing"
+
+func TestFormatDate(t *testing.T) {
+	tests := []struct {
+		value  string
+		format string
+		want   string
+	}{
+		{"2025-03-07T14:05:09Z", "", "07 Mar 2025"},
+		{"2025-03-07", "DD/MM/YYYY", "07/03/2025"},
+		{"2025-03-07T14:05:09Z", "D MMM at HH:mm", "7 Mar at 14:05"},
+		{"2025-03-07T14:05:09Z", "dddd, MMMM D", "Friday, March 7"},
+		{"2025-03-07T14:05:09Z", "h:mmA", "2:05PM"},
+		{"2025-03-07T14:05:09Z", "h:mm a", "2:05 pm"},
+		{"2025-03-07T14:05:09Z", "[Day] D [at] h", "Day 7 at 2"},
+		{"2025-03-07T14:05:09Z", "Doors open HH:mm", "Doors open 14:05"},
+		{"2025-03-07T14:05:09Z", "02 Jan 2006 15:04", "07 Mar 2025 14:05"},
+		{"1741356309", "YYYY-MM-DD", "2025-03-07"},
+		{"1741356309000", "YYYY-MM-DD", "2025-03-07"},
+		{"soon", "YYYY", "soon"},
+		{"2024", "YYYY", "2024"},
+		{"2024-11", "MMMM YYYY", "November 2024"},
+		{"12345", "YYYY", "12345"},
+		{"123456789", "YYYY-MM-DD", "1973-11-29"},
+		{"2025-03-07T14:05:09Z", "[A] h A", "A 2 PM"},
+	}
+	for _, tt := range tests {
+		var args []string
+		if tt.format != "" {
+			args = []string{tt.format}
+		}
+		if got := formatDate(tt.value, args); got != tt.want {
+			t.Errorf("formatDate(%q, %q) = %q, want %q", tt.value, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	fields := map[string]string{
+		"firstName":    "jane",
+		"lastName":     "Doe",
+		"company":      "  Acme Corporation International  ",
+		"Company Name": "Acme",
+		"registeredAt": "2025-03-07T14:05:09Z",
+	}
+	resolve := func(name string) string { return fields[name] }
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"No placeholders", "No placeholders"},
+		{"{{firstName}} {{lastName}}", "jane Doe"},
+		{"{{ firstName | title }} {{lastName | upper}}", "Jane DOE"},
+		{"{{nickname | default:\"Guest\"}}", "Guest"},
+		{"{{company | trim | truncate:10}}", "Acme Corp…"},
+		{"{{company | trim | truncate:10,\"...\"}}", "Acme Co..."},
+		{"{{field(\"Company Name\") | lower}}", "acme"},
+		{"{{registeredAt | date:\"D MMM\"}}", "7 Mar"},
+		{"{{default:\"x\" | }}", "{{default:\"x\" | }}"},
+		{"{{firstName | nope}}", "{{firstName | nope}}"},
+		{"{{'}}' | upper}} ok", "}} ok"},
+		{"open {{firstName", "open {{firstName"},
+	}
+	for _, tt := range tests {
+		if got := Render(tt.src, resolve); got != tt.want {
+			t.Errorf("Render(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package fonts
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// Glyph coverage from the cmap table, used to pick a fallback font for
+// characters the requested font doesn't have.
+
+// runeRange is an inclusive range of code points that map to real glyphs
+type runeRange struct {
+	lo, hi rune
+}
+
+// coverage is a sorted list of non-overlapping rune ranges
+type coverage []runeRange
+
+func (c coverage) contains(r rune) bool {
+	i := sort.Search(len(c), func(i int) bool { return c[i].hi >= r })
+	return i < len(c) && c[i].lo <= r
+}
+
+// Covers reports whether the face has a glyph for r.
+// The cmap is parsed on first use and kept for the life of the process.
+func (f *Face) Covers(r rune) bool {
+	f.cmapOnce.Do(func() {
+		data, err := f.Data()
+		if err == nil {
+			f.cmap = parseCmap(data)
+		}
+	})
+	return f.cmap.contains(r)
+}
+
+// parseCmap extracts the Unicode coverage of a font file.
+// Format 12 (full Unicode) is preferred over format 4 (BMP only).
+func parseCmap(font []byte) coverage {
+	cmap := findTable(font, "cmap")
+	if len(cmap) < 4 {
+		return nil
+	}
+
+	numTables := int(binary.BigEndian.Uint16(cmap[2:4]))
+	var best []byte
+	bestRank := 0
+	for i := 0; i < numTables; i++ {
+		rec := 4 + i*8
+		if rec+8 > len(cmap) {
+			break
+		}
+		platformID := binary.BigEndian.Uint16(cmap[rec:])
+		encodingID := binary.BigEndian.Uint16(cmap[rec+2:])
+		offset := int(binary.BigEndian.Uint32(cmap[rec+4:]))
+		if offset+2 > len(cmap) {
+			continue
+		}
+		format := binary.BigEndian.Uint16(cmap[offset:])
+
+		rank := 0
+		switch {
+		case format == 12 && (platformID == 3 && encodingID == 10 || platformID == 0):
+			rank = 3
+		case format == 4 && (platformID == 3 && encodingID == 1 || platformID == 0):
+			rank = 2
+		}
+		if rank > bestRank {
+			best = cmap[offset:]
+			bestRank = rank
+		}
+	}
+
+	switch bestRank {
+	case 3:
+		return parseCmapFormat12(best)
+	case 2:
+		return parseCmapFormat4(best)
+	}
+	return nil
+}
+
+func parseCmapFormat4(sub []byte) coverage {
+	if len(sub) < 14 {
+		return nil
+	}
+	segCount := int(binary.BigEndian.Uint16(sub[6:8])) / 2
+	endCodes := 14
+	startCodes := endCodes + segCount*2 + 2
+	idDeltas := startCodes + segCount*2
+	idRangeOffsets := idDeltas + segCount*2
+	if idRangeOffsets+segCount*2 > len(sub) {
+		return nil
+	}
+
+	var cov coverage
+	add := func(r rune) {
+		if n := len(cov); n > 0 && cov[n-1].hi == r-1 {
+			cov[n-1].hi = r
+			return
+		}
+		cov = append(cov, runeRange{r, r})
+	}
+
+	for i := 0; i < segCount; i++ {
+		end := rune(binary.BigEndian.Uint16(sub[endCodes+i*2:]))
+		start := rune(binary.BigEndian.Uint16(sub[startCodes+i*2:]))
+		delta := binary.BigEndian.Uint16(sub[idDeltas+i*2:])
+		rangeOffsetPos := idRangeOffsets + i*2
+		rangeOffset := int(binary.BigEndian.Uint16(sub[rangeOffsetPos:]))
+		if start > end || start == 0xFFFF {
+			continue
+		}
+
+		for c := start; c <= end; c++ {
+			var glyph uint16
+			if rangeOffset == 0 {
+				glyph = uint16(c) + delta
+			} else {
+				pos := rangeOffsetPos + rangeOffset + int(c-start)*2
+				if pos+2 > len(sub) {
+					continue
+				}
+				glyph = binary.BigEndian.Uint16(sub[pos:])
+				if glyph != 0 {
+					glyph += delta
+				}
+			}
+			if glyph != 0 {
+				add(c)
+			}
+		}
+	}
+
+	sort.Slice(cov, func(i, j int) bool { return cov[i].lo < cov[j].lo })
+	return cov
+}
+
+func parseCmapFormat12(sub []byte) coverage {
+	if len(sub) < 16 {
+		return nil
+	}
+	// The count comes from the file; a truncated table holds fewer groups
+	numGroups := int(binary.BigEndian.Uint32(sub[12:16]))
+	numGroups = min(numGroups, (len(sub)-16)/12)
+
+	cov := make(coverage, 0, numGroups)
+	for i := 0; i < numGroups; i++ {
+		g := 16 + i*12
+		start := rune(binary.BigEndian.Uint32(sub[g:]))
+		end := rune(binary.BigEndian.Uint32(sub[g+4:]))
+		startGlyph := binary.BigEndian.Uint32(sub[g+8:])
+		// A group starting at glyph 0 maps its first code point to .notdef
+		if startGlyph == 0 {
+			start++
+		}
+		if start <= end {
+			cov = append(cov, runeRange{start, end})
+		}
+	}
+
+	sort.Slice(cov, func(i, j int) bool { return cov[i].lo < cov[j].lo })
+	return cov
+}
+
+// findTable returns the bytes of a table from an in-memory font file
+func findTable(font []byte, tag string) []byte {
+	if len(font) < 12 {
+		return nil
+	}
+	numTables := int(binary.BigEndian.Uint16(font[4:6]))
+	for i := 0; i < numTables; i++ {
+		rec := 12 + i*16
+		if rec+16 > len(font) {
+			return nil
+		}
+		if string(font[rec:rec+4]) != tag {
+			continue
+		}
+		offset := int(binary.BigEndian.Uint32(font[rec+8:]))
+		length := int(binary.BigEndian.Uint32(font[rec+12:]))
+		if offset+length > len(font) {
+			return nil
+		}
+		return font[offset : offset+length]
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package fonts
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// format4 builds a format 4 subtable mapping each [start, end] segment with
+// idDelta, plus the required final 0xFFFF segment
+func format4(segments ...[3]uint16) []byte {
+	segments = append(segments, [3]uint16{0xFFFF, 0xFFFF, 1})
+	n := len(segments)
+	sub := make([]byte, 16+n*8)
+	binary.BigEndian.PutUint16(sub[0:], 4)
+	binary.BigEndian.PutUint16(sub[2:], uint16(len(sub)))
+	binary.BigEndian.PutUint16(sub[6:], uint16(n*2))
+	for i, seg := range segments {
+		binary.BigEndian.PutUint16(sub[14+i*2:], seg[1])     // endCode
+		binary.BigEndian.PutUint16(sub[16+n*2+i*2:], seg[0]) // startCode
+		binary.BigEndian.PutUint16(sub[16+n*4+i*2:], seg[2]) // idDelta
+		binary.BigEndian.PutUint16(sub[16+n*6+i*2:], 0)      // idRangeOffset
+	}
+	return sub
+}
+
+// format12 builds a format 12 subtable from [start, end, startGlyph] groups
+func format12(groups ...[3]uint32) []byte {
+	sub := make([]byte, 16+len(groups)*12)
+	binary.BigEndian.PutUint16(sub[0:], 12)
+	binary.BigEndian.PutUint32(sub[4:], uint32(len(sub)))
+	binary.BigEndian.PutUint32(sub[12:], uint32(len(groups)))
+	for i, g := range groups {
+		binary.BigEndian.PutUint32(sub[16+i*12:], g[0])
+		binary.BigEndian.PutUint32(sub[20+i*12:], g[1])
+		binary.BigEndian.PutUint32(sub[24+i*12:], g[2])
+	}
+	return sub
+}
+
+func TestParseCmapFormat4(t *testing.T) {
+	// Latin letters map to glyphs 1-26; a delta that lands on glyph 0 is .notdef
+	cov := parseCmapFormat4(format4([3]uint16{'A', 'Z', 0x10000 + 1 - 'A'}, [3]uint16{'0', '1', 0x10000 - '0'}))
+	tests := []struct {
+		r    rune
+		want bool
+	}{
+		{'A', true},
+		{'M', true},
+		{'Z', true},
+		{'a', false},
+		{'0', false},
+		{'1', true},
+		{0xFFFF, false},
+	}
+	for _, tt := range tests {
+		if got := cov.contains(tt.r); got != tt.want {
+			t.Errorf("contains(%q) = %v, want %v", tt.r, got, tt.want)
+		}
+	}
+	if parseCmapFormat4([]byte{0, 4}) != nil {
+		t.Error("short subtable has coverage")
+	}
+}
+
+func TestParseCmapFormat12(t *testing.T) {
+	cov := parseCmapFormat12(format12([3]uint32{0x600, 0x6FF, 10}, [3]uint32{0x1F600, 0x1F64F, 0}, [3]uint32{'A', 'Z', 1}))
+	tests := []struct {
+		r    rune
+		want bool
+	}{
+		{'A', true},
+		{0x627, true},
+		{0x1F600, false}, // mapped to .notdef
+		{0x1F601, true},
+		{0x1F650, false},
+		{'a', false},
+	}
+	for _, tt := range tests {
+		if got := cov.contains(tt.r); got != tt.want {
+			t.Errorf("contains(%U) = %v, want %v", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestParseCmapFormat12Truncated(t *testing.T) {
+	// A header claiming about 4G groups in a table that holds one
+	sub := format12([3]uint32{'A', 'Z', 1})
+	binary.BigEndian.PutUint32(sub[12:], 0xFFFFFFFF)
+	cov := parseCmapFormat12(sub)
+	if len(cov) != 1 || cap(cov) > 1 {
+		t.Errorf("coverage %v with capacity %d, want the one group", cov, cap(cov))
+	}
+	if cov := parseCmapFormat12(sub[:20]); len(cov) != 0 {
+		t.Errorf("partial group parsed: %v", cov)
+	}
+}
@@ -0,0 +1,385 @@
+package fonts
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Face is a single font file (one family/weight/style combination)
+type Face struct {
+	ID       string // Unique name used when registering the face with gofpdf
+	Family   string // Family name from the font's name table
+	FullName string
+	Weight   int  // CSS weight, 100-900
+	Italic   bool // Italic or oblique
+	Path     string
+
+	dataOnce sync.Once
+	data     []byte
+	dataErr  error
+
+	cmapOnce sync.Once
+	cmap     coverage
+}
+
+// Data returns the raw font file, read from disk once and shared by all generators
+func (f *Face) Data() ([]byte, error) {
+	f.dataOnce.Do(func() {
+		f.data, f.dataErr = os.ReadFile(f.Path)
+	})
+	return f.data, f.dataErr
+}
+
+// Registry indexes font files by normalized family name
+type Registry struct {
+	mu       sync.RWMutex
+	families map[string][]*Face
+	count    int
+}
+
+// SystemFontDirs are scanned after the configured fonts directory.
+// The Docker image installs DejaVu and Liberation under /usr/share/fonts.
+var SystemFontDirs = []string{
+	"/usr/share/fonts",
+	"/usr/local/share/fonts",
+}
+
+// DefaultFamilies is the last step of the fallback chain, tried in order
+var DefaultFamilies = []string{
+	"DejaVu Sans",
+	"Liberation Sans",
+	"Arial",
+}
+
+// FallbackFamilies are tried, in order, for characters the layer's own font
+// doesn't have (Arabic in a Latin-only font, CJK, symbols, ...). Families that
+// aren't installed are ignored. Override with the FONT_FALLBACKS environment variable.
+var FallbackFamilies = []string{
+	"DejaVu Sans",
+	"Noto Sans",
+	"Noto Naskh Arabic",
+	"Noto Sans Arabic",
+	"Noto Sans Hebrew",
+	"Noto Sans SC",
+	"Noto Sans JP",
+	"Noto Sans KR",
+	"Noto Emoji",
+	"Symbola",
+	"Liberation Sans",
+}
+
+// familyAliases maps common web/desktop family names (and CSS generic
+// families) to metric-compatible fonts that ship in the Docker image
+var familyAliases = map[string][]string{
+	"arial":           {"Liberation Sans", "Arimo"},
+	"helvetica":       {"Liberation Sans", "Arimo"},
+	"helveticaneue":   {"Liberation Sans", "Arimo"},
+	"timesnewroman":   {"Liberation Serif", "Tinos"},
+	"times":           {"Liberation Serif", "Tinos"},
+	"couriernew":      {"Liberation Mono", "Cousine"},
+	"courier":         {"Liberation Mono", "Cousine"},
+	"verdana":         {"DejaVu Sans"},
+	"tahoma":          {"DejaVu Sans"},
+	"segoeui":         {"DejaVu Sans"},
+	"georgia":         {"DejaVu Serif", "Liberation Serif"},
+	"sansserif":       {"DejaVu Sans", "Liberation Sans"},
+	"serif":           {"DejaVu Serif", "Liberation Serif"},
+	"monospace":       {"DejaVu Sans Mono", "Liberation Mono"},
+	"systemui":        {"DejaVu Sans"},
+	"uisansserif":     {"DejaVu Sans"},
+	"cairo":           {"Noto Sans Arabic", "Noto Naskh Arabic", "DejaVu Sans"},
+	"notosansarabic":  {"Noto Naskh Arabic", "DejaVu Sans"},
+	"notonaskharabic": {"Noto Sans Arabic", "DejaVu Sans"},
+}
+
+var (
+	registry = &Registry{families: make(map[string][]*Face)}
+	once     sync.Once
+)
+
+// Init scans the fonts directory followed by the system font directories.
+// Faces found first win, so fonts dropped into fontsDir override system ones.
+func Init(fontsDir string) {
+	once.Do(func() {
+		dirs := []string{}
+		if fontsDir != "" {
+			dirs = append(dirs, filepath.SplitList(fontsDir)...)
+		}
+		dirs = append(dirs, SystemFontDirs...)
+
+		for _, dir := range dirs {
+			registry.scanDir(dir)
+		}
+	})
+}
+
+// scanDir walks dir recursively and indexes every usable .ttf/.otf file
+func (r *Registry) scanDir(dir string) {
+	if _, err := os.Stat(dir); err != nil {
+		return
+	}
+
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".ttf" && ext != ".otf" {
+			return nil
+		}
+
+		meta, err := readFontInfo(path)
+		if err != nil {
+			return nil
+		}
+		// gofpdf can only embed TrueType outlines; CFF-flavoured OpenType is skipped
+		if meta.cff {
+			fmt.Fprintf(os.Stderr, "Skipping font %s: CFF outlines are not supported\n", path)
+			return nil
+		}
+
+		r.add(&Face{
+			Family:   meta.family,
+			FullName: meta.fullName,
+			Weight:   meta.weight,
+			Italic:   meta.italic,
+			Path:     path,
+		})
+		return nil
+	})
+}
+
+// add indexes a face unless the same family/weight/style is already known
+func (r *Registry) add(face *Face) {
+	key := normalizeFamily(face.Family)
+	face.ID = fmt.Sprintf("%s-%d", key, face.Weight)
+	if face.Italic {
+		face.ID += "i"
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.families[key] {
+		if existing.ID == face.ID {
+			return
+		}
+	}
+	r.families[key] = append(r.families[key], face)
+	r.count++
+}
+
+// Resolve returns the best face for a CSS-style font-family list and weight.
+//
+// The fallback chain is:
+//  1. each family in the (comma separated) list, in order
+//  2. the aliases of each family (e.g. Arial -> Liberation Sans, sans-serif -> DejaVu Sans)
+//  3. DefaultFamilies
+//
+// Within a family the weight is matched with the CSS font-matching rules and
+// the italic flag is honoured when the family has an italic face.
+// Resolve returns nil if no font is indexed at all; callers then fall back to
+// the PDF core fonts.
+func Resolve(family string, weight int, italic bool) *Face {
+	return registry.Resolve(family, weight, italic)
+}
+
+// Resolve is the Registry method behind the package-level Resolve
+func (r *Registry) Resolve(family string, weight int, italic bool) *Face {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.count == 0 {
+		return nil
+	}
+
+	requested := splitFamilyList(family)
+	for _, name := range requested {
+		if faces, ok := r.families[normalizeFamily(name)]; ok {
+			return matchFace(faces, weight, italic)
+		}
+	}
+	for _, name := range requested {
+		for _, alias := range familyAliases[normalizeFamily(name)] {
+			if faces, ok := r.families[normalizeFamily(alias)]; ok {
+				return matchFace(faces, weight, italic)
+			}
+		}
+	}
+	for _, name := range DefaultFamilies {
+		if faces, ok := r.families[normalizeFamily(name)]; ok {
+			return matchFace(faces, weight, italic)
+		}
+	}
+
+	return nil
+}
+
+// Fallbacks returns one face per installed FallbackFamilies entry, matched to
+// the given weight and style, in fallback order
+func Fallbacks(weight int, italic bool) []*Face {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	var faces []*Face
+	for _, name := range FallbackFamilies {
+		if family, ok := registry.families[normalizeFamily(name)]; ok {
+			faces = append(faces, matchFace(family, weight, italic))
+		}
+	}
+	return faces
+}
+
+// Families lists the indexed family names, sorted
+func Families() []string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	names := make([]string, 0, len(registry.families))
+	for _, faces := range registry.families {
+		names = append(names, faces[0].Family)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Count returns the number of indexed faces
+func Count() int {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return registry.count
+}
+
+// matchFace picks a face from one family: style first, then the closest weight
+func matchFace(faces []*Face, weight int, italic bool) *Face {
+	candidates := make([]*Face, 0, len(faces))
+	for _, f := range faces {
+		if f.Italic == italic {
+			candidates = append(candidates, f)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = faces
+	}
+
+	best := candidates[0]
+	for _, f := range candidates[1:] {
+		if weightPreferred(f.Weight, best.Weight, weight) {
+			best = f
+		}
+	}
+	return best
+}
+
+// weightPreferred reports whether weight a is a better match than b for the
+// desired weight, following the CSS Fonts Level 4 matching algorithm:
+//   - 400-500: try desired..500 upwards, then below desired downwards, then above 500
+//   - below 400: lighter weights downwards first, then heavier upwards
+//   - above 500: heavier weights upwards first, then lighter downwards
+func weightPreferred(a, b, desired int) bool {
+	return weightRank(a, desired) < weightRank(b, desired)
+}
+
+func weightRank(w, desired int) int {
+	diff := w - desired
+	if diff < 0 {
+		diff = -diff
+	}
+
+	switch {
+	case desired >= 400 && desired <= 500:
+		if w >= desired && w <= 500 {
+			return diff
+		}
+		if w < desired {
+			return 1000 + diff
+		}
+		return 2000 + diff
+	case desired < 400:
+		if w <= desired {
+			return diff
+		}
+		return 1000 + diff
+	default:
+		if w >= desired {
+			return diff
+		}
+		return 1000 + diff
+	}
+}
+
+// ParseWeight converts a CSS font-weight value to a number (100-900).
+// Relative keywords have no parent weight to work from, so "bolder" is 700
+// and "lighter" is 300. Unknown values resolve to 400.
+func ParseWeight(weight string) int {
+	weight = strings.ToLower(strings.TrimSpace(weight))
+	switch weight {
+	case "", "normal", "regular", "book":
+		return 400
+	case "bold", "bolder":
+		return 700
+	case "thin", "hairline":
+		return 100
+	case "extralight", "extra-light", "ultralight", "ultra-light":
+		return 200
+	case "light", "lighter":
+		return 300
+	case "medium":
+		return 500
+	case "semibold", "semi-bold", "demibold", "demi-bold":
+		return 600
+	case "extrabold", "extra-bold", "ultrabold", "ultra-bold":
+		return 800
+	case "black", "heavy":
+		return 900
+	}
+
+	if n, err := strconv.Atoi(weight); err == nil {
+		if n < 1 {
+			return 1
+		}
+		if n > 1000 {
+			return 1000
+		}
+		return n
+	}
+
+	return 400
+}
+
+// IsItalic reports whether a CSS font-style value asks for a slanted face
+func IsItalic(style string) bool {
+	style = strings.ToLower(strings.TrimSpace(style))
+	return style == "italic" || strings.HasPrefix(style, "oblique")
+}
+
+// splitFamilyList splits a CSS font-family value ("Cairo, 'Segoe UI', sans-serif")
+func splitFamilyList(family string) []string {
+	var names []string
+	for _, part := range strings.Split(family, ",") {
+		part = strings.Trim(strings.TrimSpace(part), `"'`)
+		if part != "" {
+			names = append(names, part)
+		}
+	}
+	return names
+}
+
+// normalizeFamily lowercases and strips spaces, dashes and underscores so that
+// "DejaVu Sans", "dejavu-sans" and "DejaVuSans" all match
+func normalizeFamily(family string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(family) {
+		switch r {
+		case ' ', '-', '_', '"', '\'':
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,104 @@
+package fonts
+
+import "testing"
+
+func TestParseWeight(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 400},
+		{"normal", 400},
+		{" Bold ", 700},
+		{"bolder", 700},
+		{"lighter", 300},
+		{"semi-bold", 600},
+		{"ExtraBold", 800},
+		{"black", 900},
+		{"350", 350},
+		{"0", 1},
+		{"1200", 1000},
+		{"heavyish", 400},
+	}
+	for _, tt := range tests {
+		if got := ParseWeight(tt.in); got != tt.want {
+			t.Errorf("ParseWeight(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMatchFace(t *testing.T) {
+	faces := []*Face{
+		{ID: "light", Weight: 300},
+		{ID: "regular", Weight: 400},
+		{ID: "medium", Weight: 500},
+		{ID: "bold", Weight: 700},
+		{ID: "black", Weight: 900},
+		{ID: "italic", Weight: 400, Italic: true},
+	}
+	tests := []struct {
+		weight int
+		italic bool
+		want   string
+	}{
+		{400, false, "regular"},
+		{450, false, "medium"}, // 400-500 looks upwards to 500 first
+		{600, false, "bold"},   // above 500 looks heavier first
+		{800, false, "black"},
+		{350, false, "light"}, // below 400 looks lighter first
+		{200, false, "light"},
+		{100, false, "light"},
+		{700, true, "italic"}, // the only italic face beats any upright weight
+	}
+	for _, tt := range tests {
+		if got := matchFace(faces, tt.weight, tt.italic); got.ID != tt.want {
+			t.Errorf("matchFace(%d, italic=%v) = %s, want %s", tt.weight, tt.italic, got.ID, tt.want)
+		}
+	}
+
+	// Without italic faces the upright ones are used
+	upright := faces[:5]
+	if got := matchFace(upright, 700, true); got.ID != "bold" {
+		t.Errorf("italic bold without italic faces = %s, want bold", got.ID)
+	}
+}
+
+func TestWeightRank(t *testing.T) {
+	tests := []struct {
+		desired int
+		better  int
+		worse   int
+	}{
+		{400, 500, 300},
+		{500, 400, 600},
+		{300, 200, 400},
+		{600, 900, 500},
+		{400, 300, 600},
+	}
+	for _, tt := range tests {
+		if !weightPreferred(tt.better, tt.worse, tt.desired) {
+			t.Errorf("for %d, %d should beat %d", tt.desired, tt.better, tt.worse)
+		}
+	}
+}
+
+func TestNormalizeFamily(t *testing.T) {
+	for _, name := range []string{"DejaVu Sans", "dejavu-sans", "DejaVuSans", `"DejaVu_Sans"`, "'dejavu sans'"} {
+		if got := normalizeFamily(name); got != "dejavusans" {
+			t.Errorf("normalizeFamily(%q) = %q", name, got)
+		}
+	}
+}
+
+func TestSplitFamilyList(t *testing.T) {
+	got := splitFamilyList(`Cairo, 'Segoe UI', "Noto Sans", , sans-serif`)
+	want := []string{"Cairo", "Segoe UI", "Noto Sans", "sans-serif"}
+	if len(got) != len(want) {
+		t.Fatalf("splitFamilyList = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("splitFamilyList[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package fonts
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+// Minimal SFNT (TrueType/OpenType) reader. Only the tables needed to index a
+// font are read: name (family/subfamily), OS/2 (weight, italic) and head
+// (fallback style bits). Files are read with ReadAt so scanning a directory
+// of large CJK fonts doesn't pull every file into memory.
+
+const (
+	sfntTrueType = 0x00010000
+	sfntApple    = 0x74727565 // 'true'
+	sfntCFF      = 0x4F54544F // 'OTTO'
+	sfntTTC      = 0x74746366 // 'ttcf'
+)
+
+// Name table IDs
+const (
+	nameFamily            = 1
+	nameSubfamily         = 2
+	nameFull              = 4
+	nameTypographicFamily = 16
+)
+
+type tableRecord struct {
+	offset uint32
+	length uint32
+}
+
+// fontInfo is the metadata extracted from a font file
+type fontInfo struct {
+	family   string
+	fullName string
+	weight   int
+	italic   bool
+	cff      bool
+}
+
+// readFontInfo parses the name, OS/2 and head tables of a TrueType/OpenType file
+func readFontInfo(path string) (*fontInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 12)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("failed to read font header: %w", err)
+	}
+
+	version := binary.BigEndian.Uint32(header[0:4])
+	switch version {
+	case sfntTrueType, sfntApple, sfntCFF:
+	case sfntTTC:
+		return nil, fmt.Errorf("font collections are not supported")
+	default:
+		return nil, fmt.Errorf("not a TrueType/OpenType font")
+	}
+
+	numTables := int(binary.BigEndian.Uint16(header[4:6]))
+	records := make([]byte, numTables*16)
+	if _, err := f.ReadAt(records, 12); err != nil {
+		return nil, fmt.Errorf("failed to read table directory: %w", err)
+	}
+
+	tables := make(map[string]tableRecord, numTables)
+	for i := 0; i < numTables; i++ {
+		rec := records[i*16 : i*16+16]
+		tables[string(rec[0:4])] = tableRecord{
+			offset: binary.BigEndian.Uint32(rec[8:12]),
+			length: binary.BigEndian.Uint32(rec[12:16]),
+		}
+	}
+
+	info := &fontInfo{weight: 400, cff: version == sfntCFF}
+	if _, hasGlyf := tables["glyf"]; !hasGlyf {
+		info.cff = true
+	}
+
+	// head.macStyle is the fallback when OS/2 is missing
+	if rec, ok := tables["head"]; ok && rec.length >= 46 {
+		if data, err := readTable(f, rec); err == nil {
+			macStyle := binary.BigEndian.Uint16(data[44:46])
+			if macStyle&0x01 != 0 {
+				info.weight = 700
+			}
+			info.italic = macStyle&0x02 != 0
+		}
+	}
+
+	if rec, ok := tables["OS/2"]; ok && rec.length >= 64 {
+		if data, err := readTable(f, rec); err == nil {
+			weight := int(binary.BigEndian.Uint16(data[4:6]))
+			// A few old fonts use a 1-9 scale
+			if weight > 0 && weight < 10 {
+				weight *= 100
+			}
+			if weight > 0 {
+				info.weight = weight
+			}
+			fsSelection := binary.BigEndian.Uint16(data[62:64])
+			// bit 0 = italic, bit 9 = oblique
+			info.italic = fsSelection&0x0001 != 0 || fsSelection&0x0200 != 0
+		}
+	}
+
+	rec, ok := tables["name"]
+	if !ok {
+		return nil, fmt.Errorf("font has no name table")
+	}
+	data, err := readTable(f, rec)
+	if err != nil {
+		return nil, err
+	}
+	names := parseNameTable(data)
+
+	info.family = names[nameTypographicFamily]
+	if info.family == "" {
+		info.family = names[nameFamily]
+	}
+	if info.family == "" {
+		return nil, fmt.Errorf("font has no family name")
+	}
+	info.fullName = names[nameFull]
+	if info.fullName == "" {
+		info.fullName = strings.TrimSpace(info.family + " " + names[nameSubfamily])
+	}
+
+	return info, nil
+}
+
+func readTable(r io.ReaderAt, rec tableRecord) ([]byte, error) {
+	data := make([]byte, rec.length)
+	if _, err := r.ReadAt(data, int64(rec.offset)); err != nil {
+		return nil, fmt.Errorf("failed to read font table: %w", err)
+	}
+	return data, nil
+}
+
+// parseNameTable returns the best available string for each name ID.
+// Windows/Unicode English records win over Macintosh Roman ones.
+func parseNameTable(data []byte) map[int]string {
+	names := make(map[int]string)
+	if len(data) < 6 {
+		return names
+	}
+
+	count := int(binary.BigEndian.Uint16(data[2:4]))
+	storage := int(binary.BigEndian.Uint16(data[4:6]))
+	priority := make(map[int]int)
+
+	for i := 0; i < count; i++ {
+		base := 6 + i*12
+		if base+12 > len(data) {
+			break
+		}
+		platformID := binary.BigEndian.Uint16(data[base:])
+		encodingID := binary.BigEndian.Uint16(data[base+2:])
+		languageID := binary.BigEndian.Uint16(data[base+4:])
+		nameID := int(binary.BigEndian.Uint16(data[base+6:]))
+		length := int(binary.BigEndian.Uint16(data[base+8:]))
+		offset := int(binary.BigEndian.Uint16(data[base+10:]))
+
+		start := storage + offset
+		if start+length > len(data) {
+			continue
+		}
+		raw := data[start : start+length]
+
+		var value string
+		var rank int
+		switch {
+		case platformID == 3 && (encodingID == 1 || encodingID == 10):
+			value = decodeUTF16BE(raw)
+			rank = 2
+			if languageID == 0x0409 {
+				rank = 3
+			}
+		case platformID == 0:
+			value = decodeUTF16BE(raw)
+			rank = 2
+		case platformID == 1 && encodingID == 0:
+			value = string(raw) // Mac Roman; names are effectively ASCII
+			rank = 1
+		default:
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		if value != "" && rank > priority[nameID] {
+			names[nameID] = value
+			priority[nameID] = rank
+		}
+	}
+
+	return names
+}
+
+func decodeUTF16BE(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.BigEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(u))
+}
@@ -0,0 +1,206 @@
+package generator
+
+// Arabic contextual shaping.
+//
+// gofpdf writes glyphs exactly as they appear in the string, so Arabic letters
+// have to be converted to their positional presentation forms (isolated,
+// final, initial, medial) before they reach the PDF. Fonts that ship Arabic
+// support (Arial, DejaVu Sans, Noto Naskh, ...) all carry the Presentation
+// Forms-A/B blocks used here.
+
+// arabicForms holds the presentation forms of a single Arabic letter.
+// A zero entry means the letter has no such form.
+type arabicForms struct {
+	isolated rune
+	final    rune
+	initial  rune
+	medial   rune
+}
+
+// dualJoining reports whether the letter connects on both sides
+func (f arabicForms) dualJoining() bool {
+	return f.initial != 0 && f.medial != 0
+}
+
+// fourForms builds the forms for a dual-joining letter laid out consecutively
+func fourForms(base rune) arabicForms {
+	return arabicForms{isolated: base, final: base + 1, initial: base + 2, medial: base + 3}
+}
+
+// twoForms builds the forms for a right-joining letter (connects to the previous letter only)
+func twoForms(base rune) arabicForms {
+	return arabicForms{isolated: base, final: base + 1}
+}
+
+var arabicFormTable = map[rune]arabicForms{
+	0x0621: {isolated: 0xFE80}, // HAMZA
+	0x0622: twoForms(0xFE81),   // ALEF WITH MADDA ABOVE
+	0x0623: twoForms(0xFE83),   // ALEF WITH HAMZA ABOVE
+	0x0624: twoForms(0xFE85),   // WAW WITH HAMZA ABOVE
+	0x0625: twoForms(0xFE87),   // ALEF WITH HAMZA BELOW
+	0x0626: fourForms(0xFE89),  // YEH WITH HAMZA ABOVE
+	0x0627: twoForms(0xFE8D),   // ALEF
+	0x0628: fourForms(0xFE8F),  // BEH
+	0x0629: twoForms(0xFE93),   // TEH MARBUTA
+	0x062A: fourForms(0xFE95),  // TEH
+	0x062B: fourForms(0xFE99),  // THEH
+	0x062C: fourForms(0xFE9D),  // JEEM
+	0x062D: fourForms(0xFEA1),  // HAH
+	0x062E: fourForms(0xFEA5),  // KHAH
+	0x062F: twoForms(0xFEA9),   // DAL
+	0x0630: twoForms(0xFEAB),   // THAL
+	0x0631: twoForms(0xFEAD),   // REH
+	0x0632: twoForms(0xFEAF),   // ZAIN
+	0x0633: fourForms(0xFEB1),  // SEEN
+	0x0634: fourForms(0xFEB5),  // SHEEN
+	0x0635: fourForms(0xFEB9),  // SAD
+	0x0636: fourForms(0xFEBD),  // DAD
+	0x0637: fourForms(0xFEC1),  // TAH
+	0x0638: fourForms(0xFEC5),  // ZAH
+	0x0639: fourForms(0xFEC9),  // AIN
+	0x063A: fourForms(0xFECD),  // GHAIN
+	0x0641: fourForms(0xFED1),  // FEH
+	0x0642: fourForms(0xFED5),  // QAF
+	0x0643: fourForms(0xFED9),  // KAF
+	0x0644: fourForms(0xFEDD),  // LAM
+	0x0645: fourForms(0xFEE1),  // MEEM
+	0x0646: fourForms(0xFEE5),  // NOON
+	0x0647: fourForms(0xFEE9),  // HEH
+	0x0648: twoForms(0xFEED),   // WAW
+	0x0649: twoForms(0xFEEF),   // ALEF MAKSURA
+	0x064A: fourForms(0xFEF1),  // YEH
+	0x067E: fourForms(0xFB56),  // PEH (Persian)
+	0x0686: fourForms(0xFB7A),  // TCHEH (Persian)
+	0x0698: twoForms(0xFB8A),   // JEH (Persian)
+	0x06A9: fourForms(0xFB8E),  // KEHEH (Persian)
+	0x06AF: fourForms(0xFB92),  // GAF (Persian)
+	0x06CC: fourForms(0xFBFC),  // FARSI YEH
+}
+
+// lamAlefLigatures maps the alef variant following a LAM to its ligature
+// (isolated form; the final form is the next code point).
+var lamAlefLigatures = map[rune]rune{
+	0x0622: 0xFEF5,
+	0x0623: 0xFEF7,
+	0x0625: 0xFEF9,
+	0x0627: 0xFEFB,
+}
+
+const (
+	arabicLam     = 0x0644
+	arabicTatweel = 0x0640
+)
+
+// isArabicTransparent reports whether r is a combining mark that is skipped when
+// deciding how its neighbours join (harakat, shadda, superscript alef, ...)
+func isArabicTransparent(r rune) bool {
+	return (r >= 0x0610 && r <= 0x061A) || (r >= 0x064B && r <= 0x065F) || r == 0x0670 ||
+		(r >= 0x06D6 && r <= 0x06DC) || (r >= 0x06DF && r <= 0x06E4) || r == 0x06E7 || r == 0x06E8 ||
+		(r >= 0x06EA && r <= 0x06ED)
+}
+
+// joinsWithNext reports whether r connects to the letter after it (in logical order)
+func joinsWithNext(r rune) bool {
+	if r == arabicTatweel {
+		return true
+	}
+	forms, ok := arabicFormTable[r]
+	return ok && forms.dualJoining()
+}
+
+// joinsWithPrev reports whether r connects to the letter before it (in logical order)
+func joinsWithPrev(r rune) bool {
+	if r == arabicTatweel {
+		return true
+	}
+	forms, ok := arabicFormTable[r]
+	return ok && forms.final != 0
+}
+
+// containsArabic reports whether s has any character from the Arabic blocks
+func containsArabic(s string) bool {
+	for _, r := range s {
+		if isArabicRune(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isArabicRune(r rune) bool {
+	return (r >= 0x0600 && r <= 0x06FF) || (r >= 0x0750 && r <= 0x077F) ||
+		(r >= 0xFB50 && r <= 0xFDFF) || (r >= 0xFE70 && r <= 0xFEFF)
+}
+
+// shapeArabic replaces Arabic letters with their contextual presentation forms
+// and merges LAM + ALEF pairs into ligatures. The result stays in logical order;
+// visual reordering is done separately by reorderBidi.
+func shapeArabic(text string) string {
+	if !containsArabic(text) {
+		return text
+	}
+
+	runes := []rune(text)
+	out := make([]rune, 0, len(runes))
+
+	// prevJoining/nextJoining skip transparent marks so harakat don't break joins
+	prevJoining := func(i int) (rune, bool) {
+		for j := i - 1; j >= 0; j-- {
+			if !isArabicTransparent(runes[j]) {
+				return runes[j], true
+			}
+		}
+		return 0, false
+	}
+	nextJoining := func(i int) (int, bool) {
+		for j := i + 1; j < len(runes); j++ {
+			if !isArabicTransparent(runes[j]) {
+				return j, true
+			}
+		}
+		return 0, false
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		forms, ok := arabicFormTable[r]
+		if !ok {
+			out = append(out, r)
+			continue
+		}
+
+		prev, hasPrev := prevJoining(i)
+		joinPrev := hasPrev && joinsWithNext(prev) && joinsWithPrev(r)
+
+		next, hasNext := nextJoining(i)
+
+		// LAM followed by an ALEF variant becomes a single ligature glyph
+		if r == arabicLam && hasNext {
+			if lig, isLig := lamAlefLigatures[runes[next]]; isLig {
+				if joinPrev {
+					lig++
+				}
+				out = append(out, lig)
+				// Keep any marks that sat between the two letters
+				out = append(out, runes[i+1:next]...)
+				i = next
+				continue
+			}
+		}
+
+		joinNext := hasNext && joinsWithNext(r) && joinsWithPrev(runes[next])
+
+		shaped := forms.isolated
+		switch {
+		case joinPrev && joinNext && forms.medial != 0:
+			shaped = forms.medial
+		case joinPrev && forms.final != 0:
+			shaped = forms.final
+		case joinNext && forms.initial != 0:
+			shaped = forms.initial
+		}
+		out = append(out, shaped)
+	}
+
+	return string(out)
+}
@@ -0,0 +1,278 @@
+package generator
+
+import (
+	"badge-service/internal/fonts"
+	"badge-service/internal/models"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/aztec"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/datamatrix"
+	"github.com/boombuler/barcode/ean"
+	"github.com/boombuler/barcode/pdf417"
+)
+
+// Barcode layers: linear (Code 128, EAN) and 2D (PDF417, DataMatrix, Aztec)
+// symbols, drawn as vector rectangles so they stay sharp at any print
+// resolution. Bars use style.color and sit on the layer's backgroundColor.
+
+const (
+	defaultPDF417Level  = 2   // PDF417 security level when errorCorrection is not set
+	defaultAztecPercent = 23  // Aztec error correction when errorCorrection is not set
+	maxBarcodeTextShare = 0.4 // largest share of the layer height the text band takes
+)
+
+// symbology describes how a barcode type is encoded and laid out
+type symbology struct {
+	linear     bool
+	quietLeft  float64 // minimum quiet zone in modules
+	quietRight float64
+	rowHeight  float64 // height of one encoded row of the image in modules (2D symbols)
+	encode     func(content string, errorCorrection int) (barcode.Barcode, error)
+}
+
+var symbologies = map[string]symbology{
+	"code128": {linear: true, quietLeft: 10, quietRight: 10, encode: func(content string, _ int) (barcode.Barcode, error) {
+		return code128.Encode(content)
+	}},
+	"ean13": {linear: true, quietLeft: 11, quietRight: 7, encode: encodeEAN(12)},
+	"ean8":  {linear: true, quietLeft: 7, quietRight: 7, encode: encodeEAN(7)},
+	// The PDF417 image has two pixel rows per symbol row; 1.5 makes rows
+	// three modules high, the minimum the standard allows
+	"pdf417": {quietLeft: 2, quietRight: 2, rowHeight: 1.5, encode: func(content string, level int) (barcode.Barcode, error) {
+		if level <= 0 {
+			level = defaultPDF417Level
+		}
+		if level > 8 {
+			return nil, fmt.Errorf("PDF417 security level must be 1-8, got %d", level)
+		}
+		return pdf417.Encode(content, byte(level))
+	}},
+	"datamatrix": {quietLeft: 1, quietRight: 1, rowHeight: 1, encode: func(content string, _ int) (barcode.Barcode, error) {
+		return datamatrix.Encode(content)
+	}},
+	"aztec": {rowHeight: 1, encode: func(content string, percent int) (barcode.Barcode, error) {
+		if percent <= 0 {
+			percent = defaultAztecPercent
+		}
+		return aztec.Encode([]byte(content), percent, 0)
+	}},
+}
+
+// encodeEAN encodes an EAN code of the given length without its check digit.
+// The check digit is added when missing and verified when present.
+func encodeEAN(digits int) func(string, int) (barcode.Barcode, error) {
+	return func(content string, _ int) (barcode.Barcode, error) {
+		content = strings.ReplaceAll(content, " ", "")
+		if len(content) != digits && len(content) != digits+1 {
+			return nil, fmt.Errorf("EAN-%d needs %d or %d digits, got %q", digits+1, digits, digits+1, content)
+		}
+		for _, r := range content {
+			if r < '0' || r > '9' {
+				return nil, fmt.Errorf("EAN-%d takes digits only, got %q", digits+1, content)
+			}
+		}
+		return ean.Encode(content)
+	}
+}
+
+// normalizeSymbology maps "Code-128", "EAN_13", "Data Matrix" and the like to
+// the keys of symbologies
+func normalizeSymbology(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "code128"
+	}
+	return strings.NewReplacer("-", "", "_", "", " ", "").Replace(name)
+}
+
+// renderBarcode draws a barcode layer: the symbol with its quiet zones fitted
+// into the layer box, and the human-readable text in a band above or below it
+func (g *PDFGenerator) renderBarcode(layer models.Layer, x, y float64) error {
+	var opts models.Barcode
+	if layer.Barcode != nil {
+		opts = *layer.Barcode
+	}
+	name := normalizeSymbology(opts.Symbology)
+	sym, ok := symbologies[name]
+	if !ok {
+		return fmt.Errorf("unknown barcode symbology %q", opts.Symbology)
+	}
+
+	content := g.codeContent(layer)
+	if content == "" {
+		return fmt.Errorf("barcode content is empty")
+	}
+	code, err := sym.encode(content, opts.ErrorCorrection)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s barcode: %w", name, err)
+	}
+
+	r, gr, b, colorAlpha, ok := foregroundColor(layer)
+	if !ok {
+		return nil
+	}
+	g.setAlpha(g.alpha * colorAlpha)
+	defer g.setAlpha(g.alpha)
+
+	// The encoded content includes any check digit the encoder added
+	text := code.Content()
+	if opts.Text != "" {
+		text = g.resolvePlaceholders(opts.Text)
+	}
+	showText := sym.linear
+	if opts.ShowText != nil {
+		showText = *opts.ShowText
+	}
+
+	symbolY, symbolH := y, layer.Size.Height
+	if showText && strings.TrimSpace(text) != "" {
+		tl, bandH := g.barcodeTextLayout(layer, text, [3]int{r, gr, b})
+		textY := y + layer.Size.Height - bandH
+		if strings.EqualFold(opts.TextPosition, "top") {
+			textY = y
+			symbolY = y + bandH
+		}
+		symbolH -= bandH
+
+		g.pdf.SetTextColor(r, gr, b)
+		g.pdf.SetXY(x, textY)
+		g.cellText(tl, layer.Size.Width, bandH, text)
+	}
+
+	quietLeft, quietRight := sym.quietLeft, sym.quietRight
+	if opts.QuietZone != nil {
+		quietLeft = math.Max(0, *opts.QuietZone)
+		quietRight = quietLeft
+	}
+
+	g.pdf.SetFillColor(r, gr, b)
+	bounds := code.Bounds()
+	cols := float64(bounds.Dx())
+	if sym.linear {
+		// Bars stretch to the full height; the modules fill the width
+		module := layer.Size.Width / (cols + quietLeft + quietRight)
+		g.fillModules(barcodeBitmap(code, true), x+quietLeft*module, symbolY, module, symbolH)
+		return nil
+	}
+
+	// 2D symbols keep square modules, centered in the box with the quiet zone all round
+	rows := float64(bounds.Dy()) * sym.rowHeight
+	module := math.Min(layer.Size.Width/(cols+2*quietLeft), symbolH/(rows+2*quietLeft))
+	originX := x + (layer.Size.Width-cols*module)/2
+	originY := symbolY + (symbolH-rows*module)/2
+	g.fillModules(barcodeBitmap(code, false), originX, originY, module, module*sym.rowHeight)
+	return nil
+}
+
+// barcodeTextLayout returns the text layout for a barcode's human-readable
+// text and the height of the band it takes. Without a fontSize the text is
+// sized from the layer height, and it shrinks to fit the width.
+func (g *PDFGenerator) barcodeTextLayout(layer models.Layer, text string, rgb [3]int) (textLayout, float64) {
+	fc := g.buildFontChain(layer.Style.FontFamily, layer.Style.FontWeight, fonts.IsItalic(layer.Style.FontStyle))
+
+	var size float64
+	if layer.Style.FontSize > 0 {
+		size = g.layerFontSize(layer)
+	} else {
+		size = math.Max(5, math.Min(10, layer.Size.Height*0.15*72/25.4))
+	}
+	if width := g.measureText(fc, text, size); width > layer.Size.Width && width > 0 {
+		size *= layer.Size.Width / width
+	}
+
+	// Points to mm, with line spacing
+	bandH := math.Min(size*25.4/72*textLineHeight, layer.Size.Height*maxBarcodeTextShare)
+	g.useFont(fc.primary(), size)
+
+	return textLayout{
+		fonts: fc,
+		size:  size,
+		align: "CM",
+		color: rgb,
+	}, bandH
+}
+
+// barcodeBitmap returns the dark modules of a barcode image as
+// bitmap[row][col]. Linear barcodes have a single row.
+func barcodeBitmap(code barcode.Barcode, linear bool) [][]bool {
+	bounds := code.Bounds()
+	rows := bounds.Dy()
+	if linear {
+		rows = 1
+	}
+
+	bitmap := make([][]bool, rows)
+	for row := range bitmap {
+		bitmap[row] = make([]bool, bounds.Dx())
+		for col := range bitmap[row] {
+			bitmap[row][col] = isDark(code.At(bounds.Min.X+col, bounds.Min.Y+row))
+		}
+	}
+	return bitmap
+}
+
+// isDark reports whether a barcode image pixel is a bar or dark module
+func isDark(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r+g+b < 3*0x8000
+}
+
+// fillModules fills the dark modules of bitmap[row][col] as one path with the
+// current fill color. Each module is module wide and rowH high; runs of dark
+// modules in a row, and rows that repeat the row above, become single
+// rectangles so no seams show between them.
+func (g *PDFGenerator) fillModules(bitmap [][]bool, x, y, module, rowH float64) {
+	drawn := false
+	for row := 0; row < len(bitmap); {
+		height := 1
+		for row+height < len(bitmap) && sameRow(bitmap[row], bitmap[row+height]) {
+			height++
+		}
+
+		top := y + float64(row)*rowH
+		bottom := top + float64(height)*rowH
+		cells := bitmap[row]
+		for col := 0; col < len(cells); {
+			if !cells[col] {
+				col++
+				continue
+			}
+			start := col
+			for col < len(cells) && cells[col] {
+				col++
+			}
+
+			left := x + float64(start)*module
+			right := x + float64(col)*module
+			g.pdf.MoveTo(left, top)
+			g.pdf.LineTo(right, top)
+			g.pdf.LineTo(right, bottom)
+			g.pdf.LineTo(left, bottom)
+			g.pdf.ClosePath()
+			drawn = true
+		}
+		row += height
+	}
+
+	if drawn {
+		g.pdf.DrawPath("F")
+	}
+}
+
+// sameRow reports whether two bitmap rows are identical
+func sameRow(a, b []bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/boombuler/barcode/datamatrix"
+)
+
+func TestNormalizeSymbology(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"", "code128"},
+		{"Code-128", "code128"},
+		{"EAN_13", "ean13"},
+		{" ean 8 ", "ean8"},
+		{"Data Matrix", "datamatrix"},
+		{"PDF417", "pdf417"},
+		{"qr", "qr"},
+	}
+	for _, tt := range tests {
+		if got := normalizeSymbology(tt.name); got != tt.want {
+			t.Errorf("normalizeSymbology(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeEAN(t *testing.T) {
+	tests := []struct {
+		symbology string
+		content   string
+		want      string // encoded content with its check digit, empty for an error
+	}{
+		{"ean13", "400638133393", "4006381333931"},
+		{"ean13", "4006381333931", "4006381333931"},
+		{"ean13", "4 006381 333931", "4006381333931"},
+		{"ean13", "4006381333932", ""},
+		{"ean13", "40063813339", ""},
+		{"ean13", "40063813339A", ""},
+		{"ean8", "9638507", "96385074"},
+		{"ean8", "96385074", "96385074"},
+		{"ean8", "96385075", ""},
+	}
+	for _, tt := range tests {
+		code, err := symbologies[tt.symbology].encode(tt.content, 0)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("%s %q: encoded as %q, want an error", tt.symbology, tt.content, code.Content())
+		case tt.want != "" && err != nil:
+			t.Errorf("%s %q: %v", tt.symbology, tt.content, err)
+		case tt.want != "" && code.Content() != tt.want:
+			t.Errorf("%s %q: content %q, want %q", tt.symbology, tt.content, code.Content(), tt.want)
+		}
+	}
+}
+
+func TestBarcodeBitmap(t *testing.T) {
+	code, err := symbologies["ean13"].encode("400638133393", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bitmap := barcodeBitmap(code, true)
+	if len(bitmap) != 1 || len(bitmap[0]) != 95 {
+		t.Fatalf("EAN-13 bitmap is %d rows of %d modules, want 1 of 95", len(bitmap), len(bitmap[0]))
+	}
+	// Start, center and end guards
+	guards := map[int]bool{0: true, 1: false, 2: true, 45: false, 46: true, 47: false, 48: true, 49: false, 92: true, 93: false, 94: true}
+	for col, dark := range guards {
+		if bitmap[0][col] != dark {
+			t.Errorf("EAN-13 module %d dark = %v, want %v", col, bitmap[0][col], dark)
+		}
+	}
+
+	matrix, err := datamatrix.Encode("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bitmap = barcodeBitmap(matrix, false)
+	n := len(bitmap)
+	if n != matrix.Bounds().Dy() || len(bitmap[0]) != matrix.Bounds().Dx() {
+		t.Fatalf("DataMatrix bitmap is %d x %d, image is %v", n, len(bitmap[0]), matrix.Bounds())
+	}
+	// The finder pattern: a solid left column and bottom row
+	for i := 0; i < n; i++ {
+		if !bitmap[i][0] || !bitmap[n-1][i] {
+			t.Fatalf("DataMatrix finder pattern broken at %d", i)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package generator
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Bidirectional text reordering.
+//
+// This is a compact implementation of the parts of the Unicode Bidirectional
+// Algorithm (UAX #9) that matter for badge text: a single paragraph without
+// explicit embeddings, mixing Arabic/Hebrew with Latin words and numbers.
+// Text is kept in logical order for measuring and line breaking, and each
+// finished line is converted to visual (left-to-right) order just before it
+// is drawn.
+
+type bidiClass int
+
+const (
+	bidiL   bidiClass = iota // strong left-to-right
+	bidiR                    // strong right-to-left (Hebrew)
+	bidiAL                   // Arabic letter
+	bidiEN                   // European number
+	bidiES                   // European separator (+ -)
+	bidiET                   // European terminator (# $ % ...)
+	bidiAN                   // Arabic number
+	bidiCS                   // common separator (, . : /)
+	bidiNSM                  // non-spacing mark
+	bidiWS                   // whitespace
+	bidiON                   // other neutral
+)
+
+// Directions for the paragraph base level
+const (
+	directionAuto = ""
+	directionLTR  = "ltr"
+	directionRTL  = "rtl"
+)
+
+var rtlLanguages = map[string]bool{
+	"ar": true, // Arabic
+	"fa": true, // Persian
+	"he": true, // Hebrew
+	"iw": true, // Hebrew (legacy code)
+	"ur": true, // Urdu
+	"ps": true, // Pashto
+	"ku": true, // Kurdish (Sorani)
+	"yi": true, // Yiddish
+	"dv": true, // Dhivehi
+}
+
+// isRTLLanguage reports whether a language tag such as "ar" or "ar-EG" is written right-to-left
+func isRTLLanguage(lang string) bool {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if idx := strings.IndexAny(lang, "-_"); idx != -1 {
+		lang = lang[:idx]
+	}
+	return rtlLanguages[lang]
+}
+
+func classifyBidi(r rune) bidiClass {
+	switch {
+	case r >= '0' && r <= '9':
+		return bidiEN
+	case r == '+' || r == '-':
+		return bidiES
+	case r == '#' || r == '$' || r == '%' || r == 0x00B0 || (r >= 0x20A0 && r <= 0x20CF):
+		return bidiET
+	case r == ',' || r == '.' || r == ':' || r == '/' || r == 0x00A0:
+		return bidiCS
+	case (r >= 0x0660 && r <= 0x0669) || r == 0x066B || r == 0x066C:
+		return bidiAN
+	case r >= 0x06F0 && r <= 0x06F9:
+		// Extended Arabic-Indic digits behave like European numbers
+		return bidiEN
+	case isArabicTransparent(r) || unicode.Is(unicode.Mn, r):
+		return bidiNSM
+	case r >= 0x0590 && r <= 0x05FF, r >= 0xFB1D && r <= 0xFB4F:
+		return bidiR
+	case isArabicRune(r), r >= 0x0700 && r <= 0x08FF:
+		return bidiAL
+	case unicode.IsSpace(r):
+		return bidiWS
+	case unicode.IsLetter(r):
+		return bidiL
+	default:
+		return bidiON
+	}
+}
+
+// containsRTL reports whether s has any strong right-to-left character
+func containsRTL(s string) bool {
+	for _, r := range s {
+		if c := classifyBidi(r); c == bidiR || c == bidiAL {
+			return true
+		}
+	}
+	return false
+}
+
+// firstStrongIsRTL applies rule P2/P3: the first strong character decides the paragraph direction
+func firstStrongIsRTL(s string) bool {
+	for _, r := range s {
+		switch classifyBidi(r) {
+		case bidiL:
+			return false
+		case bidiR, bidiAL:
+			return true
+		}
+	}
+	return false
+}
+
+// paragraphIsRTL resolves the base direction of a paragraph given an explicit direction or auto
+func paragraphIsRTL(text, direction string) bool {
+	switch direction {
+	case directionRTL:
+		return true
+	case directionLTR:
+		return false
+	default:
+		return firstStrongIsRTL(text)
+	}
+}
+
+var bidiMirrors = map[rune]rune{
+	'(': ')', ')': '(',
+	'[': ']', ']': '[',
+	'{': '}', '}': '{',
+	'<': '>', '>': '<',
+	'«': '»', '»': '«',
+}
+
+// reorderBidi converts one line of logical-order text to visual order.
+// rtlBase selects the paragraph embedding level (0 = LTR, 1 = RTL).
+func reorderBidi(line string, rtlBase bool) string {
+	runes := []rune(line)
+	if len(runes) == 0 || (!rtlBase && !containsRTL(line)) {
+		return line
+	}
+
+	n := len(runes)
+	types := make([]bidiClass, n)
+	for i, r := range runes {
+		types[i] = classifyBidi(r)
+	}
+
+	baseLevel := 0
+	sos := bidiL
+	if rtlBase {
+		baseLevel = 1
+		sos = bidiR
+	}
+
+	// W1: non-spacing marks take the type of the preceding character
+	for i := range types {
+		if types[i] == bidiNSM {
+			if i == 0 {
+				types[i] = sos
+			} else {
+				types[i] = types[i-1]
+			}
+		}
+	}
+
+	// W2: European numbers after an Arabic letter become Arabic numbers
+	lastStrong := sos
+	for i, t := range types {
+		switch t {
+		case bidiL, bidiR, bidiAL:
+			lastStrong = t
+		case bidiEN:
+			if lastStrong == bidiAL {
+				types[i] = bidiAN
+			}
+		}
+	}
+
+	// W3: Arabic letters are treated as R from here on
+	for i, t := range types {
+		if t == bidiAL {
+			types[i] = bidiR
+		}
+	}
+
+	// W4: a single separator between two numbers of the same kind joins them
+	for i := 1; i < n-1; i++ {
+		switch types[i] {
+		case bidiES:
+			if types[i-1] == bidiEN && types[i+1] == bidiEN {
+				types[i] = bidiEN
+			}
+		case bidiCS:
+			if types[i-1] == bidiEN && types[i+1] == bidiEN {
+				types[i] = bidiEN
+			} else if types[i-1] == bidiAN && types[i+1] == bidiAN {
+				types[i] = bidiAN
+			}
+		}
+	}
+
+	// W5: terminators adjacent to European numbers become European numbers
+	for i := 0; i < n; i++ {
+		if types[i] != bidiET {
+			continue
+		}
+		end := i
+		for end < n && types[end] == bidiET {
+			end++
+		}
+		if (i > 0 && types[i-1] == bidiEN) || (end < n && types[end] == bidiEN) {
+			for j := i; j < end; j++ {
+				types[j] = bidiEN
+			}
+		}
+		i = end - 1
+	}
+
+	// W6: remaining separators and terminators are neutral
+	for i, t := range types {
+		if t == bidiES || t == bidiET || t == bidiCS {
+			types[i] = bidiON
+		}
+	}
+
+	// W7: European numbers preceded by a strong L become L
+	lastStrong = sos
+	for i, t := range types {
+		switch t {
+		case bidiL, bidiR:
+			lastStrong = t
+		case bidiEN:
+			if lastStrong == bidiL {
+				types[i] = bidiL
+			}
+		}
+	}
+
+	// N1/N2: neutrals between two characters of the same direction take that
+	// direction, otherwise they take the embedding direction
+	strongDir := func(t bidiClass) bidiClass {
+		if t == bidiEN || t == bidiAN {
+			return bidiR
+		}
+		return t
+	}
+	embedding := bidiL
+	if rtlBase {
+		embedding = bidiR
+	}
+	for i := 0; i < n; i++ {
+		if types[i] != bidiWS && types[i] != bidiON {
+			continue
+		}
+		end := i
+		for end < n && (types[end] == bidiWS || types[end] == bidiON) {
+			end++
+		}
+		before := sos
+		if i > 0 {
+			before = strongDir(types[i-1])
+		}
+		after := sos
+		if end < n {
+			after = strongDir(types[end])
+		}
+		resolved := embedding
+		if before == after {
+			resolved = before
+		}
+		for j := i; j < end; j++ {
+			types[j] = resolved
+		}
+		i = end - 1
+	}
+
+	// I1/I2: resolve implicit levels
+	levels := make([]int, n)
+	for i, t := range types {
+		level := baseLevel
+		if baseLevel%2 == 0 {
+			switch t {
+			case bidiR:
+				level++
+			case bidiAN, bidiEN:
+				level += 2
+			}
+		} else {
+			switch t {
+			case bidiL, bidiEN, bidiAN:
+				level++
+			}
+		}
+		levels[i] = level
+	}
+
+	// L1: trailing whitespace goes back to the paragraph level
+	for i := n - 1; i >= 0 && unicode.IsSpace(runes[i]); i-- {
+		levels[i] = baseLevel
+	}
+
+	// Mirror paired punctuation that ends up on a right-to-left level
+	for i, r := range runes {
+		if levels[i]%2 == 1 {
+			if m, ok := bidiMirrors[r]; ok {
+				runes[i] = m
+			}
+		}
+	}
+
+	// L2: reverse every run at each level from the highest down to the lowest odd level
+	maxLevel, minOdd := 0, -1
+	for _, l := range levels {
+		if l > maxLevel {
+			maxLevel = l
+		}
+		if l%2 == 1 && (minOdd == -1 || l < minOdd) {
+			minOdd = l
+		}
+	}
+	if minOdd == -1 {
+		return string(runes)
+	}
+	for level := maxLevel; level >= minOdd; level-- {
+		for i := 0; i < n; i++ {
+			if levels[i] < level {
+				continue
+			}
+			end := i
+			for end < n && levels[end] >= level {
+				end++
+			}
+			for a, b := i, end-1; a < b; a, b = a+1, b-1 {
+				runes[a], runes[b] = runes[b], runes[a]
+				levels[a], levels[b] = levels[b], levels[a]
+			}
+			i = end - 1
+		}
+	}
+
+	return string(runes)
+}
@@ -0,0 +1,69 @@
+package generator
+
+import "testing"
+
+func TestShapeArabic(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"latin untouched", "Badge 42", "Badge 42"},
+		{"isolated letter", "ب", "ﺏ"},
+		{"initial and final", "بب", "ﺑﺐ"},
+		{"initial, medial and final", "ببب", "ﺑﺒﺐ"},
+		{"right-joining letter breaks the join", "دب", "ﺩﺏ"},
+		{"harakat are transparent", "بَب", "ﺑَﺐ"},
+		{"tatweel joins", "ـب", "ـﺐ"},
+		{"lam alef ligature", "لا", "ﻻ"},
+		// سلام: initial seen, final lam-alef, isolated meem
+		{"joined lam alef ligature", "سلام", "ﺳﻼﻡ"},
+	}
+	for _, tt := range tests {
+		if got := shapeArabic(tt.in); got != tt.want {
+			t.Errorf("%s: shapeArabic(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReorderBidi(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		rtlBase bool
+		want    string
+	}{
+		{"latin", "Hello world", false, "Hello world"},
+		{"hebrew", "שלום", true, "םולש"},
+		{"hebrew word in latin line", "Hi שלום there", false, "Hi םולש there"},
+		{"latin word in hebrew line", "שלום Bob", true, "Bob םולש"},
+		{"numbers keep their order", "חדר 101", true, "101 רדח"},
+		{"brackets are mirrored", "(שלום)", true, "(םולש)"},
+		{"arabic with european digits", "ﺳ 12", true, "12 ﺳ"},
+	}
+	for _, tt := range tests {
+		if got := reorderBidi(tt.in, tt.rtlBase); got != tt.want {
+			t.Errorf("%s: reorderBidi(%q, %v) = %q, want %q", tt.name, tt.in, tt.rtlBase, got, tt.want)
+		}
+	}
+}
+
+func TestParagraphIsRTL(t *testing.T) {
+	tests := []struct {
+		text      string
+		direction string
+		want      bool
+	}{
+		{"Hello", "", false},
+		{"שלום Bob", "", true},
+		{"123 שלום", "auto", true},
+		{"Bob שלום", "auto", false},
+		{"Bob", directionRTL, true},
+		{"שלום", directionLTR, false},
+	}
+	for _, tt := range tests {
+		if got := paragraphIsRTL(tt.text, tt.direction); got != tt.want {
+			t.Errorf("paragraphIsRTL(%q, %q) = %v, want %v", tt.text, tt.direction, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package generator
+
+import (
+	"badge-service/internal/models"
+	"math"
+	"sort"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Print production: bleed, page boxes and printer's marks.
+//
+// With settings.bleed or marks the page grows around the badge. The badge
+// itself is the TrimBox, the bleed around it the BleedBox, and the safe area
+// inside settings.margins the ArtBox. Image and shape layers that touch a trim
+// edge are extended into the bleed so no white slivers show after cutting.
+
+const (
+	bleedEdgeTolerance = 0.5 // mm; layers this close to a trim edge count as touching it
+	registrationRadius = 1.5 // mm
+	printMarkClearance = 1.0 // mm between the marks and the media edge
+)
+
+// markOffset is the distance from the trim edge to where printer's marks start,
+// clear of the bleed
+func markOffset(settings models.Settings) float64 {
+	return math.Max(math.Max(0, settings.Bleed), cropMarkOffset)
+}
+
+// printMargin is the space around the badge on the page: the bleed, plus room
+// for printer's marks when they are drawn
+func printMargin(settings models.Settings) float64 {
+	if settings.CropMarks || settings.RegistrationMarks {
+		return markOffset(settings) + cropMarkLength + printMarkClearance
+	}
+	return math.Max(0, settings.Bleed)
+}
+
+// setPageBoxes sets the TrimBox, BleedBox and ArtBox for every page of a badge
+// PDF whose trim box is inset by margin. Boxes are in PDF space, measured from
+// the bottom-left corner of the page.
+func setPageBoxes(pdf *gofpdf.Fpdf, settings models.Settings, size models.Size, margin float64) {
+	if margin > 0 {
+		pdf.SetPageBox("trim", margin, margin, size.Width, size.Height)
+		if bleed := math.Max(0, settings.Bleed); bleed > 0 {
+			pdf.SetPageBox("bleed", margin-bleed, margin-bleed, size.Width+2*bleed, size.Height+2*bleed)
+		}
+	}
+
+	safe := settings.Margins
+	if safe.Top > 0 || safe.Right > 0 || safe.Bottom > 0 || safe.Left > 0 {
+		pdf.SetPageBox("art", margin+safe.Left, margin+safe.Bottom,
+			size.Width-safe.Left-safe.Right, size.Height-safe.Top-safe.Bottom)
+	}
+}
+
+// PrintLayers returns the top-level layers of a page as they are drawn:
+// sorted by zIndex, with image and shape layers that touch a trim edge
+// extended into the bleed. The layers are a copy; batch generators share the
+// template.
+func PrintLayers(template *models.Template, page models.Page) []models.Layer {
+	layers := append([]models.Layer(nil), page.Layers...)
+	sort.SliceStable(layers, func(i, j int) bool {
+		return layers[i].ZIndex < layers[j].ZIndex
+	})
+
+	bleed := template.Design.Settings.Bleed
+	if bleed <= 0 {
+		return layers
+	}
+	width, height := badgeSize(template)
+	for i := range layers {
+		layers[i] = extendIntoBleed(layers[i], width, height, bleed)
+	}
+	return layers
+}
+
+// extendIntoBleed grows a background layer by the bleed on every side where
+// it touches the trim edge of a width x height badge
+func extendIntoBleed(layer models.Layer, width, height, bleed float64) models.Layer {
+	// Only plain rectangles: moving the edge of a circle, mask or rounded
+	// corner would change its shape
+	if layer.Type != "image" && layer.Type != "shape" {
+		return layer
+	}
+	if layerGeometry(layer, 0, 0).kind != shapeRect || normalizeRotation(layer.Style.Rotation) != 0 {
+		return layer
+	}
+
+	left := layer.Position.X <= bleedEdgeTolerance
+	top := layer.Position.Y <= bleedEdgeTolerance
+	right := layer.Position.X+layer.Size.Width >= width-bleedEdgeTolerance
+	bottom := layer.Position.Y+layer.Size.Height >= height-bleedEdgeTolerance
+	if !left && !top && !right && !bottom {
+		return layer
+	}
+
+	if left {
+		layer.Position.X -= bleed
+		layer.Size.Width += bleed
+	}
+	if right {
+		layer.Size.Width += bleed
+	}
+	if top {
+		layer.Position.Y -= bleed
+		layer.Size.Height += bleed
+	}
+	if bottom {
+		layer.Size.Height += bleed
+	}
+
+	// A stretched image would be distorted; cover keeps its proportions and
+	// crops the sliver that lands in the bleed
+	if layer.Type == "image" && layer.ObjectFit() == "fill" {
+		layer.Style.ObjectFit = "cover"
+	}
+	return layer
+}
+
+// drawPrinterMarks draws the crop and registration marks around the badge at
+// g.origin
+func (g *PDFGenerator) drawPrinterMarks() {
+	settings := g.template.Design.Settings
+	offset := markOffset(settings)
+
+	if settings.CropMarks {
+		drawCropMarks(g.pdf, []models.Position{g.origin}, g.size, offset)
+	}
+
+	if settings.RegistrationMarks {
+		g.pdf.SetLineWidth(cropMarkWidth)
+		g.pdf.SetDrawColor(0, 0, 0)
+		distance := offset + cropMarkLength/2
+		centerX := g.origin.X + g.size.Width/2
+		centerY := g.origin.Y + g.size.Height/2
+		for _, c := range []models.Position{
+			{X: centerX, Y: g.origin.Y - distance},
+			{X: centerX, Y: g.origin.Y + g.size.Height + distance},
+			{X: g.origin.X - distance, Y: centerY},
+			{X: g.origin.X + g.size.Width + distance, Y: centerY},
+		} {
+			drawRegistrationMark(g.pdf, c)
+		}
+	}
+}
+
+// drawRegistrationMark draws a target: a circle with a cross through it
+func drawRegistrationMark(pdf *gofpdf.Fpdf, c models.Position) {
+	half := cropMarkLength / 2
+	pdf.Circle(c.X, c.Y, registrationRadius, "D")
+	pdf.Line(c.X-half, c.Y, c.X+half, c.Y)
+	pdf.Line(c.X, c.Y-half, c.X, c.Y+half)
+}
@@ -0,0 +1,66 @@
+package generator
+
+import (
+	"badge-service/internal/models"
+	"testing"
+)
+
+func TestPrintMargin(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings models.Settings
+		want     float64
+	}{
+		{"nothing", models.Settings{}, 0},
+		{"bleed", models.Settings{Bleed: 3}, 3},
+		{"negative bleed", models.Settings{Bleed: -2}, 0},
+		{"crop marks", models.Settings{CropMarks: true}, cropMarkOffset + cropMarkLength + printMarkClearance},
+		{"crop marks outside a wide bleed", models.Settings{Bleed: 5, CropMarks: true}, 5 + cropMarkLength + printMarkClearance},
+		{"registration marks", models.Settings{Bleed: 1, RegistrationMarks: true}, cropMarkOffset + cropMarkLength + printMarkClearance},
+	}
+	for _, tt := range tests {
+		if got := printMargin(tt.settings); got != tt.want {
+			t.Errorf("%s: printMargin = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExtendIntoBleed(t *testing.T) {
+	// An 86 x 54 mm badge with 3 mm of bleed
+	layer := func(kind string, x, y, w, h float64) models.Layer {
+		return models.Layer{Type: kind, Position: models.Position{X: x, Y: y}, Size: models.Size{Width: w, Height: h}}
+	}
+	tests := []struct {
+		name     string
+		layer    models.Layer
+		position models.Position
+		size     models.Size
+		fit      string
+	}{
+		{"full background", layer("shape", 0, 0, 86, 54), models.Position{X: -3, Y: -3}, models.Size{Width: 92, Height: 60}, ""},
+		{"top band", layer("shape", 0, 0, 86, 10), models.Position{X: -3, Y: -3}, models.Size{Width: 92, Height: 13}, ""},
+		{"within tolerance of the right edge", layer("shape", 50, 20, 35.6, 10), models.Position{X: 50, Y: 20}, models.Size{Width: 38.6, Height: 10}, ""},
+		{"inside the badge", layer("shape", 10, 10, 20, 20), models.Position{X: 10, Y: 10}, models.Size{Width: 20, Height: 20}, ""},
+		{"text is not extended", layer("text", 0, 0, 86, 10), models.Position{X: 0, Y: 0}, models.Size{Width: 86, Height: 10}, ""},
+		{"stretched image is covered", layer("image", 0, 44, 86, 10), models.Position{X: -3, Y: 44}, models.Size{Width: 92, Height: 13}, "cover"},
+	}
+	for _, tt := range tests {
+		got := extendIntoBleed(tt.layer, 86, 54, 3)
+		if got.Position != tt.position || got.Size != tt.size || got.Style.ObjectFit != tt.fit {
+			t.Errorf("%s: got %+v %+v %q, want %+v %+v %q", tt.name, got.Position, got.Size, got.Style.ObjectFit, tt.position, tt.size, tt.fit)
+		}
+	}
+
+	// Shapes whose outline isn't a plain rectangle keep their size
+	keep := []models.Layer{
+		{Type: "shape", Size: models.Size{Width: 86, Height: 54}, Shape: &models.Shape{Type: "circle"}},
+		{Type: "shape", Size: models.Size{Width: 86, Height: 54}, Style: models.Style{BorderRadius: 4}},
+		{Type: "image", Size: models.Size{Width: 86, Height: 54}, Style: models.Style{Mask: "circle"}},
+		{Type: "shape", Size: models.Size{Width: 86, Height: 54}, Style: models.Style{Rotation: 90}},
+	}
+	for i, l := range keep {
+		if got := extendIntoBleed(l, 86, 54, 3); got.Position != l.Position || got.Size != l.Size {
+			t.Errorf("layer %d extended to %+v %+v", i, got.Position, got.Size)
+		}
+	}
+}
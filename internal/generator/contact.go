@@ -0,0 +1,209 @@
+package generator
+
+import (
+	"badge-service/internal/expr"
+	"badge-service/internal/models"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Contact card QR codes: vCard 3.0/4.0 and MeCard payloads built from the
+// user's data, so exhibitors can scan a badge straight into their contacts.
+
+// contactField is a contact property and the user fields it is read from by
+// default, first non-empty wins. Names go through the template's placeholders
+// map and custom field names, like {{ }} placeholders.
+type contactField struct {
+	name     string
+	defaults []string
+}
+
+// contactFields are in order of importance: when a card is too long for the
+// QR code, fields are dropped from the end
+var contactFields = []contactField{
+	{"firstName", []string{"firstName"}},
+	{"lastName", []string{"lastName"}},
+	{"email", []string{"email"}},
+	{"phone", []string{"phone", "mobile", "phoneNumber", "Phone Number", "telephone"}},
+	{"company", []string{"company", "organization", "organisation", "Company Name"}},
+	{"title", []string{"jobTitle", "Job Title", "title", "position"}},
+	{"url", []string{"website", "url"}},
+	{"note", nil},
+}
+
+// qrCapacity is the most bytes a QR code holds at each error correction level
+var qrCapacity = map[qrcode.RecoveryLevel]int{
+	qrcode.Low:     2953,
+	qrcode.Medium:  2331,
+	qrcode.High:    1663,
+	qrcode.Highest: 1273,
+}
+
+// contactPayload builds the vCard or MeCard for the user. Values come from
+// contactFields in the QR options (placeholder templates) or the default user
+// fields. Optional fields are dropped, least important first, until the card
+// fits in limit bytes.
+func (g *PDFGenerator) contactPayload(opts models.QRCode, limit int) (string, error) {
+	resolve := fieldResolver(g.template, g.user)
+
+	values := make(map[string]string, len(contactFields))
+	for _, field := range contactFields {
+		var value string
+		if tmpl, ok := opts.ContactFields[field.name]; ok {
+			value = expr.Render(tmpl, resolve)
+		} else {
+			for _, name := range field.defaults {
+				if value = resolve(name); value != "" {
+					break
+				}
+			}
+		}
+		values[field.name] = strings.TrimSpace(value)
+	}
+
+	if values["firstName"] == "" && values["lastName"] == "" && values["email"] == "" && values["phone"] == "" {
+		return "", fmt.Errorf("no contact details for a %s", opts.Mode)
+	}
+
+	// Name and email are always kept
+	for keep := len(contactFields); ; keep-- {
+		var card string
+		if strings.EqualFold(opts.Mode, "mecard") {
+			card = meCard(values)
+		} else {
+			card = vCard(values, opts.VCardVersion)
+		}
+		if len(card) <= limit {
+			return card, nil
+		}
+		if keep <= 3 {
+			return "", fmt.Errorf("%s is %d bytes, more than the %d a QR code can hold", opts.Mode, len(card), limit)
+		}
+		delete(values, contactFields[keep-1].name)
+	}
+}
+
+// vCard formats a vCard 3.0 or, with version "4.0" or "4", a vCard 4.0
+func vCard(v map[string]string, version string) string {
+	v4 := version == "4.0" || version == "4"
+
+	var lines []string
+	add := func(line string) {
+		lines = append(lines, foldLine(line))
+	}
+
+	add("BEGIN:VCARD")
+	if v4 {
+		add("VERSION:4.0")
+	} else {
+		add("VERSION:3.0")
+	}
+	add("N:" + escapeVCard(v["lastName"]) + ";" + escapeVCard(v["firstName"]) + ";;;")
+	fullName := strings.TrimSpace(v["firstName"] + " " + v["lastName"])
+	if fullName == "" {
+		fullName = v["email"]
+	}
+	add("FN:" + escapeVCard(fullName))
+	if v["company"] != "" {
+		add("ORG:" + escapeVCard(v["company"]))
+	}
+	if v["title"] != "" {
+		add("TITLE:" + escapeVCard(v["title"]))
+	}
+	if v["phone"] != "" {
+		if v4 {
+			add("TEL;VALUE=uri:tel:" + strings.Join(strings.Fields(v["phone"]), ""))
+		} else {
+			add("TEL:" + escapeVCard(v["phone"]))
+		}
+	}
+	if v["email"] != "" {
+		add("EMAIL:" + escapeVCard(v["email"]))
+	}
+	if v["url"] != "" {
+		add("URL:" + v["url"])
+	}
+	if v["note"] != "" {
+		add("NOTE:" + escapeVCard(v["note"]))
+	}
+	add("END:VCARD")
+
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+var vCardEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// escapeVCard escapes a vCard text value (RFC 6350 section 3.4)
+func escapeVCard(s string) string {
+	return vCardEscaper.Replace(s)
+}
+
+// foldLine folds a vCard content line at 75 bytes, without splitting a
+// UTF-8 character; continuation lines start with a space
+func foldLine(line string) string {
+	const limit = 75
+	if len(line) <= limit {
+		return line
+	}
+
+	var sb strings.Builder
+	width := limit
+	for len(line) > width {
+		cut := width
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		sb.WriteString(line[:cut])
+		sb.WriteString("\r\n ")
+		line = line[cut:]
+		width = limit - 1 // the leading space counts
+	}
+	sb.WriteString(line)
+	return sb.String()
+}
+
+// meCard formats a MeCard. MeCard has no title property, so the title goes in
+// the note.
+func meCard(v map[string]string) string {
+	var sb strings.Builder
+	sb.WriteString("MECARD:")
+	field := func(name, value string) {
+		if value != "" {
+			sb.WriteString(name + ":" + escapeMeCard(value) + ";")
+		}
+	}
+
+	name := escapeMeCard(v["lastName"])
+	if v["firstName"] != "" {
+		if name != "" {
+			name += ","
+		}
+		name += escapeMeCard(v["firstName"])
+	}
+	if name == "" {
+		name = escapeMeCard(v["email"])
+	}
+	sb.WriteString("N:" + name + ";")
+
+	field("ORG", v["company"])
+	field("TEL", v["phone"])
+	field("EMAIL", v["email"])
+	field("URL", v["url"])
+	note := v["note"]
+	if v["title"] != "" {
+		note = strings.TrimSpace(v["title"] + " " + note)
+	}
+	field("NOTE", note)
+	sb.WriteString(";")
+	return sb.String()
+}
+
+var meCardEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ":", `\:`, ",", `\,`, `"`, `\"`, "\r\n", " ", "\n", " ", "\r", " ")
+
+// escapeMeCard escapes the MeCard special characters and flattens newlines
+func escapeMeCard(s string) string {
+	return meCardEscaper.Replace(s)
+}
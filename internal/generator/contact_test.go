@@ -0,0 +1,111 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestEscapeVCard(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Jane", "Jane"},
+		{"Doe, Jane; PhD", `Doe\, Jane\; PhD`},
+		{`C:\temp`, `C:\\temp`},
+		{"line one\r\nline two\nthree", `line one\nline two\nthree`},
+	}
+	for _, tt := range tests {
+		if got := escapeVCard(tt.in); got != tt.want {
+			t.Errorf("escapeVCard(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeMeCard(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Jane", "Jane"},
+		{`Acme; "Intl": A, B`, `Acme\; \"Intl\"\: A\, B`},
+		{`a\b`, `a\\b`},
+		{"one\ntwo", "one two"},
+	}
+	for _, tt := range tests {
+		if got := escapeMeCard(tt.in); got != tt.want {
+			t.Errorf("escapeMeCard(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFoldLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"short", "FN:Jane Doe"},
+		{"exactly 75 bytes", "NOTE:" + strings.Repeat("x", 70)},
+		{"long ascii", "NOTE:" + strings.Repeat("abcdefghij", 20)},
+		{"multi-byte characters", "NOTE:" + strings.Repeat("مرحبا ", 40)},
+	}
+	for _, tt := range tests {
+		folded := foldLine(tt.line)
+		if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != tt.line {
+			t.Errorf("%s: unfolds to %q", tt.name, unfolded)
+		}
+		for i, part := range strings.Split(folded, "\r\n") {
+			if len(part) > 75 {
+				t.Errorf("%s: line %d is %d bytes", tt.name, i, len(part))
+			}
+			if !utf8.ValidString(part) {
+				t.Errorf("%s: line %d splits a character", tt.name, i)
+			}
+			if i > 0 && !strings.HasPrefix(part, " ") {
+				t.Errorf("%s: continuation line %d has no leading space", tt.name, i)
+			}
+		}
+		if len(tt.line) <= 75 && folded != tt.line {
+			t.Errorf("%s: folded a short line", tt.name)
+		}
+	}
+}
+
+func TestVCard(t *testing.T) {
+	values := map[string]string{
+		"firstName": "Jane",
+		"lastName":  "Doe",
+		"email":     "jane@example.com",
+		"phone":     "+20 100 123 4567",
+		"company":   "Acme, Inc.",
+		"title":     "CTO",
+	}
+	tests := []struct {
+		version string
+		want    []string
+	}{
+		{"", []string{"BEGIN:VCARD", "VERSION:3.0", "N:Doe;Jane;;;", "FN:Jane Doe", `ORG:Acme\, Inc.`, "TITLE:CTO", "TEL:+20 100 123 4567", "EMAIL:jane@example.com", "END:VCARD", ""}},
+		{"4.0", []string{"BEGIN:VCARD", "VERSION:4.0", "N:Doe;Jane;;;", "FN:Jane Doe", `ORG:Acme\, Inc.`, "TITLE:CTO", "TEL;VALUE=uri:tel:+201001234567", "EMAIL:jane@example.com", "END:VCARD", ""}},
+	}
+	for _, tt := range tests {
+		if got := vCard(values, tt.version); got != strings.Join(tt.want, "\r\n") {
+			t.Errorf("vCard(%q) = %q", tt.version, got)
+		}
+	}
+}
+
+func TestMeCard(t *testing.T) {
+	tests := []struct {
+		values map[string]string
+		want   string
+	}{
+		{
+			map[string]string{"firstName": "Jane", "lastName": "Doe", "phone": "+201001234567", "title": "CTO", "note": "Booth 4"},
+			"MECARD:N:Doe,Jane;TEL:+201001234567;NOTE:CTO Booth 4;;",
+		},
+		{
+			map[string]string{"email": "jane@example.com", "url": "https://example.com"},
+			`MECARD:N:jane@example.com;EMAIL:jane@example.com;URL:https\://example.com;;`,
+		},
+	}
+	for _, tt := range tests {
+		if got := meCard(tt.values); got != tt.want {
+			t.Errorf("meCard(%v) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
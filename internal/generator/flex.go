@@ -0,0 +1,415 @@
+package generator
+
+import (
+	"badge-service/internal/fonts"
+	"badge-service/internal/models"
+	"math"
+	"strings"
+)
+
+// Flexbox layout for containers (containerLayout.type "flex", the default).
+//
+// This follows the CSS flex layout algorithm in simplified form: each child
+// gets a flex basis, children are collected into lines when flexWrap allows,
+// free space in each line is shared out by flexGrow or taken back by
+// flexShrink, and lines and items are aligned with justifyContent,
+// alignContent and alignItems/alignSelf. Text layers and nested containers are
+// measured, so a child is never laid out smaller than its content unless it
+// has to shrink, and text never shrinks below its longest word.
+
+// flexItem is a visible child of a flex container. Sizes are along the main
+// axis unless named cross.
+type flexItem struct {
+	index  int
+	child  models.Layer
+	hypo   float64 // flex basis, raised to the minimum size
+	min    float64 // smallest size the content allows
+	main   float64 // resolved size
+	cross  float64
+	frozen bool
+}
+
+// flexLine is one line of a (possibly wrapping) flex container
+type flexLine struct {
+	items  []*flexItem
+	cross  float64 // size of the line across the main axis
+	offset float64 // position of the line across the main axis
+}
+
+// flexAxes maps main/cross sizes to width/height for a flex direction
+type flexAxes struct {
+	row bool
+}
+
+func (a flexAxes) main(s models.Size) float64 {
+	if a.row {
+		return s.Width
+	}
+	return s.Height
+}
+
+func (a flexAxes) cross(s models.Size) float64 {
+	if a.row {
+		return s.Height
+	}
+	return s.Width
+}
+
+func (a flexAxes) size(main, cross float64) models.Size {
+	if a.row {
+		return models.Size{Width: main, Height: cross}
+	}
+	return models.Size{Width: cross, Height: main}
+}
+
+func (a flexAxes) position(main, cross float64) models.Position {
+	if a.row {
+		return models.Position{X: main, Y: cross}
+	}
+	return models.Position{X: cross, Y: main}
+}
+
+// containerLayout returns the layout of a container, stacking children
+// vertically when it has none
+func containerLayout(container models.Layer) *models.ContainerLayout {
+	if container.ContainerLayout != nil {
+		return container.ContainerLayout
+	}
+	return &models.ContainerLayout{
+		Type:          "flex",
+		FlexDirection: "column",
+	}
+}
+
+// layoutChildren returns the position (relative to the container) and size of
+// every child of a container
+func (g *PDFGenerator) layoutChildren(container models.Layer) ([]models.Position, []models.Size) {
+	layout := containerLayout(container)
+	if layout.Type == "grid" {
+		return g.calculateGridLayout(container, layout)
+	}
+	return g.calculateFlexLayout(container, layout)
+}
+
+// calculateFlexLayout lays out the children of a flex container
+func (g *PDFGenerator) calculateFlexLayout(container models.Layer, layout *models.ContainerLayout) ([]models.Position, []models.Size) {
+	positions := make([]models.Position, len(container.Children))
+	sizes := make([]models.Size, len(container.Children))
+	for i, child := range container.Children {
+		sizes[i] = child.Size
+	}
+
+	// Direction defaults to column, which is how containers without a layout stack
+	direction := strings.ToLower(layout.FlexDirection)
+	axes := flexAxes{row: strings.HasPrefix(direction, "row")}
+	reverse := strings.HasSuffix(direction, "-reverse")
+	wrapMode := strings.ToLower(layout.FlexWrap)
+	wrap := wrapMode == "wrap" || wrapMode == "wrap-reverse"
+	gap := float64(layout.FlexGap)
+
+	containerMain := axes.main(container.Size)
+	containerCross := axes.cross(container.Size)
+
+	items := g.flexItems(container.Children, axes, containerMain)
+	if len(items) == 0 {
+		return positions, sizes
+	}
+
+	lines := buildFlexLines(items, containerMain, gap, wrap)
+	for _, line := range lines {
+		resolveFlexibleLengths(line, containerMain, gap)
+		for _, it := range line.items {
+			line.cross = math.Max(line.cross, it.cross)
+		}
+	}
+
+	// A single-line container gives its whole cross size to the line.
+	// Lines of a wrapping container are packed with alignContent.
+	if !wrap {
+		lines[0].cross = containerCross
+	} else {
+		used := gap * float64(len(lines)-1)
+		for _, line := range lines {
+			used += line.cross
+		}
+		free := containerCross - used
+
+		var start, between float64
+		switch strings.ToLower(layout.AlignContent) {
+		case "", "normal", "stretch":
+			if free > 0 {
+				for _, line := range lines {
+					line.cross += free / float64(len(lines))
+				}
+			}
+		default:
+			start, between = distributeSpace(free, len(lines), layout.AlignContent)
+		}
+
+		pos := start
+		for _, line := range lines {
+			line.offset = pos
+			if wrapMode == "wrap-reverse" {
+				line.offset = containerCross - pos - line.cross
+			}
+			pos += line.cross + gap + between
+		}
+	}
+
+	for _, line := range lines {
+		used := gap * float64(len(line.items)-1)
+		for _, it := range line.items {
+			used += it.main
+		}
+		start, between := distributeSpace(containerMain-used, len(line.items), layout.JustifyContent)
+
+		pos := start
+		for _, it := range line.items {
+			mainPos := pos
+			if reverse {
+				mainPos = containerMain - pos - it.main
+			}
+			pos += it.main + gap + between
+
+			align := it.child.AlignSelf
+			if align == "" {
+				align = layout.AlignItems
+			}
+			crossPos, cross := alignInCell(line.offset, line.cross, it.cross, align)
+
+			positions[it.index] = axes.position(mainPos, crossPos)
+			sizes[it.index] = axes.size(it.main, cross)
+		}
+	}
+
+	return positions, sizes
+}
+
+// flexItems builds the items of the visible children with their flex basis.
+// An "auto" basis is the child's own size, grown to fit its content.
+func (g *PDFGenerator) flexItems(children []models.Layer, axes flexAxes, containerMain float64) []*flexItem {
+	var items []*flexItem
+	for i, child := range children {
+		if !g.isVisible(child) {
+			continue
+		}
+
+		content, min := g.flexContentSize(child, axes)
+
+		var basis float64
+		switch child.FlexBasis.Kind {
+		case models.TrackFixed:
+			basis = child.FlexBasis.Value
+		case models.TrackPercent:
+			basis = containerMain * child.FlexBasis.Value / 100
+		case models.BasisContent:
+			basis = content
+		default:
+			basis = math.Max(axes.main(child.Size), content)
+		}
+
+		items = append(items, &flexItem{
+			index: i,
+			child: child,
+			hypo:  math.Max(basis, min),
+			min:   min,
+			cross: axes.cross(child.Size),
+		})
+	}
+	return items
+}
+
+// buildFlexLines breaks items into lines that fit the container. Without
+// wrapping everything is one line, which may overflow.
+func buildFlexLines(items []*flexItem, containerMain, gap float64, wrap bool) []*flexLine {
+	if !wrap {
+		return []*flexLine{{items: items}}
+	}
+
+	var lines []*flexLine
+	line := &flexLine{}
+	used := 0.0
+	for _, it := range items {
+		if len(line.items) > 0 && used+gap+it.hypo > containerMain {
+			lines = append(lines, line)
+			line = &flexLine{}
+			used = 0
+		}
+		if len(line.items) > 0 {
+			used += gap
+		}
+		used += it.hypo
+		line.items = append(line.items, it)
+	}
+	return append(lines, line)
+}
+
+// resolveFlexibleLengths shares the free space of a line between its items by
+// flexGrow, or removes overflow by flexShrink weighted by size. Items that
+// would shrink below their minimum are frozen there and the rest is
+// redistributed, as in the CSS algorithm.
+func resolveFlexibleLengths(line *flexLine, containerMain, gap float64) {
+	gaps := gap * float64(len(line.items)-1)
+	hypoTotal := gaps
+	for _, it := range line.items {
+		hypoTotal += it.hypo
+	}
+	growing := containerMain > hypoTotal
+
+	for _, it := range line.items {
+		it.main = it.hypo
+		factor := it.child.Shrink()
+		if growing {
+			factor = it.child.FlexGrow
+		}
+		it.frozen = factor <= 0
+	}
+
+	for range line.items {
+		remaining := containerMain - gaps
+		var factorTotal float64
+		for _, it := range line.items {
+			if it.frozen {
+				remaining -= it.main
+				continue
+			}
+			remaining -= it.hypo
+			if growing {
+				factorTotal += it.child.FlexGrow
+			} else {
+				factorTotal += it.child.Shrink() * it.hypo
+			}
+		}
+		if factorTotal == 0 || remaining == 0 {
+			return
+		}
+
+		violated := false
+		for _, it := range line.items {
+			if it.frozen {
+				continue
+			}
+			if growing {
+				it.main = it.hypo + remaining*it.child.FlexGrow/factorTotal
+			} else {
+				it.main = it.hypo + remaining*it.child.Shrink()*it.hypo/factorTotal
+			}
+			if it.main < it.min {
+				it.main = it.min
+				it.frozen = true
+				violated = true
+			}
+		}
+		if !violated {
+			return
+		}
+	}
+}
+
+// flexContentSize measures a child along the main axis. It returns the size
+// its content needs and the smallest size it can shrink to. Like the CSS
+// automatic minimum size, that is the smaller of the child's own size and
+// what its content allows, so images and QR codes never shrink below the size
+// they were designed at.
+func (g *PDFGenerator) flexContentSize(child models.Layer, axes flexAxes) (float64, float64) {
+	own := axes.main(child.Size)
+
+	switch child.Type {
+	case "text":
+		// Auto-sized text fits whatever box it gets
+		if child.AutoFontSize {
+			return 0, 0
+		}
+		if axes.row {
+			content, word := g.textContentWidth(child)
+			return content, math.Min(own, word)
+		}
+		height := g.textContentHeight(child, child.Size.Width)
+		return height, math.Min(own, height)
+	case "container":
+		content := axes.main(g.containerContentSize(child))
+		return content, math.Min(own, content)
+	default:
+		return 0, own
+	}
+}
+
+// containerContentSize lays out a nested container and returns the extent of
+// its children, so containers grow to fit what they hold
+func (g *PDFGenerator) containerContentSize(container models.Layer) models.Size {
+	positions, sizes := g.layoutChildren(container)
+
+	var extent models.Size
+	for i, child := range container.Children {
+		if !g.isVisible(child) {
+			continue
+		}
+		extent.Width = math.Max(extent.Width, positions[i].X+sizes[i].Width)
+		extent.Height = math.Max(extent.Height, positions[i].Y+sizes[i].Height)
+	}
+	return extent
+}
+
+// layerText is the text a text layer draws, placeholders resolved and Arabic shaped
+func (g *PDFGenerator) layerText(layer models.Layer) string {
+	return shapeArabic(g.resolvePlaceholders(layer.Content))
+}
+
+// textContentWidth returns the layer width needed to draw the text's longest
+// line without wrapping, and the width of its longest word
+func (g *PDFGenerator) textContentWidth(layer models.Layer) (float64, float64) {
+	text := g.layerText(layer)
+	if strings.TrimSpace(text) == "" {
+		return 0, 0
+	}
+
+	fontSize := g.layerFontSize(layer)
+	fc := g.buildFontChain(layer.Style.FontFamily, layer.Style.FontWeight, fonts.IsItalic(layer.Style.FontStyle))
+
+	var lineWidth, wordWidth float64
+	for _, line := range strings.Split(text, "\n") {
+		lineWidth = math.Max(lineWidth, g.measureText(fc, line, fontSize))
+		for _, word := range strings.Fields(line) {
+			wordWidth = math.Max(wordWidth, g.measureText(fc, word, fontSize))
+		}
+	}
+
+	return g.textBoxWidth(lineWidth), g.textBoxWidth(wordWidth)
+}
+
+// textBoxWidth is the layer width renderText needs to keep text of the given
+// width on one line: inside the wrap threshold and clear of the cell margins
+func (g *PDFGenerator) textBoxWidth(textWidth float64) float64 {
+	if textWidth == 0 {
+		return 0
+	}
+	return math.Max(textWidth/textWrapThreshold, textWidth+2*g.pdf.GetCellMargin())
+}
+
+// textContentHeight returns the height in mm of the text's lines when wrapped at width
+func (g *PDFGenerator) textContentHeight(layer models.Layer, width float64) float64 {
+	text := g.layerText(layer)
+	if strings.TrimSpace(text) == "" {
+		return 0
+	}
+
+	fontSize := g.layerFontSize(layer)
+	fc := g.buildFontChain(layer.Style.FontFamily, layer.Style.FontWeight, fonts.IsItalic(layer.Style.FontStyle))
+	tl := textLayout{fonts: fc, size: fontSize}
+
+	lines := 0
+	for _, line := range strings.Split(text, "\n") {
+		if g.measureText(fc, line, fontSize) <= width*textWrapThreshold {
+			lines++
+			continue
+		}
+		if n := len(g.splitLines(tl, line, width)); n > 0 {
+			lines += n
+		} else {
+			lines++
+		}
+	}
+
+	// Font sizes are in points, layout in mm
+	return float64(lines) * fontSize * 25.4 / 72 * textLineHeight
+}
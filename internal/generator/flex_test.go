@@ -0,0 +1,37 @@
+package generator
+
+import (
+	"badge-service/internal/models"
+	"math"
+	"testing"
+)
+
+func TestTextContentHeight(t *testing.T) {
+	template := &models.Template{}
+	template.Design.Settings = models.Settings{PaperWidth: 90, PaperHeight: 140, DPI: 72}
+	g := NewPDFGenerator(template, &models.User{})
+
+	tests := []struct {
+		content string
+		want    float64 // mm
+	}{
+		{"", 0},
+		{"Jane", 12 * 25.4 / 72 * textLineHeight},
+	}
+	for _, tt := range tests {
+		layer := models.Layer{Type: "text", Content: tt.content, Size: models.Size{Width: 80, Height: 20}}
+		layer.Style.FontSize = 12
+		if got := g.textContentHeight(layer, layer.Size.Width); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%q: height %.3f mm, want %.3f", tt.content, got, tt.want)
+		}
+	}
+
+	// A line that wraps stays a whole number of 12pt lines
+	layer := models.Layer{Type: "text", Content: "Jane Doe Jane Doe Jane Doe Jane Doe", Size: models.Size{Width: 20, Height: 20}}
+	layer.Style.FontSize = 12
+	line := g.layerFontSize(layer) * 25.4 / 72 * textLineHeight
+	got := g.textContentHeight(layer, layer.Size.Width)
+	if lines := got / line; lines < 2 || math.Abs(lines-math.Round(lines)) > 1e-9 {
+		t.Errorf("wrapped height %.3f mm is not a multiple of %.3f mm", got, line)
+	}
+}
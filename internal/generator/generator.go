@@ -2,6 +2,8 @@ package generator
 
 import (
 	"badge-service/internal/cache"
+	"badge-service/internal/expr"
+	"badge-service/internal/fonts"
 	"badge-service/internal/models"
 	"bytes"
 	"crypto/md5"
@@ -9,36 +11,96 @@ import (
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"math"
 	"os"
 	"regexp"
-	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/jung-kurt/gofpdf"
 	"github.com/skip2/go-qrcode"
+	"golang.org/x/image/colornames"
 	_ "golang.org/x/image/webp"
 )
 
 // Pre-compiled regex patterns for better performance
 var (
-	placeholderRegex = regexp.MustCompile(`\{\{customFields\.([a-f0-9-]+)\}\}`)
-	whitespaceRegex  = regexp.MustCompile(`\s+`)
+	whitespaceRegex = regexp.MustCompile(`\s+`)
 )
 
+// Text lines wrap once they fill this fraction of the layer width
+const textWrapThreshold = 0.95
+
+// Line height as a multiple of the font size
+const textLineHeight = 1.2
+
 // PDFGenerator handles PDF generation for badges
 type PDFGenerator struct {
 	template    *models.Template
 	user        *models.User
 	pdf         *gofpdf.Fpdf
 	imageCache  map[string]string // URL -> local path (for backward compatibility)
-	imageDataCache map[string][]byte // URL -> raw PNG bytes (preferred, fastest - no base64, no files)
+	imageDataCache map[string][]byte // ImageRequest key -> raw PNG bytes (preferred, fastest - no base64, no files)
 	scaleFactor float64           // Scale from mm to points
 	dpi         int               // DPI from template settings for font size conversion
+	fontFaces   map[string]bool   // Registry face IDs already added to this PDF
+	alpha       float64           // Effective opacity of the layer being drawn (parents multiplied in)
+	gsAlpha     float64           // Opacity currently set in the PDF graphics state
+	size        models.Size       // Badge size in mm
+	origin      models.Position   // Top-left corner of the badge (trim box) on the page
 }
 
 // NewPDFGenerator creates a new PDF generator instance
 func NewPDFGenerator(template *models.Template, user *models.User) *PDFGenerator {
+	width, height := badgeSize(template)
+	
+	// The page is the badge plus any bleed and room for printer's marks
+	margin := printMargin(template.Design.Settings)
+	
+	// Create PDF with exact dimensions
+	pdf := gofpdf.NewCustom(pageInit(models.Size{Width: width + 2*margin, Height: height + 2*margin}))
+	
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	setPageBoxes(pdf, template.Design.Settings, models.Size{Width: width, Height: height}, margin)
+	// Pages are added by Generate, one per page or side of the design
+	
+	g := newGenerator(template, user, pdf)
+	g.origin = models.Position{X: margin, Y: margin}
+	return g
+}
+
+// newGenerator creates a generator that draws into pdf, which may hold other
+// badges too (see Impose)
+func newGenerator(template *models.Template, user *models.User, pdf *gofpdf.Fpdf) *PDFGenerator {
+	// Fonts are added lazily from the font registry as layers ask for them (see resolveFont)
+	
+	// Get DPI from template settings (default to 300 if not set)
+	dpi := template.Design.Settings.DPI
+	if dpi == 0 {
+		dpi = 300 // Standard print DPI
+	}
+	
+	width, height := badgeSize(template)
+	
+	return &PDFGenerator{
+		template:       template,
+		user:           user,
+		pdf:            pdf,
+		imageCache:     make(map[string]string),
+		imageDataCache: make(map[string][]byte),
+		scaleFactor:    1.0,
+		dpi:            dpi,
+		fontFaces:      make(map[string]bool),
+		alpha:          1,
+		gsAlpha:        1,
+		size:           models.Size{Width: width, Height: height},
+	}
+}
+
+// badgeSize returns the page size of a badge in mm
+func badgeSize(template *models.Template) (float64, float64) {
 	settings := template.Design.Settings
 	
 	// Use template dimensions (in mm)
@@ -52,7 +114,13 @@ func NewPDFGenerator(template *models.Template, user *models.User) *PDFGenerator
 		height = template.Height
 	}
 	
-	// Default to A4 if not specified
+	// Dimensions the template gives decide the orientation; the designer
+	// sends "portrait" for every template, landscape ones included
+	if width > 0 && height > 0 {
+		return width, height
+	}
+	
+	// Default to A4 if not specified, turned by the orientation setting
 	if width == 0 {
 		width = 210
 	}
@@ -60,42 +128,44 @@ func NewPDFGenerator(template *models.Template, user *models.User) *PDFGenerator
 		height = 297
 	}
 	
-	// Create PDF with exact dimensions
-	pdf := gofpdf.NewCustom(&gofpdf.InitType{
-		OrientationStr: "P",
-		UnitStr:        "mm",
-		Size: gofpdf.SizeType{
-			Wd: width,
-			Ht: height,
-		},
-	})
-	
-	pdf.SetMargins(0, 0, 0)
-	pdf.SetAutoPageBreak(false, 0)
-	pdf.AddPage()
-	
-	// Add Unicode font support if font files exist
-	if _, err := os.Stat("fonts/arial.ttf"); err == nil {
-		pdf.AddUTF8Font("Arial", "", "fonts/arial.ttf")
-	}
-	if _, err := os.Stat("fonts/arialbd.ttf"); err == nil {
-		pdf.AddUTF8Font("Arial", "B", "fonts/arialbd.ttf")
+	size := orient(models.Size{Width: width, Height: height}, settings.Orientation)
+	return size.Width, size.Height
+}
+
+// orient applies an orientation setting to a default or named page size.
+// "landscape" puts the long side horizontal and "portrait" puts it vertical,
+// swapping width and height when they don't already match. An empty or
+// unknown orientation keeps the size as it is. Sizes given in the request are
+// not oriented: their dimensions already say which side is horizontal.
+func orient(size models.Size, orientation string) models.Size {
+	switch strings.ToLower(strings.TrimSpace(orientation)) {
+	case "landscape", "l":
+		if size.Width < size.Height {
+			size.Width, size.Height = size.Height, size.Width
+		}
+	case "portrait", "p":
+		if size.Width > size.Height {
+			size.Width, size.Height = size.Height, size.Width
+		}
 	}
-	
-	// Get DPI from template settings (default to 300 if not set)
-	dpi := settings.DPI
-	if dpi == 0 {
-		dpi = 300 // Standard print DPI
+	return size
+}
+
+// pageInit returns the gofpdf setup for pages of the given size, with the
+// orientation matching its dimensions
+func pageInit(size models.Size) *gofpdf.InitType {
+	// gofpdf takes the size in portrait and swaps it for landscape
+	if size.Width > size.Height {
+		return &gofpdf.InitType{
+			OrientationStr: "L",
+			UnitStr:        "mm",
+			Size:           gofpdf.SizeType{Wd: size.Height, Ht: size.Width},
+		}
 	}
-	
-	return &PDFGenerator{
-		template:       template,
-		user:           user,
-		pdf:            pdf,
-		imageCache:     make(map[string]string),
-		imageDataCache: make(map[string][]byte),
-		scaleFactor:    1.0,
-		dpi:            dpi,
+	return &gofpdf.InitType{
+		OrientationStr: "P",
+		UnitStr:        "mm",
+		Size:           gofpdf.SizeType{Wd: size.Width, Ht: size.Height},
 	}
 }
 
@@ -111,27 +181,12 @@ func (g *PDFGenerator) SetImageDataCache(cache map[string][]byte) {
 
 // Generate creates the PDF and returns the bytes
 func (g *PDFGenerator) Generate() ([]byte, error) {
-	// 1. Get all layers and sort by zIndex
-	layers := g.template.Design.Layers
-	sort.Slice(layers, func(i, j int) bool {
-		return layers[i].ZIndex < layers[j].ZIndex
-	})
-	
-	// 2. Render each layer
-	for _, layer := range layers {
-		if !layer.Visible {
-			continue
-		}
-		if err := g.renderLayer(layer, models.Position{X: 0, Y: 0}); err != nil {
-			// Log errors to stderr for debugging (production: remove or use proper logging)
-			if layer.Type == "qrcode" {
-				fmt.Fprintf(os.Stderr, "QR code error for layer %s: %v\n", layer.ID, err)
-			}
-			// Continue rendering other layers even if one fails
-		}
+	// 1. Render each page (or side) in order
+	for i, page := range g.template.Design.PageList() {
+		g.renderPage(page, i)
 	}
 	
-	// 3. Output PDF to buffer
+	// 2. Output PDF to buffer
 	var buf bytes.Buffer
 	if err := g.pdf.Output(&buf); err != nil {
 		return nil, fmt.Errorf("failed to output PDF: %w", err)
@@ -140,27 +195,166 @@ func (g *PDFGenerator) Generate() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// renderPage adds a page and renders its layers
+func (g *PDFGenerator) renderPage(page models.Page, index int) {
+	g.pdf.AddPage()
+	g.gsAlpha = 1 // Each page starts with a fresh graphics state
+	g.drawPage(page, index, g.origin)
+	g.drawPrinterMarks()
+}
+
+// drawPage renders the layers of a page with the badge's top-left corner at
+// origin. Back sides are rotated 180° when settings.flipBack is set, so they
+// come out upright after short-edge duplex printing or folding.
+func (g *PDFGenerator) drawPage(page models.Page, index int, origin models.Position) {
+	if g.template.Design.Settings.FlipBack && page.IsBack(index) {
+		g.pdf.TransformBegin()
+		g.pdf.TransformRotate(180, origin.X+g.size.Width/2, origin.Y+g.size.Height/2)
+		savedAlpha := g.gsAlpha
+		defer func() {
+			g.pdf.TransformEnd()
+			g.gsAlpha = savedAlpha
+		}()
+	}
+	
+	// Render each layer in zIndex order
+	for _, layer := range PrintLayers(g.template, page) {
+		if err := g.renderLayer(layer, origin); err != nil {
+			// Log errors to stderr for debugging (production: remove or use proper logging)
+			switch layer.Type {
+			case "qrcode":
+				fmt.Fprintf(os.Stderr, "QR code error for layer %s: %v\n", layer.ID, err)
+			case "barcode":
+				fmt.Fprintf(os.Stderr, "Barcode error for layer %s: %v\n", layer.ID, err)
+			}
+			// Continue rendering other layers even if one fails
+		}
+	}
+}
+
 // renderLayer renders a single layer at the given parent position
 func (g *PDFGenerator) renderLayer(layer models.Layer, parentPos models.Position) error {
+	// Hidden layers, statically or by their visibleIf condition, draw nothing
+	if !g.isVisible(layer) {
+		return nil
+	}
+	
 	// Calculate absolute position
 	absX := parentPos.X + layer.Position.X
 	absY := parentPos.Y + layer.Position.Y
 	
+	// Opacity multiplies with the opacity of enclosing containers. gofpdf has
+	// no transparency groups, so a container's opacity applies to each child
+	// on its own and overlapping children show through each other.
+	opacity := layer.Style.LayerOpacity()
+	if opacity == 0 {
+		return nil // Fully transparent, skip rendering
+	}
+	parentAlpha := g.alpha
+	g.alpha = parentAlpha * opacity
+	defer func() {
+		g.alpha = parentAlpha
+		g.setAlpha(parentAlpha)
+	}()
+	
+	// Rotate around the center of the box the designer drew. Everything drawn by
+	// the layer (including container children) is inside the transform.
+	if rotation := normalizeRotation(layer.Style.Rotation); rotation != 0 {
+		centerX := absX + layer.Size.Width/2
+		centerY := absY + layer.Size.Height/2
+		
+		var scale float64
+		layer.Size, scale = rotatedContentSize(layer.Size, rotation)
+		absX = centerX - layer.Size.Width/2
+		absY = centerY - layer.Size.Height/2
+		
+		g.pdf.TransformBegin()
+		// gofpdf rotates counter-clockwise, CSS rotate() is clockwise
+		g.pdf.TransformRotate(-rotation, centerX, centerY)
+		if scale < 1 {
+			g.pdf.TransformScaleXY(scale*100, centerX, centerY)
+		}
+		// Q restores the graphics state, including the opacity set inside the transform
+		savedAlpha := g.gsAlpha
+		defer func() {
+			g.pdf.TransformEnd()
+			g.gsAlpha = savedAlpha
+		}()
+	}
+	
+	// Background fill sits under the layer content; shapes draw their own fill and stroke
+	if layer.Type != "shape" {
+		g.renderBackground(layer, absX, absY)
+	}
+	g.setAlpha(g.alpha)
+	
+	var err error
 	switch layer.Type {
 	case "text":
-		return g.renderText(layer, absX, absY)
+		err = g.renderText(layer, absX, absY)
 	case "qrcode":
-		return g.renderQRCode(layer, absX, absY)
+		err = g.renderQRCode(layer, absX, absY)
+	case "barcode":
+		err = g.renderBarcode(layer, absX, absY)
 	case "image":
-		return g.renderImage(layer, absX, absY)
+		err = g.renderImage(layer, absX, absY)
 	case "container":
-		return g.renderContainer(layer, absX, absY)
+		err = g.renderContainer(layer, absX, absY)
 	case "shape":
 		return g.renderShape(layer, absX, absY)
 	default:
 		// Unknown layer type, skip
 		return nil
 	}
+	
+	// Borders go on top of the content, like CSS borders over a child that overflows
+	g.renderBorder(layer, absX, absY)
+	
+	return err
+}
+
+// normalizeRotation maps an angle in degrees to (-180, 180], treating tiny angles as 0
+func normalizeRotation(deg float64) float64 {
+	deg = math.Mod(deg, 360)
+	if deg > 180 {
+		deg -= 360
+	} else if deg <= -180 {
+		deg += 360
+	}
+	if math.Abs(deg) < 0.01 {
+		return 0
+	}
+	return deg
+}
+
+// rotatedContentSize returns the box a rotated layer is laid out in and the
+// uniform scale that makes its rotated bounds fit inside the original box.
+// Layers turned closer to vertical than horizontal (e.g. side-stripe labels at
+// 90°) are laid out with width and height swapped, so a tall narrow box holds a
+// long line of text running up or down the badge.
+func rotatedContentSize(box models.Size, deg float64) (models.Size, float64) {
+	rad := deg * math.Pi / 180
+	sin := math.Abs(math.Sin(rad))
+	cos := math.Abs(math.Cos(rad))
+	
+	content := box
+	if sin > cos {
+		content = models.Size{Width: box.Height, Height: box.Width}
+	}
+	
+	// Axis-aligned bounds of the rotated content
+	boundsW := content.Width*cos + content.Height*sin
+	boundsH := content.Width*sin + content.Height*cos
+	
+	scale := 1.0
+	if boundsW > 0 && boundsH > 0 {
+		scale = math.Min(box.Width/boundsW, box.Height/boundsH)
+	}
+	if scale > 1 {
+		scale = 1
+	}
+	
+	return content, scale
 }
 
 // renderText renders a text layer
@@ -172,65 +366,34 @@ func (g *PDFGenerator) renderText(layer models.Layer, x, y float64) error {
 		return nil
 	}
 	
-	// Set font style - support bold and normal
-	fontStyle := ""
-	if layer.Style.FontWeight == "bold" || layer.Style.FontWeight == "700" {
-		fontStyle = "B"
-	}
-	// "normal", "400", or empty = regular weight (default)
-	
-	// Calculate font size - test both conversion methods
-	// Template font sizes might be in pixels (px) or points (pt)
-	// Method 1 (if px): pt = px * (72 / DPI) = px * 0.24 for 300 DPI
-	// Method 2 (if pt): use directly without conversion
-	fontSizeAsPx := layer.Style.FontSize * (72.0 / float64(g.dpi))
-	fontSizeAsPt := layer.Style.FontSize // Direct use (if already in points)
-	
-	// Check environment variable to determine which method to use
-	// FONT_SIZE_UNIT=pt means use directly, =px means convert, =auto means test both
-	fontSizeUnit := os.Getenv("FONT_SIZE_UNIT")
-	if fontSizeUnit == "" {
-		fontSizeUnit = "px" // Default to px conversion
-	}
+	// Arabic letters must be converted to their contextual forms before measuring.
+	// The text stays in logical order here; lines are reordered for display as they are drawn.
+	text = shapeArabic(text)
+	rtl := g.isRTLParagraph(text)
 	
-	var fontSize float64
-	if fontSizeUnit == "pt" {
-		// Use directly as points (no conversion)
-		fontSize = fontSizeAsPt
-	} else if fontSizeUnit == "auto" {
-		// Test both - use px for now but log both
-		fontSize = fontSizeAsPx
-	} else {
-		// Default: px conversion
-		fontSize = fontSizeAsPx
-	}
-	
-	// Clamp font size to reasonable bounds
-	if fontSize < 4 {
-		fontSize = 4
-	}
-	if fontSize > 72 {
-		fontSize = 72
-	}
+	fontSize := g.layerFontSize(layer)
 	
 	// Set font first (needed for width calculations)
-	fontFamily := layer.Style.FontFamily
-	if fontFamily == "" {
-		fontFamily = "Arial"
-	}
+	// The chain holds the layer font followed by fallbacks for glyphs it doesn't have
+	fc := g.buildFontChain(layer.Style.FontFamily, layer.Style.FontWeight, fonts.IsItalic(layer.Style.FontStyle))
 	
 	// Auto font size: fit text to box
 	// Pass the base fontSize (converted) as maximum to respect template intent
 	if layer.AutoFontSize {
 		// Use the converted fontSize as maximum - don't ignore template's intent
-		fontSize = g.calculateAutoFontSize(text, layer.Size.Width, layer.Size.Height, fontFamily, fontStyle, fontSize)
+		fontSize = g.calculateAutoFontSize(text, layer.Size.Width, layer.Size.Height, fc, fontSize)
 	}
 	
-	g.pdf.SetFont(fontFamily, fontStyle, fontSize)
+	g.useFont(fc.primary(), fontSize)
 	
-	// Set text color
-	r, gr, b := hexToRGB(layer.Style.Color)
+	// Set text color; its alpha multiplies with the layer opacity
+	r, gr, b, colorAlpha, ok := foregroundColor(layer)
+	if !ok {
+		return nil
+	}
 	g.pdf.SetTextColor(r, gr, b)
+	g.setAlpha(g.alpha * colorAlpha)
+	defer g.setAlpha(g.alpha) // the border drawn next uses the layer opacity
 	
 	// Determine alignment - gofpdf format: Horizontal + Vertical
 	// Horizontal: L (left), C (center), R (right)
@@ -243,20 +406,38 @@ func (g *PDFGenerator) renderText(layer models.Layer, x, y float64) error {
 		alignStr = "RM" // Right, Middle
 	case "left":
 		alignStr = "LM" // Left, Middle
+	case "end":
+		if !rtl {
+			alignStr = "RM"
+		}
+	default:
+		// "start" or unset follows the paragraph direction
+		if rtl {
+			alignStr = "RM"
+		}
+	}
+	
+	tl := textLayout{
+		fonts:      fc,
+		size:       fontSize,
+		align:      alignStr,
+		rtl:        rtl,
+		decoration: parseTextDecoration(layer.Style.TextDecoration),
+		color:      [3]int{r, gr, b},
 	}
 	
 	// Set position
 	g.pdf.SetXY(x, y)
 	
 	// Check if text needs wrapping (exceeds cell width)
-	textWidth := g.pdf.GetStringWidth(text)
-	needsWrapping := textWidth > layer.Size.Width*0.95
+	textWidth := g.measureText(fc, text, fontSize)
+	needsWrapping := textWidth > layer.Size.Width*textWrapThreshold
 	
 	// Handle multi-line text (explicit newlines)
 	if strings.Contains(text, "\n") {
 		lines := strings.Split(text, "\n")
 		// Calculate proper line height based on font size (1.2x for spacing)
-		lineHeight := fontSize * 1.2
+		lineHeight := fontSize * textLineHeight
 		if lineHeight > layer.Size.Height/float64(len(lines)) {
 			lineHeight = layer.Size.Height / float64(len(lines))
 		}
@@ -269,245 +450,462 @@ func (g *PDFGenerator) renderText(layer models.Layer, x, y float64) error {
 			g.pdf.SetXY(x, currentY)
 			
 			// Check if this line needs wrapping
-			lineWidth := g.pdf.GetStringWidth(line)
-			if lineWidth > layer.Size.Width*0.95 {
+			lineWidth := g.measureText(fc, line, fontSize)
+			if lineWidth > layer.Size.Width*textWrapThreshold {
 				// Use MultiCell for wrapping
-				g.pdf.MultiCell(layer.Size.Width, lineHeight, line, "", alignStr, false)
+				g.multiCell(tl, layer.Size.Width, lineHeight, line)
 			} else {
 				// Use CellFormat for single line
-				g.pdf.CellFormat(layer.Size.Width, lineHeight, line, "", 0, alignStr, false, 0, "")
+				g.cellText(tl, layer.Size.Width, lineHeight, line)
 			}
 		}
 	} else if needsWrapping {
 		// Text is too long, use MultiCell for automatic word wrapping
 		// Calculate line height based on font size
-		lineHeight := fontSize * 1.2
+		lineHeight := fontSize * textLineHeight
 		if lineHeight > layer.Size.Height {
 			lineHeight = layer.Size.Height
 		}
-		g.pdf.MultiCell(layer.Size.Width, lineHeight, text, "", alignStr, false)
+		g.multiCell(tl, layer.Size.Width, lineHeight, text)
 	} else {
 		// Single line text that fits - use CellFormat
-		g.pdf.CellFormat(layer.Size.Width, layer.Size.Height, text, "", 0, alignStr, false, 0, "")
+		g.cellText(tl, layer.Size.Width, layer.Size.Height, text)
 	}
 	
 	return nil
 }
 
-// renderQRCode generates and renders a QR code
-func (g *PDFGenerator) renderQRCode(layer models.Layer, x, y float64) error {
-	// Note: visibility is already checked in renderLayer, but check opacity here
-	opacity := layer.Style.Opacity
-	if opacity == 0 {
-		return nil // Fully transparent, skip rendering
-	}
+// layerFontSize converts the layer's font size to points
+func (g *PDFGenerator) layerFontSize(layer models.Layer) float64 {
+	// Calculate font size - test both conversion methods
+	// Template font sizes might be in pixels (px) or points (pt)
+	// Method 1 (if px): pt = px * (72 / DPI) = px * 0.24 for 300 DPI
+	// Method 2 (if pt): use directly without conversion
+	fontSizeAsPx := layer.Style.FontSize * (72.0 / float64(g.dpi))
+	fontSizeAsPt := layer.Style.FontSize // Direct use (if already in points)
 	
-	// Generate QR content - resolve placeholders first
-	qrContent := g.resolvePlaceholders(layer.Content)
+	// Check environment variable to determine which method to use
+	// FONT_SIZE_UNIT=pt means use directly, =px means convert, =auto means test both
+	fontSizeUnit := os.Getenv("FONT_SIZE_UNIT")
+	if fontSizeUnit == "" {
+		fontSizeUnit = "px" // Default to px conversion
+	}
 	
-	// If content is empty or still has unresolved placeholders, use user identifier
-	if qrContent == "" || strings.Contains(qrContent, "{{") {
-		qrContent = g.user.Identifier
+	var fontSize float64
+	if fontSizeUnit == "pt" {
+		// Use directly as points (no conversion)
+		fontSize = fontSizeAsPt
+	} else if fontSizeUnit == "auto" {
+		// Test both - use px for now but log both
+		fontSize = fontSizeAsPx
+	} else {
+		// Default: px conversion
+		fontSize = fontSizeAsPx
 	}
 	
-	// Fallback to user ID if identifier is also empty
-	if qrContent == "" {
-		qrContent = g.user.ID
+	// Clamp font size to reasonable bounds
+	if fontSize < 4 {
+		fontSize = 4
+	}
+	if fontSize > 72 {
+		fontSize = 72
 	}
 	
-	// Ensure we have content to encode
-	if qrContent == "" {
-		return fmt.Errorf("QR code content is empty")
+	return fontSize
+}
+
+// resolveFont maps a layer's fontFamily/fontWeight/fontStyle to a face from the font registry.
+// Numeric weights pick the closest face the family has (see fonts.Resolve).
+// When no registered font is available it falls back to the PDF core fonts.
+func (g *PDFGenerator) resolveFont(family, weight string, italic bool) fontRef {
+	w := fonts.ParseWeight(weight)
+	
+	if face := fonts.Resolve(family, w, italic); face != nil {
+		_, err := face.Data()
+		if err == nil {
+			return fontRef{family: face.ID, face: face}
+		}
+		fmt.Fprintf(os.Stderr, "Failed to load font %s: %v\n", face.Path, err)
 	}
 	
-	// Calculate QR size in pixels based on DPI and layer size (mm to pixels)
-	// Use the larger dimension to ensure quality
-	widthMM := layer.Size.Width
-	heightMM := layer.Size.Height
-	if heightMM > widthMM {
-		widthMM = heightMM
+	// Core font fallback (Helvetica metrics, no Unicode)
+	style := ""
+	if w >= 600 {
+		style = "B"
+	}
+	if italic {
+		style += "I"
+	}
+	return fontRef{family: "Arial", style: style}
+}
+
+// isRTLParagraph decides the base direction for a piece of text.
+// Templates with rtlSupport or an RTL defaultLanguage treat any text containing
+// Arabic/Hebrew as right-to-left; otherwise the first strong character decides.
+// Pure Latin text is always left-to-right so names like "Dr. Smith" keep their punctuation.
+func (g *PDFGenerator) isRTLParagraph(text string) bool {
+	if !containsRTL(text) {
+		return false
+	}
+	settings := g.template.Design.Settings
+	if settings.RTLSupport || isRTLLanguage(settings.DefaultLanguage) {
+		return true
+	}
+	return paragraphIsRTL(text, directionAuto)
+}
+
+// visualOrder converts a single line from logical to display order
+func (g *PDFGenerator) visualOrder(line string, rtl bool) string {
+	if !rtl && !containsRTL(line) {
+		return line
+	}
+	return reorderBidi(line, rtl)
+}
+
+// cellText draws a single line like CellFormat at the current position.
+// Lines that need fallback fonts are drawn run by run.
+func (g *PDFGenerator) cellText(tl textLayout, w, h float64, line string) {
+	x, y := g.pdf.GetXY()
+	visual := g.visualOrder(line, tl.rtl)
+	if tl.fonts.needsFallback(visual) {
+		g.drawRuns(tl.fonts, w, h, visual, tl.align, tl.size)
+	} else {
+		g.pdf.CellFormat(w, h, visual, "", 0, tl.align, false, 0, "")
 	}
 	
-	// Convert mm to pixels: pixels = (mm * DPI) / 25.4
-	qrSize := int((widthMM * float64(g.dpi)) / 25.4)
+	if tl.decoration.any() {
+		g.drawTextDecoration(tl, x, y, w, h, g.measureText(tl.fonts, visual, tl.size))
+		g.pdf.SetXY(x+w, y)
+	}
+}
+
+// multiCell wraps text like gofpdf's MultiCell. Bidirectional, mixed-font or
+// decorated text is broken into lines in logical order first and each line is
+// reordered and drawn on its own, otherwise the words of a wrapped RTL sentence
+// would end up on the wrong lines, widths would be measured with the wrong font
+// and underlines wouldn't follow the wrapped lines.
+func (g *PDFGenerator) multiCell(tl textLayout, w, h float64, text string) {
+	if !tl.rtl && !containsRTL(text) && !tl.fonts.needsFallback(text) && !tl.decoration.any() {
+		g.pdf.MultiCell(w, h, text, "", tl.align, false)
+		return
+	}
 	
-	// Ensure minimum size for quality
-	if qrSize < 100 {
-		qrSize = 100
+	x, y := g.pdf.GetXY()
+	lines := g.splitLines(tl, text, w)
+	for i, line := range lines {
+		g.pdf.SetXY(x, y+float64(i)*h)
+		g.cellText(tl, w, h, line)
+	}
+	g.pdf.SetXY(x, y+float64(len(lines))*h)
+}
+
+// splitLines breaks logical-order text into lines that fit within width
+func (g *PDFGenerator) splitLines(tl textLayout, text string, width float64) []string {
+	maxWidth := width - 2*g.pdf.GetCellMargin()
+	words := strings.Fields(text)
+	
+	var lines []string
+	current := ""
+	for _, word := range words {
+		candidate := word
+		if current != "" {
+			candidate = current + " " + word
+		}
+		if current != "" && g.measureText(tl.fonts, candidate, tl.size) > maxWidth {
+			lines = append(lines, current)
+			current = word
+			continue
+		}
+		current = candidate
 	}
-	// Cap maximum size for performance
-	if qrSize > 1024 {
-		qrSize = 1024
+	if current != "" {
+		lines = append(lines, current)
 	}
 	
-	// Generate QR code directly to bytes (in-memory, no file I/O)
-	qrBytes, err := qrcode.Encode(qrContent, qrcode.Medium, qrSize)
-	if err != nil {
-		return fmt.Errorf("failed to generate QR code: %w", err)
+	return lines
+}
+
+// renderQRCode draws a QR code as vector modules, square and centered in the
+// layer box, with an optional logo from the template assets in the middle
+func (g *PDFGenerator) renderQRCode(layer models.Layer, x, y float64) error {
+	// Note: visibility and opacity are already handled in renderLayer
+	
+	r, gr, b, colorAlpha, ok := foregroundColor(layer)
+	if !ok {
+		return nil
 	}
 	
-	// Generate unique image name for gofpdf registration
-	// Include layer ID to ensure uniqueness even if content is the same
-	hash := md5.Sum([]byte(qrContent + layer.ID))
-	imageName := fmt.Sprintf("qr_%s_%x", layer.ID, hash[:8])
+	bitmap, err := g.qrBitmap(layer)
+	if err != nil {
+		return err
+	}
 	
-	// Register the QR code image with gofpdf using raw bytes (no file I/O)
-	info := g.pdf.RegisterImageOptionsReader(imageName, gofpdf.ImageOptions{
-		ImageType: "PNG", // QR codes are always PNG
-	}, bytes.NewReader(qrBytes))
+	// Square modules; the symbol and its quiet zone fill the shorter side
+	n := float64(len(bitmap))
+	module, quietZone := qrModule(layer, len(bitmap))
+	originX := x + (layer.Size.Width-n*module)/2
+	originY := y + (layer.Size.Height-n*module)/2
+	
+	// Scanners need a light background; without a backgroundColor the code
+	// and its quiet zone are white, as the PNG codes used to be
+	if strings.TrimSpace(layer.Style.BackgroundColor) == "" {
+		side := (n + 2*quietZone) * module
+		g.pdf.SetFillColor(255, 255, 255)
+		g.pdf.Rect(x+(layer.Size.Width-side)/2, y+(layer.Size.Height-side)/2, side, side, "F")
+	}
 	
-	if info == nil {
-		return fmt.Errorf("failed to register QR code image (layer: %s, content: %s)", layer.ID, qrContent)
+	logo, hasLogo := QRLogoLayer(layer, g.template, len(bitmap))
+	if hasLogo {
+		clearModules(bitmap, qrLogoModules(len(bitmap), layer.QRCode.LogoSize))
 	}
 	
-	// Draw the registered QR code image
-	g.pdf.ImageOptions(
-		imageName,
-		x, y,
-		layer.Size.Width, layer.Size.Height,
-		false,
-		gofpdf.ImageOptions{ImageType: "PNG"},
-		0, "",
-	)
+	g.setAlpha(g.alpha * colorAlpha)
+	g.pdf.SetFillColor(r, gr, b)
+	g.fillModules(bitmap, originX, originY, module, module)
+	g.setAlpha(g.alpha)
 	
+	if hasLogo {
+		return g.renderImage(logo, x+logo.Position.X, y+logo.Position.Y)
+	}
 	return nil
 }
 
-// renderImage renders an image layer
-func (g *PDFGenerator) renderImage(layer models.Layer, x, y float64) error {
-	var imageURL string
+// qrBitmap encodes the content of a QR code layer for the current user and
+// returns its modules without the quiet zone
+func (g *PDFGenerator) qrBitmap(layer models.Layer) ([][]bool, error) {
+	var opts models.QRCode
+	if layer.QRCode != nil {
+		opts = *layer.QRCode
+	}
 	
-	// Check if this is an asset reference
-	if strings.HasPrefix(layer.Content, "asset_") {
-		// Try exact match first (for cases like "asset_0" matching "asset_0")
-		if url, ok := g.template.Assets[layer.Content]; ok {
-			imageURL = url
-		} else {
-			// Fallback: find asset URL with contains match (for timestamped keys like "asset_0_1763558759124")
-			for key, url := range g.template.Assets {
-				if strings.Contains(key, layer.Content) {
-					imageURL = url
-					break
-				}
+	// A logo hides part of the code; the highest level recovers up to 30%
+	level := qrRecoveryLevel(opts.ErrorCorrection)
+	if qrLogoURL(layer, g.template) != "" {
+		level = qrcode.Highest
+	}
+	
+	var qrContent string
+	switch strings.ToLower(opts.Mode) {
+	case "vcard", "mecard":
+		// Contact cards are built from the user's data
+		card, err := g.contactPayload(opts, qrCapacity[level])
+		if err != nil {
+			return nil, err
+		}
+		qrContent = card
+	default:
+		// Generate QR content - resolve placeholders first
+		qrContent = g.codeContent(layer)
+		
+		// Ensure we have content to encode
+		if qrContent == "" {
+			return nil, fmt.Errorf("QR code content is empty")
+		}
+		
+		// A signed token carries the content as its identifier
+		if opts.Signed {
+			signed, err := g.badgeToken(qrContent)
+			if err != nil {
+				return nil, fmt.Errorf("failed to sign QR code: %w", err)
 			}
+			qrContent = signed
 		}
-	} else if layer.DataBinding != "" {
-		// Get image URL from user data binding
-		fieldID := strings.TrimPrefix(layer.DataBinding, "customFields.")
-		imageURL = g.user.GetFieldValue(fieldID)
-	} else if layer.Content != "" && (strings.HasPrefix(layer.Content, "http://") || strings.HasPrefix(layer.Content, "https://")) {
-		imageURL = layer.Content
 	}
 	
+	qr, err := qrcode.New(qrContent, level)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate QR code: %w", err)
+	}
+	qr.DisableBorder = true // the quiet zone is laid out below
+	return qr.Bitmap(), nil
+}
+
+// codeContent resolves the content of a QR code or barcode layer, falling back
+// to the user identifier, then the user ID, when it is empty or unresolved
+func (g *PDFGenerator) codeContent(layer models.Layer) string {
+	content := g.resolvePlaceholders(layer.Content)
+	
+	// If content is empty or still has unresolved placeholders, use user identifier
+	if content == "" || strings.Contains(content, "{{") {
+		content = g.user.Identifier
+	}
+	
+	// Fallback to user ID if identifier is also empty
+	if content == "" {
+		content = g.user.ID
+	}
+	
+	return content
+}
+
+// renderImage renders an image layer
+func (g *PDFGenerator) renderImage(layer models.Layer, x, y float64) error {
+	imageURL := ImageURL(layer, g.template, g.user)
+	
 	// If image layer expects an image but URL is empty, skip rendering
 	// (some layers might be optional)
 	if imageURL == "" {
 		return nil // No image to render
 	}
 	
-	// Apply opacity check (gofpdf doesn't directly support opacity in ImageOptions)
-	// For opacity < 1, we would need to pre-process the image, but for now we'll render
-	// Opacity of 0 means fully transparent, skip rendering
-	opacity := layer.Style.Opacity
-	if opacity == 0 {
-		return nil // Fully transparent, skip rendering
-	}
+	// Opacity is applied by renderLayer through the PDF graphics state (/ca, /CA)
 	
-	// Note: Rotation is not directly supported by gofpdf's ImageOptions
-	// For rotation support, we would need to pre-process the image using imaging library
-	// For now, we'll render without rotation (most templates use rotation: 0)
+	// Rotation is applied by renderLayer as a PDF transform around the layer center
+	
+	req := NewImageRequest(layer, imageURL, g.dpi, g.template.Design.Settings.JPEGQuality)
 	
 	// PREFERRED: Use direct image data cache (raw bytes, fastest - no base64, no files)
-	if imageData, ok := g.imageDataCache[imageURL]; ok {
-		
-		// Generate unique image name for gofpdf registration
-		imageName := fmt.Sprintf("img_%s", strings.ReplaceAll(imageURL, "/", "_"))
-		imageName = strings.ReplaceAll(imageName, ":", "_")
-		imageName = strings.ReplaceAll(imageName, ".", "_")
-		// Add hash to ensure uniqueness (use first 8 bytes of MD5)
-		hash := md5.Sum([]byte(imageURL))
-		imageName = fmt.Sprintf("%s_%x", imageName, hash[:8])
-		
-		// Register the image with gofpdf using raw bytes (no base64 encoding/decoding)
-		info := g.pdf.RegisterImageOptionsReader(imageName, gofpdf.ImageOptions{
-			ImageType: "PNG", // All processed images are PNG
-		}, bytes.NewReader(imageData))
-		
-		if info == nil {
-			return fmt.Errorf("layer '%s': failed to register image data", layer.ID)
+	imageData, ok := g.imageDataCache[req.Key()]
+	if !ok {
+		// FALLBACK: Download and process on-demand if not in cache
+		var err error
+		imageData, err = cache.GetImageDataDirect(req)
+		if err != nil {
+			return fmt.Errorf("layer '%s': failed to get image data on-demand: %w", layer.ID, err)
 		}
-		
-		// Draw the registered image
-		g.pdf.ImageOptions(
-			imageName,
-			x, y,
-			layer.Size.Width, layer.Size.Height,
-			false,
-			gofpdf.ImageOptions{ImageType: "PNG"},
-			0, "",
-		)
-		return nil
 	}
 	
-	// FALLBACK: Download and process on-demand if not in cache
-	imageData, err2 := cache.GetImageDataDirect(imageURL, layer.Size.Width, layer.Size.Height, g.dpi)
-	if err2 != nil {
-		return fmt.Errorf("layer '%s': failed to get image data on-demand: %w", layer.ID, err2)
+	// SVG assets are drawn as vector paths rather than an image
+	if cache.IsSVG(imageData) {
+		return g.renderSVG(layer, x, y, imageData)
 	}
 	
-	// Generate unique image name
+	// Generate unique image name for gofpdf registration. The same URL can be
+	// processed differently per layer (size, fit mode), so the name hashes the full key.
 	imageName := fmt.Sprintf("img_%s", strings.ReplaceAll(imageURL, "/", "_"))
 	imageName = strings.ReplaceAll(imageName, ":", "_")
 	imageName = strings.ReplaceAll(imageName, ".", "_")
-	hash := md5.Sum([]byte(imageURL))
+	// Add hash to ensure uniqueness (use first 8 bytes of MD5)
+	hash := md5.Sum([]byte(req.Key()))
 	imageName = fmt.Sprintf("%s_%x", imageName, hash[:8])
 	
-	// Register the image with gofpdf
+	// Register the image with gofpdf using raw bytes (no base64 encoding/decoding).
+	// JPEGs are embedded as they are (DCT), everything else is PNG.
+	imageType := cache.ImageType(imageData)
 	info := g.pdf.RegisterImageOptionsReader(imageName, gofpdf.ImageOptions{
-		ImageType: "PNG",
+		ImageType: imageType,
 	}, bytes.NewReader(imageData))
 	
 	if info == nil {
-		return fmt.Errorf("layer '%s': failed to register image data on-demand", layer.ID)
+		return fmt.Errorf("layer '%s': failed to register image data", layer.ID)
+	}
+	
+	// Masks (and borderRadius) clip the picture with a vector path instead of
+	// baking transparency into the PNG
+	if geo := layerGeometry(layer, x, y); geo.kind != shapeRect {
+		defer g.clipGeometry(geo)()
 	}
 	
-	// Draw the registered image
+	// Draw the registered image where the fit mode puts it
+	info.SetDpi(float64(g.dpi))
+	drawX, drawY, drawW, drawH := placeImage(layer, x, y, info.Width(), info.Height())
 	g.pdf.ImageOptions(
 		imageName,
-		x, y,
-		layer.Size.Width, layer.Size.Height,
+		drawX, drawY,
+		drawW, drawH,
 		false,
-		gofpdf.ImageOptions{ImageType: "PNG"},
+		gofpdf.ImageOptions{ImageType: imageType},
 		0, "",
 	)
 	return nil
 }
 
+// ImageURL returns the URL an image layer draws for a user: a template asset
+// (content "asset_..."), the field named by dataBinding, or an http(s) URL in
+// the content. Placeholders in the binding and the URL are resolved, so
+// "https://cdn.example.com/photos/{{identifier}}.jpg" works.
+func ImageURL(layer models.Layer, template *models.Template, user *models.User) string {
+	resolve := fieldResolver(template, user)
+	
+	// Check if this is an asset reference
+	if strings.HasPrefix(layer.Content, "asset_") {
+		return assetURL(template, layer.Content)
+	}
+	
+	if layer.DataBinding != "" {
+		// A binding is a placeholder body ("customFields.<id>", "photo | default:'...'")
+		// or a template of its own
+		binding := layer.DataBinding
+		if !strings.Contains(binding, "{{") {
+			binding = "{{" + binding + "}}"
+		}
+		return strings.TrimSpace(expr.Render(binding, resolve))
+	}
+	
+	url := strings.TrimSpace(expr.Render(layer.Content, resolve))
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		return url
+	}
+	return ""
+}
+
+// assetURL returns the URL of a template asset, or "" when there is none
+func assetURL(template *models.Template, name string) string {
+	// Try exact match first (for cases like "asset_0" matching "asset_0")
+	if url, ok := template.Assets[name]; ok {
+		return url
+	}
+	// Fallback: find asset URL with contains match (for timestamped keys like "asset_0_1763558759124")
+	for key, url := range template.Assets {
+		if strings.Contains(key, name) {
+			return url
+		}
+	}
+	return ""
+}
+
+// NewImageRequest describes how an image layer's picture is processed by the
+// cache. ImageRequests builds the same requests for preloading so renderImage
+// finds them by key.
+func NewImageRequest(layer models.Layer, imageURL string, dpi, jpegQuality int) cache.ImageRequest {
+	focusX, focusY := layer.Focus()
+	return cache.ImageRequest{
+		URL:     imageURL,
+		Width:   layer.Size.Width,
+		Height:  layer.Size.Height,
+		DPI:     dpi,
+		Fit:     layer.ObjectFit(),
+		FocusX:  focusX,
+		FocusY:  focusY,
+		Quality: jpegQuality,
+	}
+}
+
+// placeImage returns where a processed image of imgW x imgH (mm at the template
+// DPI) is drawn in the layer box. fill and cover images cover the box; contain
+// and none images keep their aspect ratio and are aligned by the focal point.
+func placeImage(layer models.Layer, x, y, imgW, imgH float64) (float64, float64, float64, float64) {
+	boxW, boxH := layer.Size.Width, layer.Size.Height
+	if imgW <= 0 || imgH <= 0 {
+		return x, y, boxW, boxH
+	}
+	
+	var w, h float64
+	switch layer.ObjectFit() {
+	case cache.FitContain:
+		scale := math.Min(boxW/imgW, boxH/imgH)
+		w, h = imgW*scale, imgH*scale
+	case cache.FitNone:
+		w, h = math.Min(imgW, boxW), math.Min(imgH, boxH)
+	default:
+		return x, y, boxW, boxH
+	}
+	
+	focusX, focusY := layer.Focus()
+	return x + (boxW-w)*focusX, y + (boxH-h)*focusY, w, h
+}
+
 // renderContainer renders a container with child layers
 func (g *PDFGenerator) renderContainer(layer models.Layer, x, y float64) error {
 	if len(layer.Children) == 0 {
 		return nil
 	}
 	
-	// Calculate child positions based on container layout
-	layout := layer.ContainerLayout
-	if layout == nil {
-		// Default layout: stack vertically
-		layout = &models.ContainerLayout{
-			Type:          "flex",
-			FlexDirection: "column",
-		}
-	}
-	
-	// Calculate positions for flex layout
-	childPositions := g.calculateFlexPositions(layer, layout)
+	// Calculate child positions and sizes based on the container layout
+	childPositions, childSizes := g.layoutChildren(layer)
 	
 	// Render children
 	for i, child := range layer.Children {
-		if !child.Visible {
-			continue
-		}
-		
 		childX := x
 		childY := y
 		
@@ -515,6 +913,9 @@ func (g *PDFGenerator) renderContainer(layer models.Layer, x, y float64) error {
 			childX = x + childPositions[i].X
 			childY = y + childPositions[i].Y
 		}
+		if i < len(childSizes) {
+			child.Size = childSizes[i]
+		}
 		
 		if err := g.renderLayer(child, models.Position{X: childX, Y: childY}); err != nil {
 			// Continue rendering other children even if one fails
@@ -524,125 +925,54 @@ func (g *PDFGenerator) renderContainer(layer models.Layer, x, y float64) error {
 	return nil
 }
 
-// renderShape renders a shape layer (rectangle, etc.)
+// renderShape renders a shape layer: rectangle, rounded rectangle, ellipse,
+// circle, line or polygon, filled with backgroundColor and stroked with the border
 func (g *PDFGenerator) renderShape(layer models.Layer, x, y float64) error {
-	if layer.Style.BackgroundColor == "" || layer.Style.BackgroundColor == "transparent" {
-		return nil
-	}
+	geo := layerGeometry(layer, x, y)
+	g.fillGeometry(geo, layer.Style)
 	
-	r, gr, b := hexToRGB(layer.Style.BackgroundColor)
-	g.pdf.SetFillColor(r, gr, b)
-	g.pdf.Rect(x, y, layer.Size.Width, layer.Size.Height, "F")
+	stroke, ok := layerStroke(layer.Style)
+	if geo.kind == shapeLine {
+		stroke, ok = lineStroke(layer.Style)
+	}
+	if ok {
+		g.strokeGeometry(geo, stroke)
+	}
 	
 	return nil
 }
 
-// calculateFlexPositions calculates positions for children in a flex container
-func (g *PDFGenerator) calculateFlexPositions(container models.Layer, layout *models.ContainerLayout) []models.Position {
-	positions := make([]models.Position, len(container.Children))
-	
-	if len(container.Children) == 0 {
-		return positions
-	}
-	
-	isRow := layout.FlexDirection == "row"
-	gap := float64(layout.FlexGap)
-	
-	// Calculate total size of children
-	var totalSize float64
-	for _, child := range container.Children {
-		if isRow {
-			totalSize += child.Size.Width
-		} else {
-			totalSize += child.Size.Height
-		}
-	}
-	totalSize += gap * float64(len(container.Children)-1)
-	
-	// Calculate starting position based on justify-content
-	containerSize := container.Size.Width
-	if !isRow {
-		containerSize = container.Size.Height
-	}
-	
-	var startPos float64
-	var spacing float64
-	
-	switch layout.JustifyContent {
-	case "center":
-		startPos = (containerSize - totalSize) / 2
-	case "flex-end":
-		startPos = containerSize - totalSize
-	case "space-between":
-		if len(container.Children) > 1 {
-			spacing = (containerSize - totalSize + gap*float64(len(container.Children)-1)) / float64(len(container.Children)-1)
-		}
-	case "space-around":
-		spacing = (containerSize - totalSize + gap*float64(len(container.Children)-1)) / float64(len(container.Children)*2)
-		startPos = spacing
-	case "space-evenly":
-		spacing = (containerSize - totalSize + gap*float64(len(container.Children)-1)) / float64(len(container.Children)+1)
-		startPos = spacing
-	default: // flex-start
-		startPos = 0
-	}
-	
-	// Calculate cross-axis alignment
-	crossAlign := func(childSize, containerCrossSize float64) float64 {
-		switch layout.AlignItems {
-		case "center":
-			return (containerCrossSize - childSize) / 2
-		case "flex-end":
-			return containerCrossSize - childSize
-		default: // flex-start
-			return 0
-		}
+// renderBackground fills the layer box (rounded by borderRadius) with backgroundColor
+func (g *PDFGenerator) renderBackground(layer models.Layer, x, y float64) {
+	g.fillGeometry(layerGeometry(layer, x, y), layer.Style)
+}
+
+// renderBorder strokes the layer box on top of the layer content
+func (g *PDFGenerator) renderBorder(layer models.Layer, x, y float64) {
+	if stroke, ok := layerStroke(layer.Style); ok {
+		g.strokeGeometry(layerGeometry(layer, x, y), stroke)
 	}
-	
-	// Assign positions
-	currentPos := startPos
-	for i, child := range container.Children {
-		if isRow {
-			positions[i] = models.Position{
-				X: currentPos,
-				Y: crossAlign(child.Size.Height, container.Size.Height),
-			}
-			currentPos += child.Size.Width + gap
-			if spacing > 0 {
-				currentPos += spacing - gap
-			}
-		} else {
-			positions[i] = models.Position{
-				X: crossAlign(child.Size.Width, container.Size.Width),
-				Y: currentPos,
-			}
-			currentPos += child.Size.Height + gap
-			if spacing > 0 {
-				currentPos += spacing - gap
-			}
-		}
+}
+
+// setAlpha sets the fill and stroke opacity for everything drawn next
+func (g *PDFGenerator) setAlpha(alpha float64) {
+	alpha = math.Max(0, math.Min(1, alpha))
+	if alpha == g.gsAlpha {
+		return
 	}
-	
-	return positions
+	g.pdf.SetAlpha(alpha, "Normal")
+	g.gsAlpha = alpha
 }
 
-// resolvePlaceholders replaces {{customFields.xxx}} with actual values
+// resolvePlaceholders resolves {{ }} placeholders (user fields, custom fields
+// by ID or name, names from the template's placeholders map, filters and
+// defaults) against the user's data
 func (g *PDFGenerator) resolvePlaceholders(content string) string {
 	if content == "" {
 		return ""
 	}
 	
-	result := content
-	
-	// Use pre-compiled regex pattern
-	result = placeholderRegex.ReplaceAllStringFunc(result, func(match string) string {
-		matches := placeholderRegex.FindStringSubmatch(match)
-		if len(matches) < 2 {
-			return ""
-		}
-		fieldID := matches[1]
-		return g.user.GetFieldValue(fieldID)
-	})
+	result := expr.Render(content, fieldResolver(g.template, g.user))
 	
 	// Clean up extra spaces using pre-compiled regex
 	result = strings.TrimSpace(result)
@@ -652,7 +982,7 @@ func (g *PDFGenerator) resolvePlaceholders(content string) string {
 }
 
 // calculateAutoFontSize calculates the optimal font size to fit text within given dimensions
-func (g *PDFGenerator) calculateAutoFontSize(text string, width, height float64, fontFamily, fontStyle string, maxFontSize float64) float64 {
+func (g *PDFGenerator) calculateAutoFontSize(text string, width, height float64, fc fontChain, maxFontSize float64) float64 {
 	if maxFontSize <= 0 {
 		maxFontSize = 72 // Default max if not specified
 	}
@@ -675,10 +1005,9 @@ func (g *PDFGenerator) calculateAutoFontSize(text string, width, height float64,
 	
 	for maxSize-minSize > 0.1 {
 		testSize := (minSize + maxSize) / 2
-		g.pdf.SetFont(fontFamily, fontStyle, testSize)
-		textWidth := g.pdf.GetStringWidth(text)
+		textWidth := g.measureText(fc, text, testSize)
 		
-		if textWidth <= width*0.95 {
+		if textWidth <= width*textWrapThreshold {
 			minSize = testSize
 		} else {
 			maxSize = testSize
@@ -690,15 +1019,111 @@ func (g *PDFGenerator) calculateAutoFontSize(text string, width, height float64,
 
 // ============ HELPER FUNCTIONS ============
 
-func hexToRGB(hex string) (int, int, int) {
-	hex = strings.TrimPrefix(hex, "#")
-	if len(hex) != 6 {
-		return 0, 0, 0
+// foregroundColor returns the color a layer draws its text or code with:
+// black when the layer has no color. ok is false for "transparent" and for
+// colors that don't parse, which are logged; the layer draws nothing then.
+func foregroundColor(layer models.Layer) (r, g, b int, alpha float64, ok bool) {
+	color := strings.TrimSpace(layer.Style.Color)
+	if color == "" {
+		return 0, 0, 0, 1, true
+	}
+	r, g, b, alpha, ok = parseColor(color)
+	if !ok && !strings.EqualFold(color, "transparent") && !strings.EqualFold(color, "none") {
+		logOnce("Invalid color %q for layer %s\n", color, layer.ID)
+	}
+	return r, g, b, alpha, ok
+}
+
+// maxLoggedWarnings bounds the warnings logOnce remembers
+const maxLoggedWarnings = 1024
+
+var (
+	loggedMu       sync.Mutex
+	loggedWarnings = make(map[string]bool)
+)
+
+// logOnce writes a warning to stderr the first time it is seen, so a broken
+// template doesn't log the same line for every badge. The remembered
+// warnings are forgotten once there are maxLoggedWarnings of them.
+func logOnce(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	loggedMu.Lock()
+	defer loggedMu.Unlock()
+	if loggedWarnings[msg] {
+		return
+	}
+	if len(loggedWarnings) >= maxLoggedWarnings {
+		loggedWarnings = make(map[string]bool)
+	}
+	loggedWarnings[msg] = true
+	fmt.Fprint(os.Stderr, msg)
+}
+
+// parseColor parses CSS color names, #RGB, #RRGGBB, #RRGGBBAA, rgb() and
+// rgba() colors. ok is false for empty, "transparent" and unparseable values.
+func parseColor(color string) (r, g, b int, alpha float64, ok bool) {
+	color = strings.ToLower(strings.TrimSpace(color))
+	if color == "" || color == "transparent" || color == "none" {
+		return 0, 0, 0, 0, false
+	}
+	
+	if c, named := colornames.Map[color]; named {
+		return int(c.R), int(c.G), int(c.B), 1, true
+	}
+	
+	if strings.HasPrefix(color, "rgb") {
+		open := strings.Index(color, "(")
+		closing := strings.LastIndex(color, ")")
+		if open == -1 || closing < open {
+			return 0, 0, 0, 0, false
+		}
+		parts := strings.FieldsFunc(color[open+1:closing], func(c rune) bool {
+			return c == ',' || c == ' ' || c == '/'
+		})
+		if len(parts) < 3 {
+			return 0, 0, 0, 0, false
+		}
+		channels := make([]int, 3)
+		for i := 0; i < 3; i++ {
+			v, err := strconv.ParseFloat(strings.TrimSuffix(parts[i], "%"), 64)
+			if err != nil {
+				return 0, 0, 0, 0, false
+			}
+			if strings.HasSuffix(parts[i], "%") {
+				v = v * 255 / 100
+			}
+			channels[i] = int(math.Max(0, math.Min(255, v)))
+		}
+		alpha = 1
+		if len(parts) >= 4 {
+			a, err := strconv.ParseFloat(strings.TrimSuffix(parts[3], "%"), 64)
+			if err == nil {
+				if strings.HasSuffix(parts[3], "%") {
+					a /= 100
+				}
+				alpha = math.Max(0, math.Min(1, a))
+			}
+		}
+		return channels[0], channels[1], channels[2], alpha, true
+	}
+	
+	hex := strings.TrimPrefix(color, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 && len(hex) != 8 {
+		return 0, 0, 0, 0, false
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, 0, 0, 0, false
+	}
+	alpha = 1
+	if len(hex) == 8 {
+		alpha = float64(value&0xFF) / 255
+		value >>= 8
 	}
-	r, _ := strconv.ParseInt(hex[0:2], 16, 64)
-	gr, _ := strconv.ParseInt(hex[2:4], 16, 64)
-	b, _ := strconv.ParseInt(hex[4:6], 16, 64)
-	return int(r), int(gr), int(b)
+	return int(value >> 16 & 0xFF), int(value >> 8 & 0xFF), int(value & 0xFF), alpha, true
 }
 
 func getImageType(path string) string {
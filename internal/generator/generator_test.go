@@ -0,0 +1,128 @@
+package generator
+
+import (
+	"badge-service/internal/models"
+	"math"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		color   string
+		r, g, b int
+		alpha   float64
+		ok      bool
+	}{
+		{"#336699", 0x33, 0x66, 0x99, 1, true},
+		{"#369", 0x33, 0x66, 0x99, 1, true},
+		{"#33669980", 0x33, 0x66, 0x99, 128.0 / 255, true},
+		{"rgb(10, 20, 30)", 10, 20, 30, 1, true},
+		{"rgba(255, 0, 0, 0.25)", 255, 0, 0, 0.25, true},
+		{"rgb(100% 0% 0% / 50%)", 255, 0, 0, 0.5, true},
+		{"transparent", 0, 0, 0, 0, false},
+		{"", 0, 0, 0, 0, false},
+		{"#12345", 0, 0, 0, 0, false},
+		{"red", 255, 0, 0, 1, true},
+		{" White ", 255, 255, 255, 1, true},
+		{"reddish", 0, 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		r, g, b, alpha, ok := parseColor(tt.color)
+		if r != tt.r || g != tt.g || b != tt.b || math.Abs(alpha-tt.alpha) > 1e-9 || ok != tt.ok {
+			t.Errorf("parseColor(%q) = %d, %d, %d, %v, %v; want %d, %d, %d, %v, %v",
+				tt.color, r, g, b, alpha, ok, tt.r, tt.g, tt.b, tt.alpha, tt.ok)
+		}
+	}
+}
+
+func TestForegroundColor(t *testing.T) {
+	tests := []struct {
+		color   string
+		r, g, b int
+		alpha   float64
+		ok      bool
+	}{
+		{"", 0, 0, 0, 1, true},
+		{"  ", 0, 0, 0, 1, true},
+		{"#336699", 0x33, 0x66, 0x99, 1, true},
+		{"navy", 0, 0, 128, 1, true},
+		{"rgba(0, 0, 0, 0.5)", 0, 0, 0, 0.5, true},
+		{"transparent", 0, 0, 0, 0, false},
+		{"none", 0, 0, 0, 0, false},
+		{"#12345", 0, 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		layer := models.Layer{ID: "text", Style: models.Style{Color: tt.color}}
+		r, g, b, alpha, ok := foregroundColor(layer)
+		if r != tt.r || g != tt.g || b != tt.b || math.Abs(alpha-tt.alpha) > 1e-9 || ok != tt.ok {
+			t.Errorf("foregroundColor(%q) = %d, %d, %d, %v, %v; want %d, %d, %d, %v, %v",
+				tt.color, r, g, b, alpha, ok, tt.r, tt.g, tt.b, tt.alpha, tt.ok)
+		}
+	}
+}
+
+func TestOrient(t *testing.T) {
+	portrait := models.Size{Width: 210, Height: 297}
+	landscape := models.Size{Width: 297, Height: 210}
+	square := models.Size{Width: 100, Height: 100}
+	tests := []struct {
+		size        models.Size
+		orientation string
+		want        models.Size
+	}{
+		{portrait, "landscape", landscape},
+		{portrait, " L ", landscape},
+		{landscape, "landscape", landscape},
+		{landscape, "Portrait", portrait},
+		{portrait, "p", portrait},
+		{landscape, "", landscape},
+		{landscape, "sideways", landscape},
+		{square, "landscape", square},
+	}
+	for _, tt := range tests {
+		if got := orient(tt.size, tt.orientation); got != tt.want {
+			t.Errorf("orient(%v, %q) = %v, want %v", tt.size, tt.orientation, got, tt.want)
+		}
+	}
+}
+
+func TestPageInit(t *testing.T) {
+	tests := []struct {
+		size        models.Size
+		orientation string
+		wd, ht      float64
+	}{
+		{models.Size{Width: 105, Height: 148}, "P", 105, 148},
+		{models.Size{Width: 148, Height: 105}, "L", 105, 148},
+		{models.Size{Width: 90, Height: 90}, "P", 90, 90},
+	}
+	for _, tt := range tests {
+		init := pageInit(tt.size)
+		if init.OrientationStr != tt.orientation || init.Size.Wd != tt.wd || init.Size.Ht != tt.ht || init.UnitStr != "mm" {
+			t.Errorf("pageInit(%v) = %s %vx%v, want %s %vx%v", tt.size, init.OrientationStr, init.Size.Wd, init.Size.Ht, tt.orientation, tt.wd, tt.ht)
+		}
+	}
+}
+
+func TestBadgeSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		settings      models.Settings
+		width, height float64 // template width/height
+		wantW, wantH  float64
+	}{
+		{"landscape dimensions win over portrait", models.Settings{PaperWidth: 148, PaperHeight: 105, Orientation: "portrait"}, 0, 0, 148, 105},
+		{"portrait dimensions win over landscape", models.Settings{PaperWidth: 105, PaperHeight: 148, Orientation: "landscape"}, 0, 0, 105, 148},
+		{"template dimensions", models.Settings{Orientation: "portrait"}, 90, 55, 90, 55},
+		{"square", models.Settings{PaperWidth: 90, PaperHeight: 90, Orientation: "landscape"}, 0, 0, 90, 90},
+		{"default A4", models.Settings{}, 0, 0, 210, 297},
+		{"default A4 landscape", models.Settings{Orientation: "landscape"}, 0, 0, 297, 210},
+	}
+	for _, tt := range tests {
+		template := &models.Template{Width: tt.width, Height: tt.height}
+		template.Design.Settings = tt.settings
+		if w, h := badgeSize(template); w != tt.wantW || h != tt.wantH {
+			t.Errorf("%s: badgeSize = %vx%v, want %vx%v", tt.name, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package generator
+
+import (
+	"badge-service/internal/models"
+	"strings"
+)
+
+// CSS grid layout for containers with containerLayout.type "grid".
+//
+// Children are placed in cells row by row (or at their gridRow/gridColumn),
+// tracks are sized (fixed, percent, auto from the children, then fr shares of
+// what is left), the track block is aligned in the container with
+// justifyContent/alignContent and each child is aligned in its cell with
+// justifyItems/alignItems (or its own justifySelf/alignSelf).
+
+// gridItem is a child placed in the grid, in 0-based track indexes
+type gridItem struct {
+	index            int
+	row, col         int
+	rowSpan, colSpan int
+}
+
+// calculateGridLayout returns the position of every child relative to the
+// container, and its size, which differs from the child's own size when it is
+// stretched to its cell
+func (g *PDFGenerator) calculateGridLayout(container models.Layer, layout *models.ContainerLayout) ([]models.Position, []models.Size) {
+	positions := make([]models.Position, len(container.Children))
+	sizes := make([]models.Size, len(container.Children))
+	for i, child := range container.Children {
+		sizes[i] = child.Size
+	}
+
+	columns := layout.GridColumns
+	if len(columns) == 0 {
+		columns = models.GridTracks{{Kind: models.TrackFr, Value: 1}}
+	}
+
+	// Rows past the explicit ones and one per child can't be needed, so
+	// spans and positions are clamped to them
+	rowLimit := len(layout.GridRows) + len(container.Children)
+	items, rowCount := placeGridItems(container.Children, len(columns), rowLimit, g.isVisible)
+	if len(items) == 0 {
+		return positions, sizes
+	}
+
+	// Rows the template doesn't define are implicit auto rows
+	rows := append(models.GridTracks{}, layout.GridRows...)
+	for len(rows) < rowCount {
+		rows = append(rows, models.GridTrack{Kind: models.TrackAuto})
+	}
+
+	gap := layout.GridGap
+	colSizes := sizeGridTracks(columns, container.Size.Width, gap, items, layout.JustifyContent,
+		func(it gridItem) (int, int, float64) {
+			return it.col, it.colSpan, container.Children[it.index].Size.Width
+		})
+	rowSizes := sizeGridTracks(rows, container.Size.Height, gap, items, layout.AlignContent,
+		func(it gridItem) (int, int, float64) {
+			return it.row, it.rowSpan, container.Children[it.index].Size.Height
+		})
+
+	colStarts := gridTrackStarts(colSizes, container.Size.Width, gap, layout.JustifyContent)
+	rowStarts := gridTrackStarts(rowSizes, container.Size.Height, gap, layout.AlignContent)
+
+	for _, it := range items {
+		child := container.Children[it.index]
+
+		cellX := colStarts[it.col]
+		cellY := rowStarts[it.row]
+		cellW := colStarts[it.col+it.colSpan-1] + colSizes[it.col+it.colSpan-1] - cellX
+		cellH := rowStarts[it.row+it.rowSpan-1] + rowSizes[it.row+it.rowSpan-1] - cellY
+
+		justify := child.JustifySelf
+		if justify == "" {
+			justify = layout.JustifyItems
+		}
+		align := child.AlignSelf
+		if align == "" {
+			align = layout.AlignItems
+		}
+
+		x, w := alignInCell(cellX, cellW, child.Size.Width, justify)
+		y, h := alignInCell(cellY, cellH, child.Size.Height, align)
+		positions[it.index] = models.Position{X: x, Y: y}
+		sizes[it.index] = models.Size{Width: w, Height: h}
+	}
+
+	return positions, sizes
+}
+
+// placeGridItems assigns cells to the children that are visible. Children
+// with an explicit gridRow/gridColumn are placed there; the rest fill the
+// first free cells row by row. Row spans and explicit rows are clamped to
+// rowLimit rows. It returns the items and the number of rows used.
+func placeGridItems(children []models.Layer, columnCount, rowLimit int, visible func(models.Layer) bool) ([]gridItem, int) {
+	occupied := make(map[[2]int]bool)
+	fits := func(row, col, rowSpan, colSpan int) bool {
+		if col+colSpan > columnCount {
+			return false
+		}
+		for r := row; r < row+rowSpan; r++ {
+			for c := col; c < col+colSpan; c++ {
+				if occupied[[2]int{r, c}] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	var items []gridItem
+	rowCount := 0
+	cursorRow, cursorCol := 0, 0
+
+	for i, child := range children {
+		if !visible(child) {
+			continue
+		}
+
+		colSpan := clampInt(child.GridColumnSpan, 1, columnCount)
+		rowSpan := clampInt(child.GridRowSpan, 1, rowLimit)
+		row, col := child.GridRow-1, child.GridColumn-1
+		if row >= 0 {
+			row = clampInt(row, 0, rowLimit-rowSpan)
+		}
+		if col >= 0 {
+			col = clampInt(col, 0, columnCount-colSpan)
+		}
+
+		switch {
+		case row >= 0 && col >= 0:
+			// Explicit cell, even if it overlaps another child
+		case col >= 0:
+			row = 0
+			for !fits(row, col, rowSpan, colSpan) {
+				row++
+			}
+		case row >= 0:
+			col = 0
+			for col+colSpan <= columnCount && !fits(row, col, rowSpan, colSpan) {
+				col++
+			}
+			if col+colSpan > columnCount {
+				col = 0 // Row is full: overlap its first cell, as an explicit placement would
+			}
+		default:
+			row, col = cursorRow, cursorCol
+			for !fits(row, col, rowSpan, colSpan) {
+				col++
+				if col+colSpan > columnCount {
+					row++
+					col = 0
+				}
+			}
+			cursorRow, cursorCol = row, col+colSpan
+		}
+
+		for r := row; r < row+rowSpan; r++ {
+			for c := col; c < col+colSpan; c++ {
+				occupied[[2]int{r, c}] = true
+			}
+		}
+		if row+rowSpan > rowCount {
+			rowCount = row + rowSpan
+		}
+		items = append(items, gridItem{index: i, row: row, col: col, rowSpan: rowSpan, colSpan: colSpan})
+	}
+
+	return items, rowCount
+}
+
+// sizeGridTracks resolves the size of each track along one axis. span returns
+// an item's first track, track count and own size along the axis.
+func sizeGridTracks(tracks models.GridTracks, containerSize, gap float64, items []gridItem, contentAlign string, span func(gridItem) (int, int, float64)) []float64 {
+	sizes := make([]float64, len(tracks))
+	var frTotal float64
+	autoCount := 0
+
+	for i, track := range tracks {
+		switch track.Kind {
+		case models.TrackFixed:
+			sizes[i] = track.Value
+		case models.TrackPercent:
+			sizes[i] = containerSize * track.Value / 100
+		case models.TrackFr:
+			frTotal += track.Value
+		default:
+			autoCount++
+			// Auto tracks fit the largest child that sits only in them
+			for _, it := range items {
+				if start, count, size := span(it); start == i && count == 1 && size > sizes[i] {
+					sizes[i] = size
+				}
+			}
+		}
+	}
+
+	free := containerSize - sumFloats(sizes) - gap*float64(len(tracks)-1)
+	if free <= 0 {
+		return sizes
+	}
+
+	switch {
+	case frTotal > 0:
+		for i, track := range tracks {
+			if track.Kind == models.TrackFr {
+				sizes[i] = free * track.Value / frTotal
+			}
+		}
+	case autoCount > 0 && (contentAlign == "" || contentAlign == "stretch" || contentAlign == "normal"):
+		// Like CSS, auto tracks share the leftover space unless the content is aligned
+		for i, track := range tracks {
+			if track.Kind == models.TrackAuto {
+				sizes[i] += free / float64(autoCount)
+			}
+		}
+	}
+
+	return sizes
+}
+
+// gridTrackStarts returns the offset of each track from the container edge,
+// distributing leftover space according to justifyContent/alignContent
+func gridTrackStarts(sizes []float64, containerSize, gap float64, contentAlign string) []float64 {
+	free := containerSize - sumFloats(sizes) - gap*float64(len(sizes)-1)
+	start, between := distributeSpace(free, len(sizes), contentAlign)
+
+	starts := make([]float64, len(sizes))
+	pos := start
+	for i, size := range sizes {
+		starts[i] = pos
+		pos += size + gap + between
+	}
+	return starts
+}
+
+// distributeSpace splits free space along an axis for CSS content alignment
+// (justify-content/align-content) of count items or tracks. It returns the
+// offset of the first one and the extra space between neighbours. Overflowing
+// content (negative free space) is still centered or end-aligned, as in CSS.
+func distributeSpace(free float64, count int, mode string) (float64, float64) {
+	if count == 0 {
+		return 0, 0
+	}
+
+	switch strings.ToLower(mode) {
+	case "center":
+		return free / 2, 0
+	case "end", "flex-end", "right", "bottom":
+		return free, 0
+	}
+
+	if free <= 0 {
+		return 0, 0
+	}
+	switch strings.ToLower(mode) {
+	case "space-between":
+		if count > 1 {
+			return 0, free / float64(count-1)
+		}
+		return 0, 0
+	case "space-around":
+		between := free / float64(count)
+		return between / 2, between
+	case "space-evenly":
+		between := free / float64(count+1)
+		return between, between
+	default: // start, flex-start, stretch, normal
+		return 0, 0
+	}
+}
+
+// alignInCell positions an item of the given size in a cell along one axis
+// and returns its offset and (possibly stretched) size
+func alignInCell(cellStart, cellSize, size float64, mode string) (float64, float64) {
+	switch strings.ToLower(mode) {
+	case "stretch":
+		return cellStart, cellSize
+	case "center":
+		return cellStart + (cellSize-size)/2, size
+	case "end", "flex-end", "right", "bottom":
+		return cellStart + cellSize - size, size
+	default: // start, flex-start
+		return cellStart, size
+	}
+}
+
+func sumFloats(values []float64) float64 {
+	var total float64
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
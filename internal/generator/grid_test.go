@@ -0,0 +1,70 @@
+package generator
+
+import (
+	"badge-service/internal/models"
+	"math"
+	"testing"
+)
+
+func TestPlaceGridItems(t *testing.T) {
+	visible := func(models.Layer) bool { return true }
+	tests := []struct {
+		name     string
+		children []models.Layer
+		columns  int
+		want     []gridItem
+		rows     int
+	}{
+		{
+			name:     "row by row",
+			children: []models.Layer{{}, {}, {}},
+			columns:  2,
+			want:     []gridItem{{0, 0, 0, 1, 1}, {1, 0, 1, 1, 1}, {2, 1, 0, 1, 1}},
+			rows:     2,
+		},
+		{
+			name:     "column span",
+			children: []models.Layer{{GridColumnSpan: 2}, {}},
+			columns:  2,
+			want:     []gridItem{{0, 0, 0, 1, 2}, {1, 1, 0, 1, 1}},
+			rows:     2,
+		},
+		{
+			name:     "explicit cell",
+			children: []models.Layer{{GridRow: 2, GridColumn: 2}, {}},
+			columns:  2,
+			want:     []gridItem{{0, 1, 1, 1, 1}, {1, 0, 0, 1, 1}},
+			rows:     2,
+		},
+		{
+			name:     "huge row span",
+			children: []models.Layer{{GridRowSpan: math.MaxInt32}, {}},
+			columns:  1,
+			want:     []gridItem{{0, 0, 0, 2, 1}, {1, 2, 0, 1, 1}},
+			rows:     3,
+		},
+		{
+			name:     "huge explicit row",
+			children: []models.Layer{{GridRow: math.MaxInt32, GridColumn: 1}},
+			columns:  1,
+			want:     []gridItem{{0, 0, 0, 1, 1}},
+			rows:     1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, rows := placeGridItems(tt.children, tt.columns, len(tt.children), visible)
+			if rows != tt.rows {
+				t.Errorf("rows = %d, want %d", rows, tt.rows)
+			}
+			if len(items) != len(tt.want) {
+				t.Fatalf("items = %+v, want %+v", items, tt.want)
+			}
+			for i := range items {
+				if items[i] != tt.want[i] {
+					t.Errorf("item %d = %+v, want %+v", i, items[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+package generator
+
+import (
+	"badge-service/internal/models"
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Imposition: badges for many users laid out N-up on print sheets.
+//
+// Every badge is drawn by the normal layer renderer straight onto the sheet,
+// offset to its cell and clipped to its edges, so text, shapes and QR codes
+// stay vector and images keep their resolution. Designs with several pages
+// produce one sheet per page for each group of badges. Back sheets mirror the
+// grid so each back lands behind its front: columns for long-edge duplex, or
+// rows for short-edge duplex when settings.flipBack is set.
+
+const (
+	defaultSheetMargin = 10.0 // mm on each side of the sheet
+	cropMarkLength     = 5.0  // mm
+	cropMarkOffset     = 2.0  // gap between a badge edge and its mark, mm
+	cropMarkWidth      = 0.1  // mm
+	inch               = 25.4 // mm
+	labelTolerance     = 0.05 // mm a badge may exceed a preset label by, for labels given in fractional inches
+	sheetTolerance     = 1e-6 // mm of rounding allowed when a grid exactly fills the sheet
+)
+
+// sheetSizes are the named sheet sizes in mm, portrait
+var sheetSizes = map[string]models.Size{
+	"a3":      {Width: 297, Height: 420},
+	"a4":      {Width: 210, Height: 297},
+	"a5":      {Width: 148, Height: 210},
+	"letter":  {Width: 8.5 * inch, Height: 11 * inch},
+	"legal":   {Width: 8.5 * inch, Height: 14 * inch},
+	"tabloid": {Width: 11 * inch, Height: 17 * inch},
+}
+
+// labelPreset is the geometry of a sheet of die-cut labels or badge inserts
+type labelPreset struct {
+	sheet            string
+	columns, rows    int
+	label            models.Size
+	left, top        float64 // position of the first label
+	gutterX, gutterY float64
+}
+
+// labelPresets are common Avery name badge sheets. Badges are centered on
+// labels of a different size.
+var labelPresets = map[string]labelPreset{
+	// 3 3/8" x 2 1/3" adhesive name badges, 8 per Letter sheet
+	"avery-5395": {"letter", 2, 4, models.Size{Width: 3.375 * inch, Height: 2.3333 * inch}, 0.6875 * inch, 0.59375 * inch, 0.375 * inch, 0.1667 * inch},
+	// 3 1/2" x 2 1/4" name badge inserts, 8 per Letter sheet
+	"avery-5390": {"letter", 2, 4, models.Size{Width: 3.5 * inch, Height: 2.25 * inch}, 0.75 * inch, 1 * inch, 0, 0},
+	// 4" x 3" name badge inserts, 6 per Letter sheet
+	"avery-5392": {"letter", 2, 3, models.Size{Width: 4 * inch, Height: 3 * inch}, 0.25 * inch, 1 * inch, 0, 0},
+	// 80 x 50 mm name badge labels, 10 per A4 sheet
+	"avery-l4785": {"a4", 2, 5, models.Size{Width: 80, Height: 50}, 15, 23.5, 20, 0},
+}
+
+// sheetLayout is the resolved grid of cells on a sheet
+type sheetLayout struct {
+	sheet            models.Size
+	columns, rows    int
+	cell             models.Size
+	origin           models.Position // top-left corner of the first cell
+	gutterX, gutterY float64
+}
+
+// cellOrigin returns the top-left corner of the cell at col, row
+func (l sheetLayout) cellOrigin(col, row int) models.Position {
+	return models.Position{
+		X: l.origin.X + float64(col)*(l.cell.Width+l.gutterX),
+		Y: l.origin.Y + float64(row)*(l.cell.Height+l.gutterY),
+	}
+}
+
+// bleed returns how far backgrounds bleed past each badge on the sheet: the
+// template's bleed, but never into a neighbouring badge, so at most half the
+// gutter
+func (l sheetLayout) bleed(bleed float64) float64 {
+	return math.Max(0, math.Min(bleed, math.Min(l.gutterX, l.gutterY)/2))
+}
+
+// CheckImposition reports whether badges of a template can be imposed with
+// the given options, so requests can be rejected before any work is done
+func CheckImposition(template *models.Template, imp models.Imposition) error {
+	width, height := badgeSize(template)
+	_, err := resolveSheetLayout(imp, models.Size{Width: width, Height: height}, template.Design.Settings.Bleed)
+	return err
+}
+
+// resolveSheetLayout works out the sheet size and the grid of badge cells.
+// bleed is the template's bleed; label presets must hold the badge and the
+// part of it that is drawn (see sheetLayout.bleed).
+func resolveSheetLayout(imp models.Imposition, badge models.Size, bleed float64) (sheetLayout, error) {
+	if imp.Preset != "" {
+		name := strings.ToLower(strings.TrimSpace(imp.Preset))
+		preset, ok := labelPresets[name]
+		if !ok {
+			preset, ok = labelPresets["avery-"+name]
+		}
+		if !ok {
+			return sheetLayout{}, fmt.Errorf("unknown imposition preset %q", imp.Preset)
+		}
+		layout := sheetLayout{
+			sheet:   sheetSizes[preset.sheet],
+			columns: preset.columns,
+			rows:    preset.rows,
+			cell:    preset.label,
+			origin:  models.Position{X: preset.left, Y: preset.top},
+			gutterX: preset.gutterX,
+			gutterY: preset.gutterY,
+		}
+		// Badges are centered on the labels; a larger one would print over its neighbours
+		drawn := 2 * layout.bleed(bleed)
+		if badge.Width+drawn > layout.cell.Width+labelTolerance || badge.Height+drawn > layout.cell.Height+labelTolerance {
+			return sheetLayout{}, fmt.Errorf("a %.1f x %.1f mm badge with %.1f mm bleed does not fit the %.1f x %.1f mm labels of preset %q",
+				badge.Width, badge.Height, drawn/2, layout.cell.Width, layout.cell.Height, imp.Preset)
+		}
+		return layout, nil
+	}
+
+	sheet := models.Size{Width: imp.SheetWidth, Height: imp.SheetHeight}
+	if sheet.Width <= 0 || sheet.Height <= 0 {
+		name := strings.ToLower(strings.TrimSpace(imp.Sheet))
+		if name == "" {
+			name = "a4"
+		}
+		size, ok := sheetSizes[name]
+		if !ok {
+			return sheetLayout{}, fmt.Errorf("unknown sheet size %q", imp.Sheet)
+		}
+		sheet = orient(size, imp.Orientation)
+	}
+
+	if imp.Gutter < 0 || imp.GutterX < 0 || imp.GutterY < 0 {
+		return sheetLayout{}, fmt.Errorf("imposition gutters must not be negative")
+	}
+	margins := models.Margins{Top: defaultSheetMargin, Right: defaultSheetMargin, Bottom: defaultSheetMargin, Left: defaultSheetMargin}
+	if imp.Margins != nil {
+		margins = *imp.Margins
+		if margins.Top < 0 || margins.Right < 0 || margins.Bottom < 0 || margins.Left < 0 {
+			return sheetLayout{}, fmt.Errorf("imposition margins must not be negative")
+		}
+	}
+	gutterX, gutterY := imp.Gutter, imp.Gutter
+	if imp.GutterX > 0 {
+		gutterX = imp.GutterX
+	}
+	if imp.GutterY > 0 {
+		gutterY = imp.GutterY
+	}
+
+	availW := sheet.Width - margins.Left - margins.Right
+	availH := sheet.Height - margins.Top - margins.Bottom
+	columns, rows := imp.Columns, imp.Rows
+	if columns <= 0 {
+		columns = int(math.Floor((availW + gutterX) / (badge.Width + gutterX)))
+	}
+	if rows <= 0 {
+		rows = int(math.Floor((availH + gutterY) / (badge.Height + gutterY)))
+	}
+	if columns < 1 || rows < 1 {
+		return sheetLayout{}, fmt.Errorf("a %.1f x %.1f mm badge does not fit on a %.1f x %.1f mm sheet with these margins",
+			badge.Width, badge.Height, sheet.Width, sheet.Height)
+	}
+
+	// An explicit grid must fit inside the margins too
+	blockW := float64(columns)*badge.Width + float64(columns-1)*gutterX
+	blockH := float64(rows)*badge.Height + float64(rows-1)*gutterY
+	if blockW > availW+sheetTolerance || blockH > availH+sheetTolerance {
+		return sheetLayout{}, fmt.Errorf("%d x %d badges of %.1f x %.1f mm need %.1f x %.1f mm, more than the %.1f x %.1f mm inside the sheet margins",
+			columns, rows, badge.Width, badge.Height, blockW, blockH, availW, availH)
+	}
+
+	// Center the block of badges inside the margins
+	return sheetLayout{
+		sheet:   sheet,
+		columns: columns,
+		rows:    rows,
+		cell:    badge,
+		origin: models.Position{
+			X: margins.Left + (availW-blockW)/2,
+			Y: margins.Top + (availH-blockH)/2,
+		},
+		gutterX: gutterX,
+		gutterY: gutterY,
+	}, nil
+}
+
+// Impose renders a badge for every user, N-up on print sheets, and returns
+// the PDF. imageData is the preloaded image cache (see SetImageDataCache).
+func Impose(template *models.Template, users []*models.User, imp models.Imposition, imageData map[string][]byte) ([]byte, error) {
+	width, height := badgeSize(template)
+	badge := models.Size{Width: width, Height: height}
+	layout, err := resolveSheetLayout(imp, badge, template.Design.Settings.Bleed)
+	if err != nil {
+		return nil, err
+	}
+
+	pdf := gofpdf.NewCustom(pageInit(layout.sheet))
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+
+	g := newGenerator(template, nil, pdf)
+	if imageData != nil {
+		g.SetImageDataCache(imageData)
+	}
+
+	bleed := layout.bleed(template.Design.Settings.Bleed)
+
+	pages := template.Design.PageList()
+	perSheet := layout.columns * layout.rows
+	for start := 0; start < len(users); start += perSheet {
+		end := start + perSheet
+		if end > len(users) {
+			end = len(users)
+		}
+
+		for index, page := range pages {
+			pdf.AddPage()
+			g.gsAlpha = 1
+
+			back := len(pages) > 1 && page.IsBack(index)
+			var badges []models.Position
+			for k, user := range users[start:end] {
+				col, row := k%layout.columns, k/layout.columns
+				switch {
+				case back && template.Design.Settings.FlipBack:
+					// Short-edge duplex: the sheet turns over top to bottom, and
+					// drawPage turns each back upside down to match
+					row = layout.rows - 1 - row
+				case back:
+					// Long-edge duplex: the sheet turns over left to right
+					col = layout.columns - 1 - col
+				}
+
+				// Center the badge in its cell (label presets can differ from the badge size)
+				cell := layout.cellOrigin(col, row)
+				origin := models.Position{
+					X: cell.X + (layout.cell.Width-badge.Width)/2,
+					Y: cell.Y + (layout.cell.Height-badge.Height)/2,
+				}
+				badges = append(badges, origin)
+
+				g.user = user
+				g.drawBadge(page, index, origin, bleed)
+			}
+
+			if imp.CropMarks {
+				drawCropMarks(pdf, badges, badge, math.Max(cropMarkOffset, bleed))
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("failed to output PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// drawBadge draws one page of the current user's badge at origin, clipped to
+// the badge plus bleed so overflowing layers don't spill onto neighbouring
+// badges
+func (g *PDFGenerator) drawBadge(page models.Page, index int, origin models.Position, bleed float64) {
+	g.pdf.ClipRect(origin.X-bleed, origin.Y-bleed, g.size.Width+2*bleed, g.size.Height+2*bleed, false)
+	savedAlpha := g.gsAlpha
+	defer func() {
+		g.pdf.ClipEnd()
+		g.gsAlpha = savedAlpha
+	}()
+
+	g.drawPage(page, index, origin)
+}
+
+// drawCropMarks draws cut marks outside the block of badges, in line with
+// every badge edge, so the sheet can be trimmed with straight guillotine cuts.
+// Marks start offset mm from the block so they stay clear of any bleed.
+func drawCropMarks(pdf *gofpdf.Fpdf, badges []models.Position, badge models.Size, offset float64) {
+	if len(badges) == 0 {
+		return
+	}
+
+	xs := make(map[float64]bool)
+	ys := make(map[float64]bool)
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, b := range badges {
+		xs[roundMM(b.X)], xs[roundMM(b.X+badge.Width)] = true, true
+		ys[roundMM(b.Y)], ys[roundMM(b.Y+badge.Height)] = true, true
+		minX, minY = math.Min(minX, b.X), math.Min(minY, b.Y)
+		maxX, maxY = math.Max(maxX, b.X+badge.Width), math.Max(maxY, b.Y+badge.Height)
+	}
+
+	sheetW, sheetH := pdf.GetPageSize()
+	// Marks shrink to fit narrow margins and are left out when there is no room
+	top := math.Min(cropMarkLength, minY-offset)
+	bottom := math.Min(cropMarkLength, sheetH-maxY-offset)
+	left := math.Min(cropMarkLength, minX-offset)
+	right := math.Min(cropMarkLength, sheetW-maxX-offset)
+
+	pdf.SetLineWidth(cropMarkWidth)
+	pdf.SetDrawColor(0, 0, 0)
+	for _, x := range sortedKeys(xs) {
+		if top > 0 {
+			pdf.Line(x, minY-offset-top, x, minY-offset)
+		}
+		if bottom > 0 {
+			pdf.Line(x, maxY+offset, x, maxY+offset+bottom)
+		}
+	}
+	for _, y := range sortedKeys(ys) {
+		if left > 0 {
+			pdf.Line(minX-offset-left, y, minX-offset, y)
+		}
+		if right > 0 {
+			pdf.Line(maxX+offset, y, maxX+offset+right, y)
+		}
+	}
+}
+
+// sortedKeys returns the edges of a set in order, so the output is the same on every run
+func sortedKeys(set map[float64]bool) []float64 {
+	keys := make([]float64, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Float64s(keys)
+	return keys
+}
+
+// roundMM rounds to 1/100 mm so edges shared by neighbouring badges are marked once
+func roundMM(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,55 @@
+package generator
+
+import (
+	"badge-service/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestResolveSheetLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		imp     models.Imposition
+		badge   models.Size
+		bleed   float64
+		columns int
+		rows    int
+		err     string
+	}{
+		{name: "preset", imp: models.Imposition{Preset: "avery-l4785"}, badge: models.Size{Width: 80, Height: 50}, columns: 2, rows: 5},
+		{name: "preset without prefix", imp: models.Imposition{Preset: "5392"}, badge: models.Size{Width: 100, Height: 70}, columns: 2, rows: 3},
+		{name: "badge smaller than label", imp: models.Imposition{Preset: "avery-5390"}, badge: models.Size{Width: 85, Height: 54}, columns: 2, rows: 4},
+		// Label presets with a gutter draw part of the bleed
+		{name: "bleed inside the gutter", imp: models.Imposition{Preset: "avery-l4785"}, badge: models.Size{Width: 70, Height: 50}, bleed: 3, columns: 2, rows: 5},
+		{name: "bleed past the label", imp: models.Imposition{Preset: "avery-5395"}, badge: models.Size{Width: 85.7, Height: 59.2}, bleed: 3, err: "with 2.1 mm bleed"},
+		{name: "badge larger than label", imp: models.Imposition{Preset: "avery-l4785"}, badge: models.Size{Width: 90, Height: 140}, err: "does not fit the 80.0 x 50.0 mm labels"},
+		{name: "unknown preset", imp: models.Imposition{Preset: "avery-1"}, badge: models.Size{Width: 80, Height: 50}, err: "unknown imposition preset"},
+		{name: "custom grid", imp: models.Imposition{Sheet: "a4"}, badge: models.Size{Width: 90, Height: 55}, columns: 2, rows: 5},
+		{name: "explicit grid", imp: models.Imposition{Sheet: "a4", Columns: 2, Rows: 2, Gutter: 5}, badge: models.Size{Width: 90, Height: 55}, columns: 2, rows: 2},
+		{name: "grid exactly filling the sheet", imp: models.Imposition{SheetWidth: 200, SheetHeight: 100, Columns: 2, Rows: 1, Margins: &models.Margins{}}, badge: models.Size{Width: 100, Height: 100}, columns: 2, rows: 1},
+		{name: "too many columns", imp: models.Imposition{Sheet: "a4", Columns: 3}, badge: models.Size{Width: 90, Height: 55}, err: "more than the 190.0 x 277.0 mm"},
+		{name: "too many rows", imp: models.Imposition{Sheet: "a4", Rows: 6}, badge: models.Size{Width: 90, Height: 55}, err: "more than the"},
+		{name: "gutters push the grid off the sheet", imp: models.Imposition{Sheet: "a4", Columns: 2, GutterX: 20}, badge: models.Size{Width: 90, Height: 55}, err: "more than the"},
+		{name: "negative gutter", imp: models.Imposition{Sheet: "a4", Gutter: -5}, badge: models.Size{Width: 90, Height: 55}, err: "gutters must not be negative"},
+		{name: "negative gutterY", imp: models.Imposition{Sheet: "a4", GutterY: -1}, badge: models.Size{Width: 90, Height: 55}, err: "gutters must not be negative"},
+		{name: "negative margin", imp: models.Imposition{Sheet: "a4", Margins: &models.Margins{Left: -10}}, badge: models.Size{Width: 90, Height: 55}, err: "margins must not be negative"},
+		{name: "badge larger than sheet", imp: models.Imposition{Sheet: "a5"}, badge: models.Size{Width: 210, Height: 297}, err: "does not fit on a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout, err := resolveSheetLayout(tt.imp, tt.badge, tt.bleed)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if layout.columns != tt.columns || layout.rows != tt.rows {
+				t.Errorf("grid %dx%d, want %dx%d", layout.columns, layout.rows, tt.columns, tt.rows)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package generator
+
+import (
+	"badge-service/internal/expr"
+	"badge-service/internal/models"
+	"strings"
+)
+
+// fieldResolver resolves the field names used in placeholders and conditions.
+// Names in the template's placeholders map come first, so designers can write
+// {{company}} or {{photo}} and the backend maps them per template to
+// "customFields.<id>", "{{customFields.<id>}}" or a bare field ID. Anything
+// else is looked up in the user's data.
+func fieldResolver(template *models.Template, user *models.User) expr.Resolver {
+	lookup := func(name string) string {
+		if user == nil {
+			return ""
+		}
+		return user.Lookup(name)
+	}
+
+	var placeholders map[string]string
+	if template != nil {
+		placeholders = template.Placeholders
+	}
+	if len(placeholders) == 0 {
+		return lookup
+	}
+
+	return func(name string) string {
+		target, ok := mappedPlaceholder(placeholders, name)
+		if !ok {
+			return lookup(name)
+		}
+		// Targets resolve against the user's data only: the backend maps
+		// customFields.<id> to {{customFields.<id>}}, which must not recurse
+		if strings.Contains(target, "{{") {
+			return expr.Render(target, lookup)
+		}
+		return lookup(target)
+	}
+}
+
+// mappedPlaceholder finds a name in the placeholders map, ignoring case
+func mappedPlaceholder(placeholders map[string]string, name string) (string, bool) {
+	if target, ok := placeholders[name]; ok && target != "" {
+		return target, true
+	}
+	for key, target := range placeholders {
+		if target != "" && strings.EqualFold(key, name) {
+			return target, true
+		}
+	}
+	return "", false
+}
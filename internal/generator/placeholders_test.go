@@ -0,0 +1,76 @@
+package generator
+
+import (
+	"badge-service/internal/models"
+	"testing"
+)
+
+func TestFieldResolver(t *testing.T) {
+	user := &models.User{
+		FirstName: "Jane",
+		CustomFieldValues: []models.CustomFieldValue{
+			{FieldID: "cf_1", Name: "Company", Value: "Acme"},
+			{FieldID: "cf_2", Name: "Photo", Value: "https://example.com/jane.png"},
+			{FieldID: "cf_3", Name: "Title", Value: "CTO"},
+		},
+	}
+	template := &models.Template{Placeholders: map[string]string{
+		"organization": "customFields.cf_1",
+		"Photo":        "{{customFields.cf_2}}",
+		"role":         "cf_3",
+		"title":        "{{title}}",
+		"greeting":     "Hi {{firstName}}",
+		"badge":        "",
+		"missing":      "customFields.cf_9",
+	}}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"organization", "Acme"},
+		{"ORGANIZATION", "Acme"},
+		{"photo", "https://example.com/jane.png"},
+		{"role", "CTO"},
+		// The target names the user field, not the placeholder again
+		{"title", "CTO"},
+		{"greeting", "Hi Jane"},
+		{"badge", ""},
+		{"missing", ""},
+		{"firstName", "Jane"},
+		{"company", "Acme"},
+	}
+	resolve := fieldResolver(template, user)
+	for _, tt := range tests {
+		if got := resolve(tt.name); got != tt.want {
+			t.Errorf("resolve(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if got := fieldResolver(nil, user)("firstName"); got != "Jane" {
+		t.Errorf("without a template: %q", got)
+	}
+	if got := fieldResolver(template, nil)("organization"); got != "" {
+		t.Errorf("without a user: %q", got)
+	}
+}
+
+func TestMappedPlaceholder(t *testing.T) {
+	placeholders := map[string]string{"Company": "customFields.cf_1", "photo": "", "title": "cf_3"}
+	tests := []struct {
+		name   string
+		target string
+		ok     bool
+	}{
+		{"Company", "customFields.cf_1", true},
+		{"company", "customFields.cf_1", true},
+		{"TITLE", "cf_3", true},
+		{"photo", "", false},
+		{"email", "", false},
+	}
+	for _, tt := range tests {
+		target, ok := mappedPlaceholder(placeholders, tt.name)
+		if target != tt.target || ok != tt.ok {
+			t.Errorf("mappedPlaceholder(%q) = %q, %v; want %q, %v", tt.name, target, ok, tt.target, tt.ok)
+		}
+	}
+}
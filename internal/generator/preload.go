@@ -0,0 +1,79 @@
+package generator
+
+import (
+	"badge-service/internal/cache"
+	"badge-service/internal/models"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// ImageRequests returns the processed-image requests for every picture the
+// badges of the users draw, deduplicated by key. Layers are sized exactly as
+// renderImage sees them: grown into the bleed, laid out by flex and grid
+// containers and swapped by rotation, so the preloaded images are found by
+// key instead of being fetched again while drawing.
+func ImageRequests(template *models.Template, users []*models.User) []cache.ImageRequest {
+	// Container layout measures text; a scratch PDF holds the fonts for all users
+	g := newGenerator(template, nil, gofpdf.New("P", "mm", "A4", ""))
+
+	seen := make(map[string]bool)
+	var requests []cache.ImageRequest
+	add := func(layer models.Layer) {
+		imageURL := ImageURL(layer, g.template, g.user)
+		if imageURL == "" {
+			return
+		}
+		req := NewImageRequest(layer, imageURL, g.dpi, template.Design.Settings.JPEGQuality)
+		if !seen[req.Key()] {
+			seen[req.Key()] = true
+			requests = append(requests, req)
+		}
+	}
+
+	// collect follows renderLayer, renderContainer and renderQRCode
+	var collect func(layer models.Layer)
+	collect = func(layer models.Layer) {
+		if !g.isVisible(layer) || layer.Style.LayerOpacity() == 0 {
+			return
+		}
+		if rotation := normalizeRotation(layer.Style.Rotation); rotation != 0 {
+			layer.Size, _ = rotatedContentSize(layer.Size, rotation)
+		}
+
+		switch layer.Type {
+		case "image":
+			add(layer)
+		case "qrcode":
+			// The logo is sized from the symbol, which depends on the content
+			if qrLogoURL(layer, g.template) == "" {
+				return
+			}
+			if bitmap, err := g.qrBitmap(layer); err == nil {
+				if logo, ok := QRLogoLayer(layer, g.template, len(bitmap)); ok {
+					add(logo)
+				}
+			}
+		case "container":
+			if len(layer.Children) == 0 {
+				return
+			}
+			_, sizes := g.layoutChildren(layer)
+			for i, child := range layer.Children {
+				if i < len(sizes) {
+					child.Size = sizes[i]
+				}
+				collect(child)
+			}
+		}
+	}
+
+	for _, user := range users {
+		g.user = user
+		for _, page := range template.Design.PageList() {
+			for _, layer := range PrintLayers(template, page) {
+				collect(layer)
+			}
+		}
+	}
+	return requests
+}
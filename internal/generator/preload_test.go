@@ -0,0 +1,56 @@
+package generator
+
+import (
+	"badge-service/internal/models"
+	"testing"
+)
+
+func TestImageRequests(t *testing.T) {
+	photo := func(size models.Size) models.Layer {
+		return models.Layer{Type: "image", Content: "https://example.com/photo.jpg", Size: size, Visible: true}
+	}
+	rotated := photo(models.Size{Width: 20, Height: 40})
+	rotated.Style.Rotation = 90
+	grown := photo(models.Size{Width: 10, Height: 10})
+	grown.FlexGrow = 1
+	hidden := photo(models.Size{Width: 30, Height: 30})
+	hidden.VisibleIf = "company == 'ACME'"
+
+	tests := []struct {
+		name  string
+		layer models.Layer
+		want  []models.Size
+	}{
+		{"plain", photo(models.Size{Width: 20, Height: 40}), []models.Size{{Width: 20, Height: 40}}},
+		{"rotated", rotated, []models.Size{{Width: 40, Height: 20}}},
+		{"hidden", hidden, nil},
+		{
+			"flex child",
+			models.Layer{
+				Type:            "container",
+				Size:            models.Size{Width: 60, Height: 10},
+				Visible:         true,
+				Children:        []models.Layer{grown},
+				ContainerLayout: &models.ContainerLayout{Type: "flex", FlexDirection: "row"},
+			},
+			[]models.Size{{Width: 60, Height: 10}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := &models.Template{}
+			template.Design.Settings = models.Settings{PaperWidth: 90, PaperHeight: 140}
+			template.Design.Layers = []models.Layer{tt.layer}
+
+			requests := ImageRequests(template, []*models.User{{Identifier: "A1"}})
+			if len(requests) != len(tt.want) {
+				t.Fatalf("got %d requests, want %d", len(requests), len(tt.want))
+			}
+			for i, req := range requests {
+				if req.Width != tt.want[i].Width || req.Height != tt.want[i].Height {
+					t.Errorf("request %d is %gx%g mm, want %gx%g", i, req.Width, req.Height, tt.want[i].Width, tt.want[i].Height)
+				}
+			}
+		})
+	}
+}
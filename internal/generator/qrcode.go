@@ -0,0 +1,210 @@
+package generator
+
+import (
+	"badge-service/internal/models"
+	"badge-service/internal/token"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// QR code options: error correction, quiet zone, a logo in the center and
+// signed badge tokens.
+
+const (
+	defaultQRQuietZone = 4   // modules, the minimum the QR standard asks for
+	defaultQRLogoSize  = 0.2 // logo width as a fraction of the symbol
+	maxQRLogoSize      = 0.3
+	// The square cleared behind a logo covers at most 12% of the modules,
+	// well below the 30% level H recovers: the codewords cut by its edges
+	// are lost too
+	maxQRClearedSize = 0.35
+)
+
+// qrRecoveryLevel maps an errorCorrection setting to a go-qrcode level.
+// go-qrcode calls the standard's Q and H levels High and Highest.
+func qrRecoveryLevel(name string) qrcode.RecoveryLevel {
+	switch strings.ToUpper(strings.TrimSpace(name)) {
+	case "L", "LOW":
+		return qrcode.Low
+	case "Q", "QUARTILE":
+		return qrcode.High
+	case "H", "HIGH", "HIGHEST":
+		return qrcode.Highest
+	}
+	return qrcode.Medium
+}
+
+// QRLogoLayer returns the image layer that draws a QR code layer's logo,
+// positioned relative to the QR layer, and whether the layer has a logo.
+// The logo is sized from the symbol of n x n modules, without the quiet
+// zone. Handlers preload it like any other image layer.
+func QRLogoLayer(layer models.Layer, template *models.Template, n int) (models.Layer, bool) {
+	url := qrLogoURL(layer, template)
+	if url == "" {
+		return models.Layer{}, false
+	}
+
+	// One module of padding on each side of the logo
+	module, _ := qrModule(layer, n)
+	side := float64(max(qrLogoModules(n, layer.QRCode.LogoSize)-2, 0)) * module
+
+	return models.Layer{
+		ID:       layer.ID + "_logo",
+		Type:     "image",
+		Content:  url,
+		Position: models.Position{X: (layer.Size.Width - side) / 2, Y: (layer.Size.Height - side) / 2},
+		Size:     models.Size{Width: side, Height: side},
+		Visible:  true,
+		Style:    models.Style{ObjectFit: "contain"},
+	}, true
+}
+
+// qrLogoURL returns the URL of a QR code layer's logo asset, empty when the
+// layer has none
+func qrLogoURL(layer models.Layer, template *models.Template) string {
+	if layer.Type != "qrcode" || layer.QRCode == nil || layer.QRCode.Logo == "" {
+		return ""
+	}
+	return assetURL(template, layer.QRCode.Logo)
+}
+
+// qrModule returns the module size of a QR code layer whose symbol has n x n
+// modules, and its quiet zone in modules. The symbol and its quiet zone fill
+// the shorter side of the layer.
+func qrModule(layer models.Layer, n int) (module, quietZone float64) {
+	quietZone = defaultQRQuietZone
+	if layer.QRCode != nil && layer.QRCode.QuietZone != nil {
+		quietZone = math.Max(0, *layer.QRCode.QuietZone)
+	}
+	return math.Min(layer.Size.Width, layer.Size.Height) / (float64(n) + 2*quietZone), quietZone
+}
+
+// qrLogoModules returns the side, in modules, of the square cleared behind a
+// logo of the given scale on a symbol of n x n modules: the logo plus one
+// module of padding, capped by maxQRClearedSize and with the parity of n so
+// it stays centered
+func qrLogoModules(n int, scale float64) int {
+	if scale <= 0 {
+		scale = defaultQRLogoSize
+	}
+	side := int(math.Ceil(math.Min(scale, maxQRLogoSize)*float64(n))) + 2
+	side = min(side, int(maxQRClearedSize*float64(n)))
+	if (n-side)%2 != 0 {
+		side--
+	}
+	return max(side, 0)
+}
+
+// SignsQRCodes reports whether any QR code layer of the template, on any
+// page, encodes a signed badge token. Handlers use it to refuse generation
+// when no signing key is configured instead of leaving the code out.
+func SignsQRCodes(template *models.Template) bool {
+	var signs func(layers []models.Layer) bool
+	signs = func(layers []models.Layer) bool {
+		for _, layer := range layers {
+			if layer.Type == "qrcode" && layer.QRCode != nil && layer.QRCode.Signed {
+				// Contact cards are never signed
+				switch strings.ToLower(layer.QRCode.Mode) {
+				case "vcard", "mecard":
+				default:
+					return true
+				}
+			}
+			if signs(layer.Children) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, page := range template.Design.PageList() {
+		if signs(page.Layers) {
+			return true
+		}
+	}
+	return false
+}
+
+// clearModules turns off the modules of an n x n QR bitmap inside a centered
+// square of the given side (in modules), so the logo sits on a clean
+// background instead of cut modules. Finder, timing and alignment patterns
+// are kept: scanners need them to locate the code at all.
+func clearModules(bitmap [][]bool, side int) {
+	n := len(bitmap)
+	lo := max(0, (n-side)/2)
+	hi := min(n, lo+side)
+	functional := qrFunctionModules(n)
+	for row := lo; row < hi; row++ {
+		for col := lo; col < hi; col++ {
+			if !functional(row, col) {
+				bitmap[row][col] = false
+			}
+		}
+	}
+}
+
+// qrFunctionModules returns a test for the finder patterns with their
+// separators, the timing patterns and the alignment patterns of a symbol of
+// n x n modules
+func qrFunctionModules(n int) func(row, col int) bool {
+	centers := qrAlignmentCenters((n - 17) / 4)
+	last := len(centers) - 1
+	return func(row, col int) bool {
+		// Finder patterns and separators in three corners
+		if (row < 8 && col < 8) || (row < 8 && col >= n-8) || (row >= n-8 && col < 8) {
+			return true
+		}
+		if row == 6 || col == 6 {
+			return true
+		}
+		for i, cy := range centers {
+			for j, cx := range centers {
+				// No alignment pattern overlaps a finder pattern
+				if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+					continue
+				}
+				if row >= cy-2 && row <= cy+2 && col >= cx-2 && col <= cx+2 {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// qrAlignmentCenters returns the row and column coordinates of the alignment
+// pattern centers of a QR code version, as tabulated in ISO/IEC 18004 annex E
+func qrAlignmentCenters(version int) []int {
+	if version < 2 || version > 40 {
+		return nil
+	}
+	count := version/7 + 2
+	step := 26
+	if version != 32 {
+		step = (version*4 + count*2 + 1) / (count*2 - 2) * 2
+	}
+	centers := make([]int, count)
+	centers[0] = 6
+	for i, pos := count-1, version*4+10; i > 0; i, pos = i-1, pos-step {
+		centers[i] = pos
+	}
+	return centers
+}
+
+// badgeToken returns a signed token for a badge identifier, with the
+// template's event and the user's badge category
+func (g *PDFGenerator) badgeToken(identifier string) (string, error) {
+	claims := token.Claims{Identifier: identifier}
+	if g.template.EventID != 0 {
+		claims.EventID = strconv.Itoa(g.template.EventID)
+	}
+	for _, path := range []string{"badgeCategoryId", "badgeCategory.id", "badge_category_id"} {
+		if id, ok := g.user.Path(path); ok && id != "" {
+			claims.BadgeCategoryID = id
+			break
+		}
+	}
+	return token.Sign(claims)
+}
@@ -0,0 +1,136 @@
+package generator
+
+import (
+	"badge-service/internal/models"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/skip2/go-qrcode"
+)
+
+func TestSignsQRCodes(t *testing.T) {
+	qr := func(opts models.QRCode) models.Layer {
+		return models.Layer{Type: "qrcode", QRCode: &opts}
+	}
+	tests := []struct {
+		name   string
+		layers []models.Layer
+		want   bool
+	}{
+		{"no qr codes", []models.Layer{{Type: "text"}}, false},
+		{"plain qr code", []models.Layer{qr(models.QRCode{})}, false},
+		{"signed qr code", []models.Layer{qr(models.QRCode{Signed: true})}, true},
+		{"signed contact card", []models.Layer{qr(models.QRCode{Signed: true, Mode: "vCard"})}, false},
+		{"in a container", []models.Layer{{Type: "container", Children: []models.Layer{qr(models.QRCode{Signed: true})}}}, true},
+	}
+	for _, tt := range tests {
+		template := &models.Template{}
+		template.Design.Layers = tt.layers
+		if got := SignsQRCodes(template); got != tt.want {
+			t.Errorf("%s: SignsQRCodes = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQRAlignmentCenters(t *testing.T) {
+	tests := []struct {
+		version int
+		want    []int
+	}{
+		{1, nil},
+		{2, []int{6, 18}},
+		{6, []int{6, 34}},
+		{7, []int{6, 22, 38}},
+		{14, []int{6, 26, 46, 66}},
+		{32, []int{6, 34, 60, 86, 112, 138}},
+		{36, []int{6, 24, 50, 76, 102, 128, 154}},
+		{40, []int{6, 30, 58, 86, 114, 142, 170}},
+	}
+	for _, tt := range tests {
+		if got := qrAlignmentCenters(tt.version); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("qrAlignmentCenters(%d) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestClearModulesKeepsFunctionPatterns(t *testing.T) {
+	// Contents long enough for versions from 1 up past 7, where an
+	// alignment pattern sits in the center of the symbol
+	for _, size := range []int{1, 20, 60, 120, 300, 700} {
+		qr, err := qrcode.New(strings.Repeat("A", size), qrcode.Highest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		qr.DisableBorder = true
+		original := qr.Bitmap()
+		n := len(original)
+		bitmap := make([][]bool, n)
+		for row := range original {
+			bitmap[row] = append([]bool(nil), original[row]...)
+		}
+
+		side := qrLogoModules(n, 1)
+		if float64(side*side) > 0.125*float64(n*n) {
+			t.Errorf("version %d: cleared %d x %d of %d x %d modules", qr.VersionNumber, side, side, n, n)
+		}
+		clearModules(bitmap, side)
+
+		functional := qrFunctionModules(n)
+		cleared := 0
+		for row := range bitmap {
+			for col := range bitmap[row] {
+				switch {
+				case functional(row, col) && bitmap[row][col] != original[row][col]:
+					t.Fatalf("version %d: function module (%d, %d) cleared", qr.VersionNumber, row, col)
+				case bitmap[row][col] != original[row][col]:
+					cleared++
+				}
+			}
+		}
+		if cleared == 0 {
+			t.Errorf("version %d: no modules cleared", qr.VersionNumber)
+		}
+		// Versions with an odd number of alignment rows have one in the center
+		if len(qrAlignmentCenters(qr.VersionNumber))%2 == 1 && !functional(n/2, n/2) {
+			t.Errorf("version %d: center alignment pattern not protected", qr.VersionNumber)
+		}
+	}
+}
+
+func TestQRLogoLayer(t *testing.T) {
+	template := &models.Template{}
+	template.Assets = map[string]string{"logo": "https://example.com/logo.png"}
+	zero := 0.0
+	tests := []struct {
+		name     string
+		opts     models.QRCode
+		n        int
+		wantSide float64
+	}{
+		// 25 modules and a quiet zone of 4 on each side fill 33 mm
+		{"default size", models.QRCode{Logo: "logo"}, 25, 5},
+		{"capped scale", models.QRCode{Logo: "logo", LogoSize: 0.9}, 25, 5},
+		{"no quiet zone", models.QRCode{Logo: "logo", QuietZone: &zero}, 25, 5 * 33.0 / 25},
+		{"large symbol", models.QRCode{Logo: "logo", LogoSize: 0.3}, 57, 17 * 33.0 / 65},
+	}
+	for _, tt := range tests {
+		opts := tt.opts
+		layer := models.Layer{ID: "qr", Type: "qrcode", Size: models.Size{Width: 33, Height: 40}, QRCode: &opts}
+		logo, ok := QRLogoLayer(layer, template, tt.n)
+		if !ok {
+			t.Fatalf("%s: no logo layer", tt.name)
+		}
+		if math.Abs(logo.Size.Width-tt.wantSide) > 1e-9 || logo.Size.Height != logo.Size.Width {
+			t.Errorf("%s: logo size = %v, want %.3f", tt.name, logo.Size, tt.wantSide)
+		}
+		if math.Abs(logo.Position.X-(33-logo.Size.Width)/2) > 1e-9 || math.Abs(logo.Position.Y-(40-logo.Size.Height)/2) > 1e-9 {
+			t.Errorf("%s: logo not centered: %v", tt.name, logo.Position)
+		}
+	}
+
+	if _, ok := QRLogoLayer(models.Layer{Type: "qrcode", QRCode: &models.QRCode{}}, template, 25); ok {
+		t.Error("layer without a logo has a logo layer")
+	}
+}
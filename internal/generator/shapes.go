@@ -0,0 +1,336 @@
+package generator
+
+import (
+	"badge-service/internal/models"
+	"math"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Vector shapes for shape layers, and the background and border of every
+// other layer type. Like CSS borders, strokes are drawn inside the layer box
+// so a border never grows the layer.
+
+type shapeKind int
+
+const (
+	shapeRect shapeKind = iota
+	shapeRoundedRect
+	shapeEllipse
+	shapeLine
+	shapePolygon
+)
+
+// defaultLineWidth is used for line shapes that don't set borderWidth (mm)
+const defaultLineWidth = 0.25
+
+// shapeGeometry is a shape in page coordinates
+type shapeGeometry struct {
+	kind       shapeKind
+	x, y, w, h float64
+	radius     float64            // corner radius of rounded rectangles
+	points     []gofpdf.PointType // line end points and polygon vertices
+}
+
+// strokeStyle is a resolved border
+type strokeStyle struct {
+	r, g, b int
+	alpha   float64
+	width   float64
+	dash    []float64
+	round   bool // round caps, used for dotted lines
+}
+
+// defaultMaskRadius is the corner radius of a roundedRect image mask without
+// borderRadius, as a fraction of the shorter side
+const defaultMaskRadius = 0.15
+
+// layerGeometry returns the outline of a layer placed at (x, y). Shape layers
+// can be any shape and image layers can be masked to a circle, ellipse or
+// rounded rectangle; every other layer is a rectangle, rounded by style.borderRadius.
+func layerGeometry(layer models.Layer, x, y float64) shapeGeometry {
+	geo := shapeGeometry{
+		kind:   shapeRect,
+		x:      x,
+		y:      y,
+		w:      layer.Size.Width,
+		h:      layer.Size.Height,
+		radius: layer.Style.BorderRadius,
+	}
+
+	switch {
+	case layer.Type == "shape" && layer.Shape != nil:
+		switch normalizeShapeType(layer.Shape.Type) {
+		case "roundedrect", "roundrect":
+			geo.kind = shapeRoundedRect
+		case "ellipse", "oval":
+			geo.kind = shapeEllipse
+		case "circle":
+			geo = geo.circle()
+		case "line":
+			geo.kind = shapeLine
+			geo.points = linePoints(layer.Shape.Points, x, y, geo.w, geo.h)
+		case "polygon", "triangle":
+			geo.kind = shapePolygon
+			geo.points = polygonPoints(layer.Shape, x, y, geo.w, geo.h)
+		}
+	case layer.Type == "image":
+		switch normalizeShapeType(layer.Style.Mask) {
+		case "circle":
+			geo = geo.circle()
+		case "ellipse", "oval":
+			geo.kind = shapeEllipse
+		case "roundedrect", "roundrect", "rounded":
+			geo.kind = shapeRoundedRect
+			if geo.radius <= 0 {
+				geo.radius = math.Min(geo.w, geo.h) * defaultMaskRadius
+			}
+		}
+	}
+
+	if geo.kind == shapeRect && geo.radius > 0 {
+		geo.kind = shapeRoundedRect
+	}
+	geo.radius = math.Max(0, math.Min(geo.radius, math.Min(geo.w, geo.h)/2))
+	if geo.kind == shapeRoundedRect && geo.radius == 0 {
+		geo.kind = shapeRect
+	}
+
+	return geo
+}
+
+// normalizeShapeType lowercases a shape name and drops dashes, so
+// "rounded-rect" and "roundedRect" are the same
+func normalizeShapeType(name string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "-", ""))
+}
+
+// circle turns the box into the largest circle centered in it
+func (geo shapeGeometry) circle() shapeGeometry {
+	d := math.Min(geo.w, geo.h)
+	geo.x += (geo.w - d) / 2
+	geo.y += (geo.h - d) / 2
+	geo.w, geo.h = d, d
+	geo.kind = shapeEllipse
+	return geo
+}
+
+// linePoints uses the first two points of the shape, or a line through the
+// middle of the box along its longer side
+func linePoints(points []models.Position, x, y, w, h float64) []gofpdf.PointType {
+	if len(points) >= 2 {
+		return []gofpdf.PointType{
+			{X: x + points[0].X, Y: y + points[0].Y},
+			{X: x + points[1].X, Y: y + points[1].Y},
+		}
+	}
+	if h > w {
+		return []gofpdf.PointType{{X: x + w/2, Y: y}, {X: x + w/2, Y: y + h}}
+	}
+	return []gofpdf.PointType{{X: x, Y: y + h/2}, {X: x + w, Y: y + h/2}}
+}
+
+// polygonPoints uses the shape's points, or a regular polygon with shape.sides
+// corners (a triangle by default) inscribed in the box, pointing up
+func polygonPoints(shape *models.Shape, x, y, w, h float64) []gofpdf.PointType {
+	if len(shape.Points) >= 3 {
+		points := make([]gofpdf.PointType, len(shape.Points))
+		for i, p := range shape.Points {
+			points[i] = gofpdf.PointType{X: x + p.X, Y: y + p.Y}
+		}
+		return points
+	}
+
+	sides := shape.Sides
+	if sides < 3 {
+		sides = 3
+	}
+	cx, cy := x+w/2, y+h/2
+	points := make([]gofpdf.PointType, sides)
+	for i := range points {
+		angle := -math.Pi/2 + 2*math.Pi*float64(i)/float64(sides)
+		points[i] = gofpdf.PointType{X: cx + w/2*math.Cos(angle), Y: cy + h/2*math.Sin(angle)}
+	}
+	return points
+}
+
+// inset shrinks closed box shapes by d on every side so a stroke of width 2d
+// stays inside the original outline
+func (geo shapeGeometry) inset(d float64) shapeGeometry {
+	switch geo.kind {
+	case shapeRect, shapeRoundedRect, shapeEllipse:
+		geo.x += d
+		geo.y += d
+		geo.w = math.Max(0, geo.w-2*d)
+		geo.h = math.Max(0, geo.h-2*d)
+		geo.radius = math.Max(0, geo.radius-d)
+	}
+	return geo
+}
+
+// drawGeometry outputs the shape path with a gofpdf style string ("F" or "D")
+func (g *PDFGenerator) drawGeometry(geo shapeGeometry, styleStr string) {
+	switch geo.kind {
+	case shapeLine:
+		if styleStr == "D" && len(geo.points) == 2 {
+			g.pdf.Line(geo.points[0].X, geo.points[0].Y, geo.points[1].X, geo.points[1].Y)
+		}
+	case shapePolygon:
+		g.pdf.Polygon(geo.points, styleStr)
+	default:
+		if geo.w <= 0 || geo.h <= 0 {
+			return
+		}
+		switch geo.kind {
+		case shapeRoundedRect:
+			g.roundedRectPath(geo.x, geo.y, geo.w, geo.h, geo.radius)
+			g.pdf.DrawPath(styleStr)
+		case shapeEllipse:
+			g.pdf.Ellipse(geo.x+geo.w/2, geo.y+geo.h/2, geo.w/2, geo.h/2, 0, styleStr)
+		default:
+			g.pdf.Rect(geo.x, geo.y, geo.w, geo.h, styleStr)
+		}
+	}
+}
+
+// roundedRectPath builds a rounded rectangle path. gofpdf's RoundedRect opens a
+// graphics state (q) it never closes, which would break the nesting of
+// rotation transforms, so the path is built here instead.
+func (g *PDFGenerator) roundedRectPath(x, y, w, h, r float64) {
+	// Control point distance for a quarter circle drawn as a cubic Bézier
+	k := r * 4 / 3 * (math.Sqrt2 - 1)
+
+	g.pdf.MoveTo(x+r, y)
+	g.pdf.LineTo(x+w-r, y)
+	g.pdf.CurveBezierCubicTo(x+w-r+k, y, x+w, y+r-k, x+w, y+r)
+	g.pdf.LineTo(x+w, y+h-r)
+	g.pdf.CurveBezierCubicTo(x+w, y+h-r+k, x+w-r+k, y+h, x+w-r, y+h)
+	g.pdf.LineTo(x+r, y+h)
+	g.pdf.CurveBezierCubicTo(x+r-k, y+h, x, y+h-r+k, x, y+h-r)
+	g.pdf.LineTo(x, y+r)
+	g.pdf.CurveBezierCubicTo(x, y+r-k, x+r-k, y, x+r, y)
+	g.pdf.ClosePath()
+}
+
+// clipGeometry restricts drawing to the inside of a closed shape. The clip is a
+// vector path, so edges stay sharp at any resolution. Call the returned
+// function to end the clip.
+func (g *PDFGenerator) clipGeometry(geo shapeGeometry) func() {
+	// ClipEnd emits Q, which also restores the opacity set before the clip
+	savedAlpha := g.gsAlpha
+
+	switch geo.kind {
+	case shapeRoundedRect:
+		g.pdf.ClipRoundedRect(geo.x, geo.y, geo.w, geo.h, geo.radius, false)
+	case shapeEllipse:
+		g.pdf.ClipEllipse(geo.x+geo.w/2, geo.y+geo.h/2, geo.w/2, geo.h/2, false)
+	case shapePolygon:
+		g.pdf.ClipPolygon(geo.points, false)
+	default:
+		g.pdf.ClipRect(geo.x, geo.y, geo.w, geo.h, false)
+	}
+
+	return func() {
+		g.pdf.ClipEnd()
+		g.gsAlpha = savedAlpha
+	}
+}
+
+// fillGeometry fills the shape with style.backgroundColor, blended with
+// backgroundColorAlpha (and any alpha in the color itself) on top of the layer opacity
+func (g *PDFGenerator) fillGeometry(geo shapeGeometry, style models.Style) {
+	if geo.kind == shapeLine {
+		return
+	}
+	r, gr, b, colorAlpha, ok := parseColor(style.BackgroundColor)
+	if !ok {
+		return
+	}
+
+	alpha := g.alpha * style.BackgroundAlpha() * colorAlpha
+	if alpha <= 0 {
+		return
+	}
+
+	g.setAlpha(alpha)
+	g.pdf.SetFillColor(r, gr, b)
+	g.drawGeometry(geo, "F")
+	g.setAlpha(g.alpha)
+}
+
+// layerStroke resolves the border of a layer. A border needs both a color and
+// a width, as in CSS; borderStyle "none" or "hidden" turns it off.
+func layerStroke(style models.Style) (strokeStyle, bool) {
+	borderStyle := strings.ToLower(strings.TrimSpace(style.BorderStyle))
+	if style.BorderWidth <= 0 || borderStyle == "none" || borderStyle == "hidden" {
+		return strokeStyle{}, false
+	}
+
+	r, g, b, alpha, ok := parseColor(style.BorderColor)
+	if !ok {
+		return strokeStyle{}, false
+	}
+
+	stroke := strokeStyle{r: r, g: g, b: b, alpha: alpha, width: style.BorderWidth}
+	switch {
+	case len(style.BorderDash) > 0:
+		stroke.dash = style.BorderDash
+	case borderStyle == "dashed":
+		stroke.dash = []float64{3 * stroke.width, 2 * stroke.width}
+	case borderStyle == "dotted":
+		// Zero-length dashes with round caps draw dots one stroke width across
+		stroke.dash = []float64{0, 2 * stroke.width}
+		stroke.round = true
+	}
+	return stroke, true
+}
+
+// lineStroke resolves the stroke of a line shape. Lines have nothing to fill,
+// so a missing border color falls back to the background color (designers
+// often draw rules as thin filled boxes), then the text color, then black.
+func lineStroke(style models.Style) (strokeStyle, bool) {
+	if style.BorderWidth <= 0 {
+		style.BorderWidth = defaultLineWidth
+	}
+	for _, color := range []string{style.BorderColor, style.BackgroundColor, style.Color, "#000000"} {
+		if _, _, _, _, ok := parseColor(color); ok {
+			style.BorderColor = color
+			break
+		}
+	}
+	return layerStroke(style)
+}
+
+// strokeGeometry draws the outline of the shape with the given stroke
+func (g *PDFGenerator) strokeGeometry(geo shapeGeometry, stroke strokeStyle) {
+	alpha := g.alpha * stroke.alpha
+	if stroke.width <= 0 || alpha <= 0 {
+		return
+	}
+
+	prevWidth := g.pdf.GetLineWidth()
+	pr, pg, pb := g.pdf.GetDrawColor()
+
+	g.setAlpha(alpha)
+	g.pdf.SetDrawColor(stroke.r, stroke.g, stroke.b)
+	g.pdf.SetLineWidth(stroke.width)
+	if len(stroke.dash) > 0 {
+		g.pdf.SetDashPattern(stroke.dash, 0)
+	}
+	if stroke.round {
+		g.pdf.SetLineCapStyle("round")
+	}
+
+	g.drawGeometry(geo.inset(stroke.width/2), "D")
+
+	if stroke.round {
+		g.pdf.SetLineCapStyle("butt")
+	}
+	if len(stroke.dash) > 0 {
+		g.pdf.SetDashPattern([]float64{}, 0)
+	}
+	g.pdf.SetLineWidth(prevWidth)
+	g.pdf.SetDrawColor(pr, pg, pb)
+	g.setAlpha(g.alpha)
+}
@@ -0,0 +1,137 @@
+package generator
+
+import (
+	"badge-service/internal/models"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+func TestLayerGeometry(t *testing.T) {
+	box := models.Size{Width: 40, Height: 20}
+	shape := func(kind string) models.Layer {
+		return models.Layer{Type: "shape", Size: box, Shape: &models.Shape{Type: kind}}
+	}
+	tests := []struct {
+		name  string
+		layer models.Layer
+		want  shapeGeometry
+	}{
+		{"plain box", models.Layer{Type: "text", Size: box},
+			shapeGeometry{kind: shapeRect, x: 10, y: 5, w: 40, h: 20}},
+		{"border radius", models.Layer{Type: "text", Size: box, Style: models.Style{BorderRadius: 3}},
+			shapeGeometry{kind: shapeRoundedRect, x: 10, y: 5, w: 40, h: 20, radius: 3}},
+		{"radius capped at half the short side", models.Layer{Type: "container", Size: box, Style: models.Style{BorderRadius: 50}},
+			shapeGeometry{kind: shapeRoundedRect, x: 10, y: 5, w: 40, h: 20, radius: 10}},
+		{"rectangle shape", shape("rect"),
+			shapeGeometry{kind: shapeRect, x: 10, y: 5, w: 40, h: 20}},
+		{"rounded rect without radius", shape("rounded-rect"),
+			shapeGeometry{kind: shapeRect, x: 10, y: 5, w: 40, h: 20}},
+		{"ellipse", shape("Oval"),
+			shapeGeometry{kind: shapeEllipse, x: 10, y: 5, w: 40, h: 20}},
+		{"circle centered in the box", shape("circle"),
+			shapeGeometry{kind: shapeEllipse, x: 20, y: 5, w: 20, h: 20}},
+		{"horizontal line", shape("line"),
+			shapeGeometry{kind: shapeLine, x: 10, y: 5, w: 40, h: 20, points: []gofpdf.PointType{{X: 10, Y: 15}, {X: 50, Y: 15}}}},
+		{"line through points", models.Layer{Type: "shape", Size: box, Shape: &models.Shape{Type: "line", Points: []models.Position{{X: 0, Y: 0}, {X: 40, Y: 20}}}},
+			shapeGeometry{kind: shapeLine, x: 10, y: 5, w: 40, h: 20, points: []gofpdf.PointType{{X: 10, Y: 5}, {X: 50, Y: 25}}}},
+	}
+	for _, tt := range tests {
+		if got := layerGeometry(tt.layer, 10, 5); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: layerGeometry = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPolygonPoints(t *testing.T) {
+	// A triangle inscribed in the box, pointing up
+	points := polygonPoints(&models.Shape{Type: "triangle"}, 0, 0, 20, 20)
+	want := []gofpdf.PointType{{X: 10, Y: 0}, {X: 10 + 10*math.Cos(math.Pi/6), Y: 15}, {X: 10 - 10*math.Cos(math.Pi/6), Y: 15}}
+	if len(points) != len(want) {
+		t.Fatalf("got %d points, want %d", len(points), len(want))
+	}
+	for i := range points {
+		if math.Abs(points[i].X-want[i].X) > 1e-9 || math.Abs(points[i].Y-want[i].Y) > 1e-9 {
+			t.Errorf("point %d = %v, want %v", i, points[i], want[i])
+		}
+	}
+
+	if n := len(polygonPoints(&models.Shape{Type: "polygon", Sides: 6}, 0, 0, 20, 20)); n != 6 {
+		t.Errorf("hexagon has %d points", n)
+	}
+}
+
+func TestLayerStroke(t *testing.T) {
+	tests := []struct {
+		name  string
+		style models.Style
+		want  strokeStyle
+		ok    bool
+	}{
+		{"solid", models.Style{BorderColor: "#ff0000", BorderWidth: 0.5},
+			strokeStyle{r: 255, alpha: 1, width: 0.5}, true},
+		{"no width", models.Style{BorderColor: "#ff0000"}, strokeStyle{}, false},
+		{"no color", models.Style{BorderWidth: 0.5}, strokeStyle{}, false},
+		{"transparent", models.Style{BorderColor: "transparent", BorderWidth: 0.5}, strokeStyle{}, false},
+		{"style none", models.Style{BorderColor: "#000", BorderWidth: 0.5, BorderStyle: "none"}, strokeStyle{}, false},
+		{"dashed", models.Style{BorderColor: "#000", BorderWidth: 0.5, BorderStyle: "Dashed"},
+			strokeStyle{alpha: 1, width: 0.5, dash: []float64{1.5, 1}}, true},
+		{"dotted", models.Style{BorderColor: "#000", BorderWidth: 0.5, BorderStyle: "dotted"},
+			strokeStyle{alpha: 1, width: 0.5, dash: []float64{0, 1}, round: true}, true},
+		{"explicit dash", models.Style{BorderColor: "#000", BorderWidth: 0.5, BorderStyle: "dashed", BorderDash: []float64{2, 1}},
+			strokeStyle{alpha: 1, width: 0.5, dash: []float64{2, 1}}, true},
+	}
+	for _, tt := range tests {
+		got, ok := layerStroke(tt.style)
+		if !reflect.DeepEqual(got, tt.want) || ok != tt.ok {
+			t.Errorf("%s: layerStroke = %+v, %v; want %+v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLineStroke(t *testing.T) {
+	tests := []struct {
+		name  string
+		style models.Style
+		want  strokeStyle
+	}{
+		{"border color", models.Style{BorderColor: "#0000ff", BackgroundColor: "#ff0000", BorderWidth: 1},
+			strokeStyle{b: 255, alpha: 1, width: 1}},
+		{"background color", models.Style{BackgroundColor: "#ff0000"},
+			strokeStyle{r: 255, alpha: 1, width: defaultLineWidth}},
+		{"black", models.Style{}, strokeStyle{alpha: 1, width: defaultLineWidth}},
+	}
+	for _, tt := range tests {
+		got, ok := lineStroke(tt.style)
+		if !ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: lineStroke = %+v, %v; want %+v", tt.name, got, ok, tt.want)
+		}
+	}
+}
+
+func TestLayerGeometryMasks(t *testing.T) {
+	image := func(mask string, radius float64) models.Layer {
+		return models.Layer{Type: "image", Size: models.Size{Width: 30, Height: 40}, Style: models.Style{Mask: mask, BorderRadius: radius}}
+	}
+	tests := []struct {
+		name  string
+		layer models.Layer
+		want  shapeGeometry
+	}{
+		{"no mask", image("", 0), shapeGeometry{kind: shapeRect, w: 30, h: 40}},
+		{"circle", image("circle", 0), shapeGeometry{kind: shapeEllipse, y: 5, w: 30, h: 30}},
+		{"ellipse", image("ellipse", 0), shapeGeometry{kind: shapeEllipse, w: 30, h: 40}},
+		{"rounded rect default radius", image("roundedRect", 0), shapeGeometry{kind: shapeRoundedRect, w: 30, h: 40, radius: 30 * defaultMaskRadius}},
+		{"rounded rect with radius", image("rounded-rect", 2), shapeGeometry{kind: shapeRoundedRect, w: 30, h: 40, radius: 2}},
+		{"unknown mask", image("star", 0), shapeGeometry{kind: shapeRect, w: 30, h: 40}},
+		{"mask on a text layer", models.Layer{Type: "text", Size: models.Size{Width: 30, Height: 40}, Style: models.Style{Mask: "circle"}},
+			shapeGeometry{kind: shapeRect, w: 30, h: 40}},
+	}
+	for _, tt := range tests {
+		if got := layerGeometry(tt.layer, 0, 0); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: layerGeometry = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package generator
+
+import (
+	"badge-service/internal/cache"
+	"badge-service/internal/models"
+	"badge-service/internal/svg"
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"image/png"
+	"math"
+	"os"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// SVG image layers: the document is parsed into paths and drawn with PDF
+// path operators, so logos and icons stay sharp at any print resolution.
+// Fills and strokes use PDF colors, opacity, dashes and shadings; shapes whose
+// paint PDF shadings can't express are rasterized at the template DPI.
+
+// maxRasterShapes bounds the shapes of one SVG that are rasterized; each can
+// take an image of up to 4096 x 4096 pixels
+const maxRasterShapes = 32
+
+// renderSVG draws an SVG image in the layer box. The fit mode places the
+// SVG's viewport like placeImage places bitmaps, and the layer outline
+// (box, borderRadius or mask) clips it.
+func (g *PDFGenerator) renderSVG(layer models.Layer, x, y float64, data []byte) error {
+	doc, err := svg.Parse(data)
+	if err != nil {
+		return fmt.Errorf("layer '%s': invalid SVG: %w", layer.ID, err)
+	}
+
+	warnings := append([]string(nil), doc.Warnings...)
+	warned := make(map[string]bool)
+	for _, w := range warnings {
+		warned[w] = true
+	}
+
+	defer g.clipGeometry(layerGeometry(layer, x, y))()
+
+	// Shapes set colors, line width, caps, joins and dashes inside their own
+	// q/Q; gofpdf's record of them is put back afterwards, as it re-emits
+	// them on new pages
+	lineWidth := g.pdf.GetLineWidth()
+	fr, fg, fb := g.pdf.GetFillColor()
+	dr, dg, db := g.pdf.GetDrawColor()
+	defer func() {
+		g.pdf.SetLineWidth(lineWidth)
+		g.pdf.SetFillColor(fr, fg, fb)
+		g.pdf.SetDrawColor(dr, dg, db)
+		g.pdf.SetLineCapStyle("butt")
+		g.pdf.SetLineJoinStyle("miter")
+		g.pdf.SetDashPattern([]float64{}, 0)
+	}()
+
+	root := doc.Transform(svgViewport(layer, x, y, doc))
+	box := svg.Rect{X: x, Y: y, W: layer.Size.Width, H: layer.Size.Height}
+	rasterized := 0
+	for _, shape := range doc.Shapes {
+		if reason := rasterReason(shape); reason != "" {
+			if rasterized >= maxRasterShapes {
+				if w := fmt.Sprintf("more than %d shapes need rasterizing; the rest are left out", maxRasterShapes); !warned[w] {
+					warned[w] = true
+					warnings = append(warnings, w)
+				}
+				continue
+			}
+			rasterized++
+			if w := reason + " are rasterized"; !warned[w] {
+				warned[w] = true
+				warnings = append(warnings, w)
+			}
+			if err := g.rasterizeSVGShape(shape, root, box); err != nil {
+				return fmt.Errorf("layer '%s': %w", layer.ID, err)
+			}
+			continue
+		}
+		g.drawSVGShape(shape, root)
+	}
+
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "SVG warning for layer %s: %s\n", layer.ID, w)
+	}
+	return nil
+}
+
+// svgViewport returns the page area the SVG's viewport maps to. fill uses the
+// layer box and leaves the aspect ratio to the SVG's preserveAspectRatio, as
+// browsers do; contain fits the intrinsic size in the box, cover covers the
+// box and none keeps the intrinsic size, aligned by the focal point.
+func svgViewport(layer models.Layer, x, y float64, doc *svg.Document) svg.Rect {
+	boxW, boxH := layer.Size.Width, layer.Size.Height
+	w, h := doc.Width, doc.Height
+	fit := layer.ObjectFit()
+	if fit == cache.FitFill || w <= 0 || h <= 0 {
+		return svg.Rect{X: x, Y: y, W: boxW, H: boxH}
+	}
+
+	switch fit {
+	case cache.FitContain:
+		scale := math.Min(boxW/w, boxH/h)
+		w, h = w*scale, h*scale
+	case cache.FitCover:
+		scale := math.Max(boxW/w, boxH/h)
+		w, h = w*scale, h*scale
+	}
+
+	focusX, focusY := layer.Focus()
+	return svg.Rect{X: x + (boxW-w)*focusX, Y: y + (boxH-h)*focusY, W: w, H: h}
+}
+
+// rasterReason returns why a shape can't be drawn with PDF paint, or "" when
+// it can. PDF shadings here have two colors, no per-stop opacity and always
+// pad, and only the fill can use one.
+func rasterReason(shape *svg.Shape) string {
+	if !shape.Stroke.None() && shape.Stroke.Gradient != nil {
+		return "gradient strokes"
+	}
+	grad := shape.Fill.Gradient
+	if shape.Fill.None() || grad == nil {
+		return ""
+	}
+	if grad.Spread == "reflect" || grad.Spread == "repeat" {
+		return "gradients with spreadMethod " + grad.Spread
+	}
+	for _, stop := range grad.Stops[1:] {
+		if stop.Opacity != grad.Stops[0].Opacity {
+			return "gradients with varying stop-opacity"
+		}
+	}
+	if grad.Radial && len(grad.Stops) > 2 {
+		return "radial gradients with more than two stops"
+	}
+	if grad.Radial && grad.Stops[0].Offset > 0 {
+		return "radial gradients whose first stop is past 0"
+	}
+	return ""
+}
+
+// pdfMatrix converts a transform of page coordinates in mm (y down) to the
+// matrix gofpdf's Transform concatenates (points, y up)
+func (g *PDFGenerator) pdfMatrix(m svg.Matrix) gofpdf.TransformMatrix {
+	k := g.pdf.GetConversionRatio()
+	_, h := g.pdf.GetPageSize()
+	toPDF := svg.Matrix{A: k, D: -k, F: k * h}
+	fromPDF := svg.Matrix{A: 1 / k, D: -1 / k, F: h}
+	t := toPDF.Mul(m).Mul(fromPDF)
+	return gofpdf.TransformMatrix{A: t.A, B: t.B, C: t.C, D: t.D, E: t.E, F: t.F}
+}
+
+// tracePath adds a path to the PDF, its coordinates multiplied by scale.
+// gofpdf writes path coordinates with two decimals, so paths are drawn at
+// roughly page size and the transform scales them back.
+func (g *PDFGenerator) tracePath(p svg.Path, scale float64) {
+	for _, seg := range p {
+		switch seg.Op {
+		case svg.MoveTo:
+			g.pdf.MoveTo(seg.P[0].X*scale, seg.P[0].Y*scale)
+		case svg.LineTo:
+			g.pdf.LineTo(seg.P[0].X*scale, seg.P[0].Y*scale)
+		case svg.CubeTo:
+			g.pdf.CurveBezierCubicTo(
+				seg.P[0].X*scale, seg.P[0].Y*scale,
+				seg.P[1].X*scale, seg.P[1].Y*scale,
+				seg.P[2].X*scale, seg.P[2].Y*scale)
+		case svg.Close:
+			g.pdf.ClosePath()
+		}
+	}
+}
+
+// drawSVGShape fills and strokes a shape. root maps the document's user space
+// to the page.
+func (g *PDFGenerator) drawSVGShape(shape *svg.Shape, root svg.Matrix) {
+	m := root.Mul(shape.Transform)
+	s := m.Scale()
+	rs := root.Scale()
+	if s == 0 || rs == 0 || len(shape.Path) == 0 {
+		return
+	}
+
+	// Each shape's transform, clips and stroke settings end with its Q
+	savedAlpha := g.gsAlpha
+	g.pdf.TransformBegin()
+	defer func() {
+		g.pdf.TransformEnd()
+		g.gsAlpha = savedAlpha
+	}()
+
+	// Clip paths are in the document's user space
+	space := svg.Identity
+	if len(shape.Clips) > 0 {
+		space = root.Mul(svg.Scaling(1/rs, 1/rs))
+		g.pdf.Transform(g.pdfMatrix(space))
+		for _, c := range shape.Clips {
+			g.tracePath(c.Path, rs)
+			if c.EvenOdd {
+				g.pdf.DrawPath("W* n")
+			} else {
+				g.pdf.DrawPath("W n")
+			}
+		}
+	}
+	inverse, _ := space.Invert()
+	if rel := inverse.Mul(m).Mul(svg.Scaling(1/s, 1/s)); rel != svg.Identity {
+		g.pdf.Transform(g.pdfMatrix(rel))
+	}
+
+	if !shape.Fill.None() {
+		if shape.Fill.Gradient != nil {
+			g.fillSVGGradient(shape, m, s)
+		} else {
+			c := shape.Fill.Color
+			g.pdf.SetFillColor(int(c.R), int(c.G), int(c.B))
+			g.setAlpha(g.alpha * shape.Opacity * shape.Fill.Opacity)
+			g.tracePath(shape.Path, s)
+			if shape.EvenOdd {
+				g.pdf.DrawPath("F*")
+			} else {
+				g.pdf.DrawPath("F")
+			}
+		}
+	}
+
+	if !shape.Stroke.None() && shape.Width > 0 {
+		c := shape.Stroke.Color
+		g.pdf.SetDrawColor(int(c.R), int(c.G), int(c.B))
+		g.pdf.SetLineWidth(shape.Width * s)
+		g.pdf.SetLineCapStyle(shape.Cap)
+		g.pdf.SetLineJoinStyle(shape.Join)
+		g.pdf.RawWriteStr(fmt.Sprintf("%.2f M", math.Max(1, shape.Miter)))
+		if len(shape.Dash) > 0 {
+			dash := make([]float64, len(shape.Dash))
+			for i, d := range shape.Dash {
+				dash[i] = d * s
+			}
+			g.pdf.SetDashPattern(dash, shape.DashFrom*s)
+		}
+		g.setAlpha(g.alpha * shape.Opacity * shape.Stroke.Opacity)
+		g.tracePath(shape.Path, s)
+		g.pdf.DrawPath("D")
+	}
+}
+
+// fillSVGGradient fills a shape with a linear or two-stop radial gradient.
+// The shape clips a PDF shading painted over a square around it in gradient
+// coordinates, so radial gradients stay circles. The current transform maps
+// the shape's user space, scaled by s, to the page; m maps it unscaled.
+func (g *PDFGenerator) fillSVGGradient(shape *svg.Shape, m svg.Matrix, s float64) {
+	grad := shape.Fill.Gradient
+	bounds := shape.Path.Bounds()
+	space := grad.Space(bounds)
+	toGradient, ok := space.Invert()
+	t := m.Mul(space).Scale()
+	if !ok || t == 0 {
+		return
+	}
+
+	savedAlpha := g.gsAlpha
+	g.pdf.TransformBegin()
+	defer func() {
+		g.pdf.TransformEnd()
+		g.gsAlpha = savedAlpha
+	}()
+
+	g.tracePath(shape.Path, s)
+	if shape.EvenOdd {
+		g.pdf.DrawPath("W* n")
+	} else {
+		g.pdf.DrawPath("W n")
+	}
+	// Gradient coordinates, scaled by t
+	g.pdf.Transform(g.pdfMatrix(svg.Scaling(s, s).Mul(space).Mul(svg.Scaling(1/t, 1/t))))
+
+	// The square covering the shape, in scaled gradient coordinates
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, corner := range []svg.Point{
+		{X: bounds.X, Y: bounds.Y}, {X: bounds.X + bounds.W, Y: bounds.Y},
+		{X: bounds.X, Y: bounds.Y + bounds.H}, {X: bounds.X + bounds.W, Y: bounds.Y + bounds.H},
+	} {
+		p := toGradient.Apply(corner)
+		minX, maxX = math.Min(minX, p.X*t), math.Max(maxX, p.X*t)
+		minY, maxY = math.Min(minY, p.Y*t), math.Max(maxY, p.Y*t)
+	}
+	side := math.Max(maxX-minX, maxY-minY)
+	if side <= 0 {
+		return
+	}
+	sx := (minX+maxX)/2 - side/2
+	sy := (minY+maxY)/2 - side/2
+	// norm maps scaled gradient coordinates to the shading's unit square, whose
+	// origin is the lower-left corner
+	norm := func(x, y float64) (float64, float64) {
+		return (x*t - sx) / side, 1 - (y*t-sy)/side
+	}
+
+	stops := grad.Stops
+	g.setAlpha(g.alpha * shape.Opacity * shape.Fill.Opacity * stops[0].Opacity)
+
+	if grad.Radial {
+		// Offset 0 is at the focal point and the second stop's color is
+		// reached on the circle that far towards the end circle
+		fx, fy := grad.FX, grad.FY
+		if d := math.Hypot(fx-grad.CX, fy-grad.CY); d > grad.R*0.999 && d > 0 {
+			fx = grad.CX + (fx-grad.CX)*grad.R*0.999/d
+			fy = grad.CY + (fy-grad.CY)*grad.R*0.999/d
+		}
+		o := stops[len(stops)-1].Offset
+		cx, cy := fx+o*(grad.CX-fx), fy+o*(grad.CY-fy)
+		x1, y1 := norm(fx, fy)
+		x2, y2 := norm(cx, cy)
+		c1, c2 := stops[0].Color, stops[len(stops)-1].Color
+		g.pdf.RadialGradient(sx, sy, side, side,
+			int(c1.R), int(c1.G), int(c1.B), int(c2.R), int(c2.G), int(c2.B),
+			x1, y1, x2, y2, o*grad.R*t/side)
+		return
+	}
+
+	// Linear gradients are drawn a band per pair of stops. Shadings extend
+	// past both ends, so each band clips to the half plane after its first
+	// stop and covers the bands before it.
+	dx, dy := grad.X2-grad.X1, grad.Y2-grad.Y1
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return
+	}
+	ux, uy := dx/length, dy/length
+	reach := side * 2
+	for i := 0; i+1 < len(stops); i++ {
+		a, b := stops[i], stops[i+1]
+		if b.Offset <= a.Offset {
+			continue
+		}
+		ax, ay := grad.X1+a.Offset*dx, grad.Y1+a.Offset*dy
+		bx, by := grad.X1+b.Offset*dx, grad.Y1+b.Offset*dy
+		if i > 0 {
+			px, py := ax*t, ay*t
+			g.pdf.ClipPolygon([]gofpdf.PointType{
+				{X: px - uy*reach, Y: py + ux*reach},
+				{X: px + uy*reach, Y: py - ux*reach},
+				{X: px + uy*reach + ux*reach, Y: py - ux*reach + uy*reach},
+				{X: px - uy*reach + ux*reach, Y: py + ux*reach + uy*reach},
+			}, false)
+		}
+		x1, y1 := norm(ax, ay)
+		x2, y2 := norm(bx, by)
+		g.pdf.LinearGradient(sx, sy, side, side,
+			int(a.Color.R), int(a.Color.G), int(a.Color.B), int(b.Color.R), int(b.Color.G), int(b.Color.B),
+			x1, y1, x2, y2)
+		if i > 0 {
+			g.pdf.ClipEnd()
+		}
+	}
+}
+
+// rasterizeSVGShape draws the part of a shape inside the layer box as an
+// image at the template DPI
+func (g *PDFGenerator) rasterizeSVGShape(shape *svg.Shape, root svg.Matrix, box svg.Rect) error {
+	img, area := svg.Rasterize(shape, root, box, float64(g.dpi))
+	if img == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return fmt.Errorf("failed to encode rasterized SVG shape: %w", err)
+	}
+	hash := md5.Sum(buf.Bytes())
+	imageName := fmt.Sprintf("svg_%x", hash[:8])
+
+	info := g.pdf.RegisterImageOptionsReader(imageName, gofpdf.ImageOptions{
+		ImageType: "PNG",
+	}, &buf)
+	if info == nil {
+		return fmt.Errorf("failed to register rasterized SVG shape")
+	}
+
+	g.setAlpha(g.alpha)
+	g.pdf.ImageOptions(imageName, area.X, area.Y, area.W, area.H, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+	return nil
+}
@@ -0,0 +1,69 @@
+package generator
+
+import "strings"
+
+// textDecoration is the parsed CSS text-decoration-line of a text layer
+type textDecoration struct {
+	underline   bool
+	lineThrough bool
+	overline    bool
+}
+
+func (d textDecoration) any() bool {
+	return d.underline || d.lineThrough || d.overline
+}
+
+// parseTextDecoration accepts CSS values such as "underline",
+// "line-through" or "underline line-through"; "none" or unknown values draw nothing
+func parseTextDecoration(value string) textDecoration {
+	var d textDecoration
+	for _, part := range strings.Fields(strings.ToLower(value)) {
+		switch part {
+		case "underline":
+			d.underline = true
+		case "line-through", "strikethrough", "strike":
+			d.lineThrough = true
+		case "overline":
+			d.overline = true
+		}
+	}
+	return d
+}
+
+// drawTextDecoration draws the decoration lines for one line of text that was
+// drawn in a w x h cell at (x, y). Because it runs per line, underlines follow
+// wrapped text instead of spanning the whole layer.
+func (g *PDFGenerator) drawTextDecoration(tl textLayout, x, y, w, h, textWidth float64) {
+	if textWidth <= 0 {
+		return
+	}
+
+	g.useFont(tl.fonts.primary(), tl.size)
+	startX, baseline := g.lineOrigin(x, y, w, h, textWidth, tl.align)
+	_, fontHeight := g.pdf.GetFontSize()
+
+	// Offsets relative to the baseline, as fractions of the font size.
+	// These match the underline metrics of typical sans-serif faces.
+	thickness := fontHeight * 0.06
+	prevWidth := g.pdf.GetLineWidth()
+	pr, pg, pb := g.pdf.GetDrawColor()
+	g.pdf.SetLineWidth(thickness)
+	g.pdf.SetDrawColor(tl.color[0], tl.color[1], tl.color[2])
+
+	drawAt := func(offset float64) {
+		lineY := baseline + offset
+		g.pdf.Line(startX, lineY, startX+textWidth, lineY)
+	}
+	if tl.decoration.underline {
+		drawAt(fontHeight * 0.12)
+	}
+	if tl.decoration.lineThrough {
+		drawAt(-fontHeight * 0.28)
+	}
+	if tl.decoration.overline {
+		drawAt(-fontHeight * 0.78)
+	}
+
+	g.pdf.SetLineWidth(prevWidth)
+	g.pdf.SetDrawColor(pr, pg, pb)
+}
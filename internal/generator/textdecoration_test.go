@@ -0,0 +1,29 @@
+package generator
+
+import "testing"
+
+func TestParseTextDecoration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  textDecoration
+	}{
+		{"", textDecoration{}},
+		{"none", textDecoration{}},
+		{"underline", textDecoration{underline: true}},
+		{"Line-Through", textDecoration{lineThrough: true}},
+		{"strikethrough", textDecoration{lineThrough: true}},
+		{"overline", textDecoration{overline: true}},
+		{"underline line-through", textDecoration{underline: true, lineThrough: true}},
+		{"underline dotted red", textDecoration{underline: true}},
+		{"blink", textDecoration{}},
+	}
+	for _, tt := range tests {
+		got := parseTextDecoration(tt.value)
+		if got != tt.want {
+			t.Errorf("parseTextDecoration(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+		if got.any() != (tt.want != textDecoration{}) {
+			t.Errorf("parseTextDecoration(%q).any() = %v", tt.value, got.any())
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package generator
+
+import (
+	"badge-service/internal/fonts"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Per-glyph font fallback.
+//
+// A text layer is drawn with a font chain: the face resolved from the layer's
+// fontFamily/fontWeight followed by the registry's fallback faces. Each line
+// is split into runs of consecutive characters that the same font can draw,
+// and runs are measured and drawn one after another on a shared baseline.
+
+// fontRef is one font of a chain as gofpdf knows it
+type fontRef struct {
+	family string
+	style  string
+	face   *fonts.Face // nil for the PDF core fonts
+}
+
+// covers reports whether the font has a glyph for r
+func (f fontRef) covers(r rune) bool {
+	if f.face == nil {
+		// Core fonts are cp1252 only
+		return r < 0x80 || (r >= 0xA0 && r <= 0xFF)
+	}
+	return f.face.Covers(r)
+}
+
+// fontChain is the primary font of a layer followed by its glyph fallbacks
+type fontChain struct {
+	fonts []fontRef
+}
+
+// primary returns the font the layer asked for
+func (fc fontChain) primary() fontRef {
+	return fc.fonts[0]
+}
+
+// textRun is a piece of a line drawn with a single font
+type textRun struct {
+	text string
+	font fontRef
+}
+
+// textLayout is what every line of a text layer is drawn with
+type textLayout struct {
+	fonts      fontChain
+	size       float64 // font size in points
+	align      string  // CellFormat alignment, e.g. "CM"
+	rtl        bool    // paragraph direction
+	decoration textDecoration
+	color      [3]int // text color, also used for decoration lines
+}
+
+// buildFontChain resolves the layer font and appends the fallback faces
+func (g *PDFGenerator) buildFontChain(family, weight string, italic bool) fontChain {
+	primary := g.resolveFont(family, weight, italic)
+	chain := fontChain{fonts: []fontRef{primary}}
+
+	seen := map[string]bool{primary.family: true}
+	for _, face := range fonts.Fallbacks(fonts.ParseWeight(weight), italic) {
+		if seen[face.ID] {
+			continue
+		}
+		seen[face.ID] = true
+		chain.fonts = append(chain.fonts, fontRef{family: face.ID, face: face})
+	}
+
+	return chain
+}
+
+// useFont makes f the current PDF font, registering its face on first use
+func (g *PDFGenerator) useFont(f fontRef, size float64) bool {
+	if f.face != nil && !g.fontFaces[f.face.ID] {
+		data, err := f.face.Data()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load font %s: %v\n", f.face.Path, err)
+			return false
+		}
+		g.pdf.AddUTF8FontFromBytes(f.face.ID, "", data)
+		g.fontFaces[f.face.ID] = true
+	}
+	g.pdf.SetFont(f.family, f.style, size)
+	return true
+}
+
+// splitRuns breaks text into runs by glyph coverage. Each character goes to the
+// first font of the chain that has it; spaces and combining marks stay with the
+// run they are in so words aren't split needlessly. Characters no font has are
+// left with the primary font.
+func (g *PDFGenerator) splitRuns(fc fontChain, text string) []textRun {
+	if len(fc.fonts) == 1 {
+		return []textRun{{text: text, font: fc.primary()}}
+	}
+
+	var runs []textRun
+	var current strings.Builder
+	currentIdx := -1
+
+	flush := func() {
+		if current.Len() > 0 {
+			runs = append(runs, textRun{text: current.String(), font: fc.fonts[currentIdx]})
+			current.Reset()
+		}
+	}
+
+	for _, r := range text {
+		sticky := unicode.IsSpace(r) || unicode.Is(unicode.Mn, r) || r == 0x200D || r == 0xFE0F
+		if currentIdx >= 0 && (sticky || fc.fonts[currentIdx].covers(r)) {
+			current.WriteRune(r)
+			continue
+		}
+
+		idx := 0
+		for i, f := range fc.fonts {
+			if f.covers(r) {
+				idx = i
+				break
+			}
+		}
+
+		if idx != currentIdx {
+			flush()
+			currentIdx = idx
+		}
+		current.WriteRune(r)
+	}
+	flush()
+
+	return runs
+}
+
+// measureText returns the width of text at the given size, summing its runs
+func (g *PDFGenerator) measureText(fc fontChain, text string, size float64) float64 {
+	var width float64
+	for _, run := range g.splitRuns(fc, text) {
+		if g.useFont(run.font, size) {
+			width += g.pdf.GetStringWidth(run.text)
+		}
+	}
+	g.useFont(fc.primary(), size)
+	return width
+}
+
+// needsFallback reports whether any character of text is missing from the primary font
+func (fc fontChain) needsFallback(text string) bool {
+	if len(fc.fonts) == 1 {
+		return false
+	}
+	primary := fc.primary()
+	for _, r := range text {
+		if !unicode.IsSpace(r) && !primary.covers(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// lineOrigin returns where CellFormat would start a line of the given width
+// inside a w x h cell at (x, y), and the baseline it would use
+func (g *PDFGenerator) lineOrigin(x, y, w, h, textWidth float64, alignStr string) (float64, float64) {
+	margin := g.pdf.GetCellMargin()
+
+	startX := x + margin
+	switch {
+	case strings.Contains(alignStr, "R"):
+		startX = x + w - margin - textWidth
+	case strings.Contains(alignStr, "C"):
+		startX = x + (w-textWidth)/2
+	}
+
+	_, fontHeight := g.pdf.GetFontSize()
+	dy := 0.0
+	switch {
+	case strings.Contains(alignStr, "T"):
+		dy = (fontHeight - h) / 2
+	case strings.Contains(alignStr, "B"):
+		dy = (h - fontHeight) / 2
+	}
+
+	return startX, y + dy + 0.5*h + 0.3*fontHeight
+}
+
+// drawRuns draws one line of visual-order text inside a w x h cell at the
+// current position, aligned like CellFormat (alignStr such as "CM")
+func (g *PDFGenerator) drawRuns(fc fontChain, w, h float64, line, alignStr string, size float64) {
+	x, y := g.pdf.GetXY()
+	runs := g.splitRuns(fc, line)
+
+	total := 0.0
+	widths := make([]float64, len(runs))
+	for i, run := range runs {
+		if g.useFont(run.font, size) {
+			widths[i] = g.pdf.GetStringWidth(run.text)
+			total += widths[i]
+		}
+	}
+
+	g.useFont(fc.primary(), size)
+	cursor, baseline := g.lineOrigin(x, y, w, h, total, alignStr)
+
+	for i, run := range runs {
+		if g.useFont(run.font, size) {
+			g.pdf.Text(cursor, baseline, run.text)
+		}
+		cursor += widths[i]
+	}
+
+	g.useFont(fc.primary(), size)
+	g.pdf.SetXY(x+w, y)
+}
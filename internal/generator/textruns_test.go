@@ -0,0 +1,83 @@
+package generator
+
+import (
+	"badge-service/internal/fonts"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testFace writes a font file whose only table is a cmap covering the given
+// [first, last] rune ranges, and returns a face for it
+func testFace(t *testing.T, id string, ranges ...[2]rune) *fonts.Face {
+	// Format 12 subtable with one group per range
+	sub := make([]byte, 16+len(ranges)*12)
+	binary.BigEndian.PutUint16(sub[0:], 12)
+	binary.BigEndian.PutUint32(sub[4:], uint32(len(sub)))
+	binary.BigEndian.PutUint32(sub[12:], uint32(len(ranges)))
+	for i, r := range ranges {
+		binary.BigEndian.PutUint32(sub[16+i*12:], uint32(r[0]))
+		binary.BigEndian.PutUint32(sub[20+i*12:], uint32(r[1]))
+		binary.BigEndian.PutUint32(sub[24+i*12:], 1)
+	}
+
+	// cmap header with a single Windows Unicode full repertoire record
+	cmap := make([]byte, 12, 12+len(sub))
+	binary.BigEndian.PutUint16(cmap[2:], 1)
+	binary.BigEndian.PutUint16(cmap[4:], 3)
+	binary.BigEndian.PutUint16(cmap[6:], 10)
+	binary.BigEndian.PutUint32(cmap[8:], 12)
+	cmap = append(cmap, sub...)
+
+	// sfnt header and table directory
+	font := make([]byte, 28, 28+len(cmap))
+	binary.BigEndian.PutUint32(font[0:], 0x00010000)
+	binary.BigEndian.PutUint16(font[4:], 1)
+	copy(font[12:], "cmap")
+	binary.BigEndian.PutUint32(font[20:], 28)
+	binary.BigEndian.PutUint32(font[24:], uint32(len(cmap)))
+	font = append(font, cmap...)
+
+	path := filepath.Join(t.TempDir(), id+".ttf")
+	if err := os.WriteFile(path, font, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return &fonts.Face{ID: id, Path: path}
+}
+
+func TestSplitRuns(t *testing.T) {
+	g := &PDFGenerator{}
+	core := fontRef{family: "Helvetica"}
+	greek := fontRef{family: "greek", face: testFace(t, "greek", [2]rune{0x370, 0x3FF})}
+	chain := fontChain{fonts: []fontRef{core, greek}}
+
+	type run struct {
+		text   string
+		family string
+	}
+	tests := []struct {
+		name  string
+		chain fontChain
+		text  string
+		want  []run
+	}{
+		{"single font", fontChain{fonts: []fontRef{core}}, "Hi Ωmega", []run{{"Hi Ωmega", "Helvetica"}}},
+		{"fallback for a glyph", chain, "Hi Ωmega", []run{{"Hi ", "Helvetica"}, {"Ω", "greek"}, {"mega", "Helvetica"}}},
+		{"fallback keeps its own glyphs", chain, "ΑΒΓ", []run{{"ΑΒΓ", "greek"}}},
+		{"spaces stay in the run", chain, "Ω ok", []run{{"Ω ", "greek"}, {"ok", "Helvetica"}}},
+		{"combining mark stays", chain, "Cafe\u0301", []run{{"Cafe\u0301", "Helvetica"}}},
+		{"no font has it", chain, "a中b", []run{{"a中b", "Helvetica"}}},
+		{"empty", chain, "", nil},
+	}
+	for _, tt := range tests {
+		var got []run
+		for _, r := range g.splitRuns(tt.chain, tt.text) {
+			got = append(got, run{r.text, r.font.family})
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: splitRuns(%q) = %q, want %q", tt.name, tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package generator
+
+import (
+	"badge-service/internal/expr"
+	"badge-service/internal/models"
+)
+
+// LayerVisible reports whether a layer of a template is drawn for a user: it
+// must be visible and its visibleIf condition, if any, must hold for the
+// user's data. A condition that does not parse is logged once and ignored,
+// so a typo shows the layer instead of silently dropping it from every badge.
+func LayerVisible(layer models.Layer, template *models.Template, user *models.User) bool {
+	if !layer.Visible {
+		return false
+	}
+	if layer.VisibleIf == "" {
+		return true
+	}
+
+	visible, err := expr.Eval(layer.VisibleIf, fieldResolver(template, user))
+	if err != nil {
+		logOnce("Invalid visibleIf for layer %s: %v\n", layer.ID, err)
+		return true
+	}
+	return visible
+}
+
+// isVisible reports whether a layer is drawn on this badge
+func (g *PDFGenerator) isVisible(layer models.Layer) bool {
+	return LayerVisible(layer, g.template, g.user)
+}
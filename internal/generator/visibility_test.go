@@ -0,0 +1,63 @@
+package generator
+
+import (
+	"badge-service/internal/models"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestLayerVisible(t *testing.T) {
+	template := &models.Template{}
+	user := &models.User{Data: map[string]interface{}{"ticketCount": 2, "role": "speaker"}}
+	tests := []struct {
+		name      string
+		visible   bool
+		visibleIf string
+		want      bool
+	}{
+		{"hidden", false, "", false},
+		{"no condition", true, "", true},
+		{"condition holds", true, `role == "speaker"`, true},
+		{"condition fails", true, "ticketCount > 2", false},
+		{"hidden despite condition", false, "ticketCount > 1", false},
+		{"invalid condition", true, "ticketCount >", true},
+	}
+	for _, tt := range tests {
+		layer := models.Layer{ID: tt.name, Visible: tt.visible, VisibleIf: tt.visibleIf}
+		if got := LayerVisible(layer, template, user); got != tt.want {
+			t.Errorf("%s: LayerVisible = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLogOnce(t *testing.T) {
+	stderr := os.Stderr
+	os.Stderr, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	defer func() {
+		os.Stderr.Close()
+		os.Stderr = stderr
+	}()
+
+	loggedMu.Lock()
+	loggedWarnings = make(map[string]bool)
+	loggedMu.Unlock()
+
+	layer := models.Layer{ID: "vip", Visible: true, VisibleIf: "role =="}
+	for i := 0; i < 3; i++ {
+		LayerVisible(layer, &models.Template{}, &models.User{})
+	}
+	if n := len(loggedWarnings); n != 1 {
+		t.Errorf("%d warnings remembered after the same invalid condition, want 1", n)
+	}
+
+	for i := 0; i < 2*maxLoggedWarnings; i++ {
+		logOnce("warning %d\n", i)
+	}
+	if n := len(loggedWarnings); n > maxLoggedWarnings {
+		t.Errorf("%d warnings remembered, want at most %d", n, maxLoggedWarnings)
+	}
+	if !loggedWarnings[fmt.Sprintf("warning %d\n", 2*maxLoggedWarnings-1)] {
+		t.Error("latest warning not remembered")
+	}
+}
@@ -4,9 +4,10 @@ import (
 	"badge-service/internal/cache"
 	"badge-service/internal/generator"
 	"badge-service/internal/models"
+	"badge-service/internal/token"
 	"encoding/base64"
+	"errors"
 	"fmt"
-	"strings"
 	"sync"
 	"time"
 
@@ -41,7 +42,7 @@ func GenerateBadge(c *fiber.Ctx) error {
 	}
 	
 	// Validate request
-	if req.Template.ID == 0 && req.Template.Design.Layers == nil {
+	if req.Template.ID == 0 && req.Template.Design.Layers == nil && req.Template.Design.Pages == nil {
 		return c.Status(400).JSON(fiber.Map{
 			"error": "Template is required",
 		})
@@ -53,77 +54,18 @@ func GenerateBadge(c *fiber.Ctx) error {
 		})
 	}
 	
-	// Collect image requests with dimensions for direct loading
-	// Use map for O(1) deduplication instead of O(n²) nested loop
-	imageRequestMap := make(map[string]cache.ImageRequest)
-	
-	// Get DPI from template settings
-	dpi := req.Template.Design.Settings.DPI
-	if dpi == 0 {
-		dpi = 300 // Default DPI
-	}
-	
-	// Helper function to recursively collect image layers
-	var collectImageLayers func(layers []models.Layer)
-	collectImageLayers = func(layers []models.Layer) {
-		for _, layer := range layers {
-			if !layer.Visible {
-				continue
-			}
-			
-			var imageURL string
-			
-			// Check if this is an asset reference
-			if strings.HasPrefix(layer.Content, "asset_") {
-				// Try exact match first
-				if url, ok := req.Template.Assets[layer.Content]; ok {
-					imageURL = url
-				} else {
-					// Fallback: find asset URL with contains match
-					for key, url := range req.Template.Assets {
-						if strings.Contains(key, layer.Content) {
-							imageURL = url
-							break
-						}
-					}
-				}
-			} else if layer.DataBinding != "" {
-				// Get image URL from user data binding
-				fieldID := strings.TrimPrefix(layer.DataBinding, "customFields.")
-				imageURL = req.User.User.GetFieldValue(fieldID)
-			} else if layer.Content != "" && (strings.HasPrefix(layer.Content, "http://") || strings.HasPrefix(layer.Content, "https://")) {
-				imageURL = layer.Content
-			}
-			
-			// If we found an image URL and it's an image layer, add to requests
-			if imageURL != "" && layer.Type == "image" {
-				// Use composite key for O(1) deduplication
-				key := fmt.Sprintf("%s_%.2f_%.2f", imageURL, layer.Size.Width, layer.Size.Height)
-				if _, exists := imageRequestMap[key]; !exists {
-					imageRequestMap[key] = cache.ImageRequest{
-						URL:    imageURL,
-						Width:  layer.Size.Width,
-						Height: layer.Size.Height,
-						DPI:    dpi,
-					}
-				}
-			}
-			
-			// Recursively check container children
-			if layer.Type == "container" && len(layer.Children) > 0 {
-				collectImageLayers(layer.Children)
-			}
-		}
+	// Signed QR codes can't be drawn without a signing key; that's a
+	// configuration error, not a badge without its code
+	if generator.SignsQRCodes(&req.Template) && !token.CanSign() {
+		return c.Status(503).JSON(fiber.Map{
+			"error":   "Signed QR codes are not available",
+			"details": token.ErrNoKeys.Error(),
+		})
 	}
 	
-	// Collect all image layers recursively
-	collectImageLayers(req.Template.Design.Layers)
-	
-	// Convert map to slice for PreloadImagesDirect
-	imageRequests := make([]cache.ImageRequest, 0, len(imageRequestMap))
-	for _, req := range imageRequestMap {
-		imageRequests = append(imageRequests, req)
-	}
+	// Collect image requests sized as the layers are drawn, on every page of
+	// the design, so the generator finds them by key
+	imageRequests := generator.ImageRequests(&req.Template, []*models.User{&req.User.User})
 	
 	// Pre-fetch all images with dimensions (direct loading, in-memory processing)
 	var imageDataCache map[string][]byte
@@ -185,118 +127,40 @@ func GenerateBadgeBatch(c *fiber.Ctx) error {
 		})
 	}
 	
-	// Collect all image URLs to pre-fetch
-	var imageURLs []string
-	urlSet := make(map[string]bool) // Deduplicate URLs
-	
-	// Template assets
-	for _, url := range req.Template.Assets {
-		if url != "" && !urlSet[url] {
-			imageURLs = append(imageURLs, url)
-			urlSet[url] = true
-		}
-	}
-	
-	// User photos from customFieldValues
-	for _, userData := range req.Users {
-		for _, cf := range userData.User.CustomFieldValues {
-			if cf.FieldType == "file" && cf.Value != "" && (strings.HasPrefix(cf.Value, "http://") || strings.HasPrefix(cf.Value, "https://")) {
-				if !urlSet[cf.Value] {
-					imageURLs = append(imageURLs, cf.Value)
-					urlSet[cf.Value] = true
-				}
-			}
-		}
-	}
-	
-	// Also check dataBinding fields in template layers to ensure all images are preloaded
-	for _, layer := range req.Template.Design.Layers {
-		if layer.Type == "image" && layer.DataBinding != "" {
-			fieldID := strings.TrimPrefix(layer.DataBinding, "customFields.")
-			for _, userData := range req.Users {
-				imageURL := userData.User.GetFieldValue(fieldID)
-				if imageURL != "" && (strings.HasPrefix(imageURL, "http://") || strings.HasPrefix(imageURL, "https://")) {
-					if !urlSet[imageURL] {
-						imageURLs = append(imageURLs, imageURL)
-						urlSet[imageURL] = true
-					}
-				}
-			}
-		}
-	}
-	
-	// Collect image requests with dimensions for all users
-	// Use map for O(1) deduplication instead of O(n²) nested loop
-	imageRequestMap := make(map[string]cache.ImageRequest)
-	
-	// Get DPI from template settings
-	dpi := req.Template.Design.Settings.DPI
-	if dpi == 0 {
-		dpi = 300 // Default DPI
+	// Signed QR codes can't be drawn without a signing key; that's a
+	// configuration error, not a badge without its code
+	if generator.SignsQRCodes(&req.Template) && !token.CanSign() {
+		return c.Status(503).JSON(fiber.Map{
+			"error":   "Signed QR codes are not available",
+			"details": token.ErrNoKeys.Error(),
+		})
 	}
 	
-	// Helper function to recursively collect image layers
-	var collectImageLayers func(layers []models.Layer, user *models.User)
-	collectImageLayers = func(layers []models.Layer, user *models.User) {
-		for _, layer := range layers {
-			if !layer.Visible {
-				continue
-			}
-			
-			var imageURL string
-			
-			// Check if this is an asset reference
-			if strings.HasPrefix(layer.Content, "asset_") {
-				if url, ok := req.Template.Assets[layer.Content]; ok {
-					imageURL = url
-				} else {
-					for key, url := range req.Template.Assets {
-						if strings.Contains(key, layer.Content) {
-							imageURL = url
-							break
-						}
-					}
-				}
-			} else if layer.DataBinding != "" {
-				fieldID := strings.TrimPrefix(layer.DataBinding, "customFields.")
-				imageURL = user.GetFieldValue(fieldID)
-			} else if layer.Content != "" && (strings.HasPrefix(layer.Content, "http://") || strings.HasPrefix(layer.Content, "https://")) {
-				imageURL = layer.Content
-			}
-			
-			if imageURL != "" && layer.Type == "image" {
-				// Use composite key for O(1) deduplication
-				key := fmt.Sprintf("%s_%.2f_%.2f", imageURL, layer.Size.Width, layer.Size.Height)
-				if _, exists := imageRequestMap[key]; !exists {
-					imageRequestMap[key] = cache.ImageRequest{
-						URL:    imageURL,
-						Width:  layer.Size.Width,
-						Height: layer.Size.Height,
-						DPI:    dpi,
-					}
-				}
-			}
-			
-			if layer.Type == "container" && len(layer.Children) > 0 {
-				collectImageLayers(layer.Children, user)
-			}
+	if req.Imposition != nil {
+		if err := generator.CheckImposition(&req.Template, *req.Imposition); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Invalid imposition",
+				"details": err.Error(),
+			})
 		}
 	}
 	
-	// Collect image layers for first user (template structure is same for all)
-	if len(req.Users) > 0 {
-		collectImageLayers(req.Template.Design.Layers, &req.Users[0].User)
-	}
-	
-	// Convert map to slice for PreloadImagesDirect
-	imageRequests := make([]cache.ImageRequest, 0, len(imageRequestMap))
-	for _, req := range imageRequestMap {
-		imageRequests = append(imageRequests, req)
+	// Collect image requests for every user: visibleIf conditions can show an
+	// image to some users only. Shared images deduplicate by key.
+	users := make([]*models.User, len(req.Users))
+	for i := range req.Users {
+		users[i] = &req.Users[i].User
 	}
+	imageRequests := generator.ImageRequests(&req.Template, users)
 	
 	// Pre-fetch all images with dimensions (direct loading)
 	imageDataCache := cache.PreloadImagesDirect(imageRequests)
 	
+	// Imposition: every badge N-up on print sheets in a single PDF
+	if req.Imposition != nil {
+		return imposeBatch(c, &req, imageDataCache)
+	}
+	
 	// Generate PDFs concurrently
 	results := make([]models.BadgeResult, len(req.Users))
 	var wg sync.WaitGroup
@@ -348,6 +212,80 @@ func GenerateBadgeBatch(c *fiber.Ctx) error {
 	})
 }
 
+// imposeBatch renders all badges of a batch onto print sheets and returns the
+// PDF, as binary or as base64 JSON like GenerateBadge
+func imposeBatch(c *fiber.Ctx, req *models.BatchGenerateRequest, imageDataCache map[string][]byte) error {
+	users := make([]*models.User, len(req.Users))
+	for i := range req.Users {
+		users[i] = &req.Users[i].User
+	}
+	
+	pdfBytes, err := generator.Impose(&req.Template, users, *req.Imposition, imageDataCache)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "Failed to generate PDF",
+			"details": err.Error(),
+		})
+	}
+	
+	if c.Get("Accept") == "application/json" {
+		return c.JSON(fiber.Map{
+			"success":    true,
+			"total":      len(users),
+			"pdf_base64": base64.StdEncoding.EncodeToString(pdfBytes),
+			"filename":   "badges_sheet.pdf",
+		})
+	}
+	
+	c.Set("Content-Type", "application/pdf")
+	c.Set("Content-Disposition", "inline; filename=badges_sheet.pdf")
+	return c.Send(pdfBytes)
+}
+
+// VerifyBadge checks a signed badge token scanned from a QR code and returns
+// its claims. The token comes from a JSON body ({"token": "..."}) or the
+// token query parameter.
+func VerifyBadge(c *fiber.Ctx) error {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+		}
+	}
+	if req.Token == "" {
+		req.Token = c.Query("token")
+	}
+	if req.Token == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Token is required",
+		})
+	}
+	
+	claims, err := token.Verify(req.Token)
+	switch {
+	case errors.Is(err, token.ErrNoKeys):
+		return c.Status(503).JSON(fiber.Map{
+			"valid": false,
+			"error": err.Error(),
+		})
+	case err != nil:
+		return c.Status(401).JSON(fiber.Map{
+			"valid": false,
+			"error": err.Error(),
+		})
+	}
+	
+	return c.JSON(fiber.Map{
+		"valid":  true,
+		"claims": claims,
+	})
+}
+
 // PreloadTemplate pre-caches template assets
 func PreloadTemplate(c *fiber.Ctx) error {
 	var req struct {
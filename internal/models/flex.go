@@ -0,0 +1,50 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+)
+
+// BasisContent sizes a flex item by its content, like CSS flex-basis: content
+const BasisContent = "content"
+
+// FlexBasis is the initial main size of a flex item: a number (layer units),
+// a percentage of the container, "auto" (the layer's own size, grown to fit
+// its content) or "content"
+type FlexBasis struct {
+	Kind  string // TrackFixed, TrackPercent, TrackAuto or BasisContent; empty means auto
+	Value float64
+}
+
+// UnmarshalJSON accepts a number or a CSS-like string; unknown values mean auto
+func (b *FlexBasis) UnmarshalJSON(data []byte) error {
+	*b = FlexBasis{}
+
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	switch v := raw.(type) {
+	case float64:
+		*b = FlexBasis{Kind: TrackFixed, Value: v}
+	case string:
+		if strings.EqualFold(strings.TrimSpace(v), BasisContent) {
+			b.Kind = BasisContent
+			return nil
+		}
+		if track, ok := trackSize(v); ok && track.Kind != TrackFr {
+			*b = FlexBasis{Kind: track.Kind, Value: track.Value}
+		}
+	}
+	return nil
+}
+
+// Shrink returns the flex shrink factor, 1 by default
+func (l Layer) Shrink() float64 {
+	if l.FlexShrink == nil {
+		return 1
+	}
+	return math.Max(0, *l.FlexShrink)
+}
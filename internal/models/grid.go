@@ -0,0 +1,171 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Grid track sizing kinds
+const (
+	TrackFixed   = "fixed"   // Value in layer units (mm)
+	TrackPercent = "percent" // Value as a percentage of the container
+	TrackFr      = "fr"      // Value is a share of the remaining space
+	TrackAuto    = "auto"    // sized by the largest child in the track
+)
+
+// MaxGridTracks is the most rows or columns a grid template defines; longer
+// lists are cut off
+const MaxGridTracks = 100
+
+// GridTrack is one row or column of a grid container
+type GridTrack struct {
+	Kind  string
+	Value float64
+}
+
+// GridTracks is a list of grid tracks. The designer sends either a track count
+// (3 means three equal 1fr tracks), a CSS-like template string
+// ("40 1fr 2fr", "repeat(3, 1fr)", "auto 25%") or an array of sizes.
+type GridTracks []GridTrack
+
+// UnmarshalJSON accepts a count, a template string or an array of numbers/strings.
+// Tracks that don't parse are logged and sized auto, so one bad value doesn't
+// fail the whole request.
+func (t *GridTracks) UnmarshalJSON(data []byte) error {
+	*t = nil
+
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	switch v := raw.(type) {
+	case float64:
+		*t = equalTracks(int(math.Min(v, MaxGridTracks)))
+	case string:
+		*t = parseTrackList(v)
+	case []interface{}:
+		for _, item := range v {
+			switch size := item.(type) {
+			case float64:
+				*t = append(*t, GridTrack{Kind: TrackFixed, Value: size})
+			case string:
+				*t = append(*t, parseTrack(size))
+			}
+		}
+	}
+	if len(*t) > MaxGridTracks {
+		*t = (*t)[:MaxGridTracks]
+	}
+	return nil
+}
+
+// equalTracks returns n 1fr tracks, at most MaxGridTracks
+func equalTracks(n int) GridTracks {
+	var t GridTracks
+	for i := 0; i < n && i < MaxGridTracks; i++ {
+		t = append(t, GridTrack{Kind: TrackFr, Value: 1})
+	}
+	return t
+}
+
+// parseTrackList parses a grid-template string. A bare number is a track
+// count, as the designer sends "3" as well as 3.
+func parseTrackList(value string) GridTracks {
+	value = strings.TrimSpace(value)
+	if n, err := strconv.Atoi(value); err == nil {
+		return equalTracks(n)
+	}
+
+	var tracks GridTracks
+	for _, token := range splitTrackTokens(value) {
+		if strings.HasPrefix(strings.ToLower(token), "repeat(") {
+			inner := strings.TrimSuffix(token[len("repeat("):], ")")
+			parts := strings.SplitN(inner, ",", 2)
+			if len(parts) != 2 {
+				tracks = append(tracks, autoTrack(token))
+				continue
+			}
+			count, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+			if err != nil {
+				tracks = append(tracks, autoTrack(token))
+				continue
+			}
+			repeated := parseTrackList(parts[1])
+			for i := 0; i < count && len(tracks) < MaxGridTracks; i++ {
+				tracks = append(tracks, repeated...)
+			}
+			continue
+		}
+
+		tracks = append(tracks, parseTrack(token))
+	}
+	return tracks
+}
+
+// splitTrackTokens splits on whitespace outside parentheses
+func splitTrackTokens(value string) []string {
+	var tokens []string
+	var current strings.Builder
+	depth := 0
+	for _, r := range value {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case (r == ' ' || r == '\t') && depth == 0:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// parseTrack parses one track size. Anything else is logged and sized auto.
+func parseTrack(token string) GridTrack {
+	track, ok := trackSize(token)
+	if !ok {
+		return autoTrack(token)
+	}
+	return track
+}
+
+// trackSize parses a track size: "1fr", "auto", "25%", "40" or "40mm"
+func trackSize(token string) (GridTrack, bool) {
+	token = strings.ToLower(strings.TrimSpace(token))
+
+	kind, number := TrackFixed, strings.TrimSuffix(token, "mm")
+	switch {
+	case token == "auto" || token == "min-content" || token == "max-content":
+		return GridTrack{Kind: TrackAuto}, true
+	case strings.HasSuffix(token, "fr"):
+		kind, number = TrackFr, strings.TrimSuffix(token, "fr")
+	case strings.HasSuffix(token, "%"):
+		kind, number = TrackPercent, strings.TrimSuffix(token, "%")
+	}
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return GridTrack{}, false
+	}
+	return GridTrack{Kind: kind, Value: v}, true
+}
+
+// autoTrack logs a track size that doesn't parse and returns an auto track
+// in its place
+func autoTrack(token string) GridTrack {
+	fmt.Fprintf(os.Stderr, "Invalid grid track %q: sized auto\n", strings.TrimSpace(token))
+	return GridTrack{Kind: TrackAuto}
+}
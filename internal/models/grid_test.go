@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGridTracksUnmarshal(t *testing.T) {
+	fr := GridTrack{Kind: TrackFr, Value: 1}
+	auto := GridTrack{Kind: TrackAuto}
+	tests := []struct {
+		json string
+		want GridTracks
+	}{
+		{`3`, GridTracks{fr, fr, fr}},
+		{`"2"`, GridTracks{fr, fr}},
+		{`"40 1fr 2fr"`, GridTracks{{TrackFixed, 40}, fr, {TrackFr, 2}}},
+		{`"repeat(2, 1fr) auto 25%"`, GridTracks{fr, fr, auto, {TrackPercent, 25}}},
+		{`"12.5mm max-content"`, GridTracks{{TrackFixed, 12.5}, auto}},
+		{`[30, "1fr", "auto"]`, GridTracks{{TrackFixed, 30}, fr, auto}},
+		{`"1fr bogus -5"`, GridTracks{fr, auto, auto}},
+		{`"repeat(x, 1fr) 1fr"`, GridTracks{auto, fr}},
+		{`["1fr", "%"]`, GridTracks{fr, auto}},
+		{`0`, nil},
+		{`null`, nil},
+	}
+	for _, tt := range tests {
+		var got GridTracks
+		if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+			t.Errorf("%s: %v", tt.json, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.json, got, tt.want)
+		}
+	}
+}
+
+func TestGridTracksLimit(t *testing.T) {
+	for _, src := range []string{`1e9`, `"100000"`, `"repeat(100000, 1fr 2fr)"`, `"repeat(1000, repeat(1000, 1fr))"`} {
+		var got GridTracks
+		if err := json.Unmarshal([]byte(src), &got); err != nil {
+			t.Errorf("%s: %v", src, err)
+			continue
+		}
+		if len(got) != MaxGridTracks {
+			t.Errorf("%s: %d tracks, want %d", src, len(got), MaxGridTracks)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package models
+
+// Imposition lays out many badges on one print sheet (N-up), for batches
+// printed on office printers or label sheets
+type Imposition struct {
+	Preset      string   `json:"preset,omitempty"`     // label sheet preset, e.g. "avery-5395"; sets the sheet, grid, gutters and margins
+	Sheet       string   `json:"sheet,omitempty"`      // A3, A4, A5, Letter, Legal or Tabloid (default A4)
+	SheetWidth  float64  `json:"sheetWidth,omitempty"` // custom sheet width in mm, with sheetHeight; overrides sheet
+	SheetHeight float64  `json:"sheetHeight,omitempty"`
+	Orientation string   `json:"orientation,omitempty"` // portrait or landscape
+	Columns     int      `json:"columns,omitempty"`     // badges across; as many as fit when 0
+	Rows        int      `json:"rows,omitempty"`        // badges down; as many as fit when 0
+	Gutter      float64  `json:"gutter,omitempty"`      // space between badges in mm
+	GutterX     float64  `json:"gutterX,omitempty"`     // horizontal space, overrides gutter
+	GutterY     float64  `json:"gutterY,omitempty"`     // vertical space, overrides gutter
+	Margins     *Margins `json:"margins,omitempty"`     // sheet margins; 10 mm on each side when missing
+	CropMarks   bool     `json:"cropMarks,omitempty"`   // cut marks in the margins at every badge edge
+}
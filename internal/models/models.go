@@ -1,6 +1,11 @@
 package models
 
-import "strings"
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
 
 // ============ TEMPLATE STRUCTURES ============
 
@@ -18,23 +23,182 @@ type Template struct {
 
 type TemplateDesign struct {
 	Layers   []Layer  `json:"layers"`
+	Pages    []Page   `json:"pages,omitempty"` // multi-page and double-sided badges; replaces layers when set
 	Settings Settings `json:"settings"`
 }
 
+// Page is one page or side of a badge, with its own layers
+type Page struct {
+	ID     string  `json:"id,omitempty"`
+	Name   string  `json:"name,omitempty"`
+	Side   string  `json:"side,omitempty"` // front or back; alternates front, back, front... when empty
+	Layers []Layer `json:"layers"`
+}
+
+// PageList returns the pages of the design in print order. A design without
+// pages is a single page of its layers.
+func (d TemplateDesign) PageList() []Page {
+	if len(d.Pages) > 0 {
+		return d.Pages
+	}
+	return []Page{{Side: "front", Layers: d.Layers}}
+}
+
+// IsBack reports whether the page at index in the page list is a back side
+func (p Page) IsBack(index int) bool {
+	switch strings.ToLower(p.Side) {
+	case "back":
+		return true
+	case "front":
+		return false
+	}
+	return index%2 == 1
+}
+
 type Layer struct {
-	ID              string          `json:"id"`
-	Type            string          `json:"type"` // image, text, qrcode, container
-	Position        Position        `json:"position"`
-	Size            Size            `json:"size"`
-	Style           Style           `json:"style"`
-	Content         string          `json:"content"`
-	DataBinding     string          `json:"dataBinding,omitempty"`
-	Children        []Layer         `json:"children,omitempty"`
-	ZIndex          int             `json:"zIndex"`
-	Visible         bool            `json:"visible"`
-	ParentID        string          `json:"parentId,omitempty"`
+	ID              string           `json:"id"`
+	Type            string           `json:"type"` // image, text, qrcode, barcode, container, shape
+	Position        Position         `json:"position"`
+	Size            Size             `json:"size"`
+	Style           Style            `json:"style"`
+	Content         string           `json:"content"`
+	DataBinding     string           `json:"dataBinding,omitempty"`
+	Children        []Layer          `json:"children,omitempty"`
+	ZIndex          int              `json:"zIndex"`
+	Visible         bool             `json:"visible"`
+	VisibleIf       string           `json:"visibleIf,omitempty"` // condition on the user's data, e.g. badgeCategory == "VIP"
+	ParentID        string           `json:"parentId,omitempty"`
 	ContainerLayout *ContainerLayout `json:"containerLayout,omitempty"`
-	AutoFontSize    bool            `json:"autoFontSize,omitempty"`
+	AutoFontSize    bool             `json:"autoFontSize,omitempty"`
+	Shape           *Shape           `json:"shape,omitempty"`
+	Barcode         *Barcode         `json:"barcode,omitempty"`
+	QRCode          *QRCode          `json:"qrcode,omitempty"`
+	FocalPoint      *FocalPoint      `json:"focalPoint,omitempty"`
+	AspectRatio     AspectRatio      `json:"aspectRatio,omitempty"`
+	GridColumn      int              `json:"gridColumn,omitempty"` // 1-based grid column; auto-placed when 0
+	GridRow         int              `json:"gridRow,omitempty"`    // 1-based grid row; auto-placed when 0
+	GridColumnSpan  int              `json:"gridColumnSpan,omitempty"`
+	GridRowSpan     int              `json:"gridRowSpan,omitempty"`
+	JustifySelf     string           `json:"justifySelf,omitempty"` // overrides the grid's justifyItems
+	AlignSelf       string           `json:"alignSelf,omitempty"`   // overrides the container's alignItems
+	FlexGrow        float64          `json:"flexGrow,omitempty"`
+	FlexShrink      *float64         `json:"flexShrink,omitempty"` // 1 when missing, as in CSS
+	FlexBasis       FlexBasis        `json:"flexBasis,omitempty"`
+}
+
+// FocalPoint is the point of an image (0-1 from the left/top) that stays in
+// view when the image is cropped or letterboxed, like CSS object-position
+type FocalPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// AspectRatio is the designer's aspect ratio lock. It arrives as a boolean
+// or "locked", or as a ratio: a number (width / height) or a "16:9" style
+// string. The designer sends the ratio of every image box, so a ratio alone
+// records the shape of the box and doesn't lock it.
+type AspectRatio struct {
+	Locked bool
+	Value  float64 // 0 when the ratio itself isn't known
+}
+
+// UnmarshalJSON accepts every form the designer sends; unknown values and bare
+// ratios mean unlocked
+func (a *AspectRatio) UnmarshalJSON(data []byte) error {
+	*a = AspectRatio{}
+
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	switch v := raw.(type) {
+	case bool:
+		a.Locked = v
+	case float64:
+		a.Value = math.Max(0, v)
+	case string:
+		v = strings.TrimSpace(strings.ToLower(v))
+		if parts := strings.SplitN(v, ":", 2); len(parts) == 2 {
+			w, errW := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+			h, errH := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+			if errW == nil && errH == nil && w > 0 && h > 0 {
+				a.Value = w / h
+			}
+		} else if n, err := strconv.ParseFloat(v, 64); err == nil {
+			a.Value = math.Max(0, n)
+		} else {
+			a.Locked = v == "locked" || v == "preserve" || v == "true"
+		}
+	}
+	return nil
+}
+
+// ObjectFit returns how an image layer's picture fills its box: fill, contain,
+// cover or none. Without style.objectFit, an explicitly locked aspect ratio
+// means contain and anything else keeps the old stretch-to-fill behavior.
+func (l Layer) ObjectFit() string {
+	fit := strings.ToLower(strings.TrimSpace(l.Style.ObjectFit))
+	switch fit {
+	case "fill", "contain", "cover", "none":
+		return fit
+	case "scale-down":
+		return "contain"
+	}
+	if l.AspectRatio.Locked {
+		return "contain"
+	}
+	return "fill"
+}
+
+// Focus returns the focal point clamped to 0-1, the center by default
+func (l Layer) Focus() (float64, float64) {
+	if l.FocalPoint == nil {
+		return 0.5, 0.5
+	}
+	return clampUnit(l.FocalPoint.X), clampUnit(l.FocalPoint.Y)
+}
+
+func clampUnit(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// Shape is the geometry of a shape layer. Without it a shape layer is a
+// rectangle (rounded when style.borderRadius is set).
+type Shape struct {
+	Type   string     `json:"type"`             // rect, roundedRect, ellipse, circle, line, polygon
+	Points []Position `json:"points,omitempty"` // polygon vertices or line end points, relative to the layer position
+	Sides  int        `json:"sides,omitempty"`  // regular polygon inscribed in the layer box when no points are given
+}
+
+// Barcode configures a barcode layer. Bars are drawn in style.color on the
+// layer's backgroundColor; the human-readable text uses the layer font.
+type Barcode struct {
+	Symbology       string   `json:"symbology"`                 // code128, ean13, ean8, pdf417, datamatrix, aztec
+	ShowText        *bool    `json:"showText,omitempty"`        // human-readable text; on by default for linear barcodes
+	Text            string   `json:"text,omitempty"`            // text to show instead of the encoded content, placeholders allowed
+	TextPosition    string   `json:"textPosition,omitempty"`    // bottom (default) or top
+	QuietZone       *float64 `json:"quietZone,omitempty"`       // blank margin in modules; the symbology's minimum when missing
+	ErrorCorrection int      `json:"errorCorrection,omitempty"` // PDF417 security level (1-8) or Aztec error correction percentage
+}
+
+// QRCode configures a QR code layer. Modules are drawn in style.color on the
+// layer's backgroundColor (white when not set).
+type QRCode struct {
+	ErrorCorrection string            `json:"errorCorrection,omitempty"` // L, M (default), Q or H
+	QuietZone       *float64          `json:"quietZone,omitempty"`       // blank margin in modules, 4 when missing
+	Logo            string            `json:"logo,omitempty"`            // key of a template asset drawn in the center; raises error correction to H
+	LogoSize        float64           `json:"logoSize,omitempty"`        // logo width as a fraction of the QR code, 0.2 by default, at most 0.3
+	Signed          bool              `json:"signed,omitempty"`          // encode a signed badge token instead of the plain content
+	Mode            string            `json:"mode,omitempty"`            // text (default), vcard or mecard
+	VCardVersion    string            `json:"vcardVersion,omitempty"`    // 3.0 (default) or 4.0
+	ContactFields   map[string]string `json:"contactFields,omitempty"`   // contact property -> placeholder template, overriding the default user fields
 }
 
 type Position struct {
@@ -48,43 +212,90 @@ type Size struct {
 }
 
 type Style struct {
-	FontSize        float64 `json:"fontSize"`
-	FontFamily      string  `json:"fontFamily"`
-	FontWeight      string  `json:"fontWeight"`
-	Color           string  `json:"color"`
-	TextAlign       string  `json:"textAlign"`
-	Opacity         float64 `json:"opacity"`
-	BackgroundColor string  `json:"backgroundColor,omitempty"`
-	Rotation        float64 `json:"rotation,omitempty"`
+	FontSize             float64   `json:"fontSize"`
+	FontFamily           string    `json:"fontFamily"`
+	FontWeight           string    `json:"fontWeight"`
+	FontStyle            string    `json:"fontStyle,omitempty"`      // normal, italic, oblique
+	TextDecoration       string    `json:"textDecoration,omitempty"` // none, underline, line-through, overline (space separated)
+	Color                string    `json:"color"`
+	TextAlign            string    `json:"textAlign"`
+	Opacity              *float64  `json:"opacity,omitempty"`
+	BackgroundColor      string    `json:"backgroundColor,omitempty"`
+	BackgroundColorAlpha *float64  `json:"backgroundColorAlpha,omitempty"`
+	Rotation             float64   `json:"rotation,omitempty"`
+	BorderColor          string    `json:"borderColor,omitempty"`
+	BorderWidth          float64   `json:"borderWidth,omitempty"`  // same unit as position/size
+	BorderStyle          string    `json:"borderStyle,omitempty"`  // solid, dashed, dotted
+	BorderDash           []float64 `json:"borderDash,omitempty"`   // explicit dash/gap lengths, overrides borderStyle
+	BorderRadius         float64   `json:"borderRadius,omitempty"` // same unit as position/size
+	ObjectFit            string    `json:"objectFit,omitempty"`    // image layers: fill, contain, cover, none
+	Mask                 string    `json:"mask,omitempty"`         // image layers: circle, ellipse, roundedRect
+}
+
+// LayerOpacity returns the layer opacity clamped to 0-1. A missing value means fully opaque.
+func (s Style) LayerOpacity() float64 {
+	return clampAlpha(s.Opacity)
+}
+
+// BackgroundAlpha returns backgroundColorAlpha clamped to 0-1. A missing value means fully opaque.
+func (s Style) BackgroundAlpha() float64 {
+	return clampAlpha(s.BackgroundColorAlpha)
+}
+
+func clampAlpha(v *float64) float64 {
+	if v == nil {
+		return 1
+	}
+	return clampUnit(*v)
 }
 
 type Settings struct {
-	PaperWidth      float64 `json:"paperWidth"`
-	PaperHeight     float64 `json:"paperHeight"`
-	DPI             int     `json:"dpi"`
-	Orientation     string  `json:"orientation"`
-	DefaultLanguage string  `json:"defaultLanguage"`
-	RTLSupport      bool    `json:"rtlSupport"`
+	PaperWidth        float64 `json:"paperWidth"`
+	PaperHeight       float64 `json:"paperHeight"`
+	DPI               int     `json:"dpi"`
+	Orientation       string  `json:"orientation"`
+	DefaultLanguage   string  `json:"defaultLanguage"`
+	RTLSupport        bool    `json:"rtlSupport"`
+	FlipBack          bool    `json:"flipBack,omitempty"`          // rotate back sides 180° for short-edge duplex and fold-over badges
+	Margins           Margins `json:"margins"`                     // safe area inside the trim edge, mm
+	Bleed             float64 `json:"bleed,omitempty"`             // mm printed beyond the trim edge on every side
+	CropMarks         bool    `json:"cropMarks,omitempty"`         // crop marks at the trim corners, outside the bleed
+	RegistrationMarks bool    `json:"registrationMarks,omitempty"` // registration targets centered on each side
+	JPEGQuality       int     `json:"jpegQuality,omitempty"`       // 1-100 for resized photos, 90 when unset
+}
+
+// Margins are distances from the edges of a page, in mm
+type Margins struct {
+	Top    float64 `json:"top"`
+	Right  float64 `json:"right"`
+	Bottom float64 `json:"bottom"`
+	Left   float64 `json:"left"`
 }
 
 type ContainerLayout struct {
-	Type           string `json:"type"`
-	FlexDirection  string `json:"flexDirection"`
-	JustifyContent string `json:"justifyContent"`
-	AlignItems     string `json:"alignItems"`
-	FlexGap        int    `json:"flexGap"`
-	FlexWrap       string `json:"flexWrap"`
+	Type           string     `json:"type"` // flex, grid
+	FlexDirection  string     `json:"flexDirection"`
+	JustifyContent string     `json:"justifyContent"`
+	AlignItems     string     `json:"alignItems"`
+	FlexGap        int        `json:"flexGap"`
+	FlexWrap       string     `json:"flexWrap"`
+	GridRows       GridTracks `json:"gridRows,omitempty"`
+	GridColumns    GridTracks `json:"gridColumns,omitempty"`
+	GridGap        float64    `json:"gridGap,omitempty"`
+	AlignContent   string     `json:"alignContent,omitempty"`
+	JustifyItems   string     `json:"justifyItems,omitempty"`
 }
 
 // ============ USER STRUCTURES ============
 
 type User struct {
-	ID                string             `json:"id"`
-	FirstName         string             `json:"firstName"`
-	LastName          string             `json:"lastName"`
-	Email             string             `json:"email"`
-	Identifier        string             `json:"identifier"`
-	CustomFieldValues []CustomFieldValue `json:"customFieldValues"`
+	ID                string                 `json:"id"`
+	FirstName         string                 `json:"firstName"`
+	LastName          string                 `json:"lastName"`
+	Email             string                 `json:"email"`
+	Identifier        string                 `json:"identifier"`
+	CustomFieldValues []CustomFieldValue     `json:"customFieldValues"`
+	Data              map[string]interface{} `json:"-"` // the whole user object as received (see Path)
 }
 
 type CustomFieldValue struct {
@@ -114,6 +325,46 @@ func (u *User) GetFieldByName(name string) string {
 	return ""
 }
 
+// Lookup resolves a field reference from a template: a user property
+// (firstName, lastName, email, identifier, id), customFields.<fieldId>, a
+// custom field ID, a path into the user's JSON (avatarUrl,
+// badgeCategory.name, addons[0].name), or a custom field name or label
+func (u *User) Lookup(ref string) string {
+	ref = strings.TrimSpace(ref)
+	switch strings.ToLower(ref) {
+	case "id":
+		return u.ID
+	case "firstname":
+		return u.FirstName
+	case "lastname":
+		return u.LastName
+	case "email":
+		return u.Email
+	case "identifier":
+		return u.Identifier
+	}
+
+	if fieldID, ok := strings.CutPrefix(ref, "customFields."); ok {
+		return u.GetFieldValue(fieldID)
+	}
+	for _, cf := range u.CustomFieldValues {
+		if cf.FieldID == ref {
+			return cf.Value
+		}
+	}
+
+	// A null or empty property (e.g. "phone": null) doesn't hide a custom
+	// field with the same name
+	value, _ := u.Path(ref)
+	if value != "" {
+		return value
+	}
+	if byName := u.GetFieldByName(ref); byName != "" {
+		return byName
+	}
+	return value
+}
+
 // ============ REQUEST/RESPONSE STRUCTURES ============
 
 type GenerateBadgeRequest struct {
@@ -126,8 +377,9 @@ type UserData struct {
 }
 
 type BatchGenerateRequest struct {
-	Template Template   `json:"template"`
-	Users    []UserData `json:"users"`
+	Template   Template    `json:"template"`
+	Users      []UserData  `json:"users"`
+	Imposition *Imposition `json:"imposition,omitempty"` // return one N-up PDF instead of a PDF per user
 }
 
 type BatchGenerateResponse struct {
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLayerObjectFit(t *testing.T) {
+	tests := []struct {
+		json string
+		fit  string
+	}{
+		{`{}`, "fill"},
+		{`{"aspectRatio": 0.659}`, "fill"},
+		{`{"aspectRatio": "4:3"}`, "fill"},
+		{`{"aspectRatio": "1.5"}`, "fill"},
+		{`{"aspectRatio": true}`, "contain"},
+		{`{"aspectRatio": "locked"}`, "contain"},
+		{`{"aspectRatio": false}`, "fill"},
+		{`{"aspectRatio": true, "style": {"objectFit": "cover"}}`, "cover"},
+		{`{"style": {"objectFit": "scale-down"}}`, "contain"},
+		{`{"style": {"objectFit": "bogus"}}`, "fill"},
+	}
+	for _, tt := range tests {
+		var layer Layer
+		if err := json.Unmarshal([]byte(tt.json), &layer); err != nil {
+			t.Fatalf("%s: %v", tt.json, err)
+		}
+		if got := layer.ObjectFit(); got != tt.fit {
+			t.Errorf("%s: ObjectFit() = %q, want %q", tt.json, got, tt.fit)
+		}
+	}
+}
+
+func TestAspectRatioValue(t *testing.T) {
+	tests := []struct {
+		json  string
+		value float64
+	}{
+		{`0.5`, 0.5},
+		{`"16:9"`, 16.0 / 9},
+		{`"2"`, 2},
+		{`-1`, 0},
+		{`"wide"`, 0},
+		{`true`, 0},
+	}
+	for _, tt := range tests {
+		var a AspectRatio
+		if err := json.Unmarshal([]byte(tt.json), &a); err != nil {
+			t.Fatalf("%s: %v", tt.json, err)
+		}
+		if a.Value != tt.value {
+			t.Errorf("%s: Value = %v, want %v", tt.json, a.Value, tt.value)
+		}
+	}
+}
+
+func TestPageList(t *testing.T) {
+	layers := []Layer{{ID: "name"}}
+	single := TemplateDesign{Layers: layers}
+	pages := single.PageList()
+	if len(pages) != 1 || pages[0].Side != "front" || len(pages[0].Layers) != 1 || pages[0].Layers[0].ID != "name" {
+		t.Errorf("design without pages: %+v", pages)
+	}
+
+	multi := TemplateDesign{Layers: layers, Pages: []Page{{ID: "front"}, {ID: "back"}}}
+	pages = multi.PageList()
+	if len(pages) != 2 || pages[0].ID != "front" || pages[1].ID != "back" {
+		t.Errorf("design with pages: %+v", pages)
+	}
+}
+
+func TestPageIsBack(t *testing.T) {
+	tests := []struct {
+		side  string
+		index int
+		want  bool
+	}{
+		{"", 0, false},
+		{"", 1, true},
+		{"", 2, false},
+		{"back", 0, true},
+		{"Back", 2, true},
+		{"front", 1, false},
+		{"FRONT", 3, false},
+		{"inside", 1, true},
+	}
+	for _, tt := range tests {
+		if got := (Page{Side: tt.side}).IsBack(tt.index); got != tt.want {
+			t.Errorf("Page{Side: %q}.IsBack(%d) = %v, want %v", tt.side, tt.index, got, tt.want)
+		}
+	}
+}
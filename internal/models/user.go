@@ -0,0 +1,134 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// UnmarshalJSON decodes the typed user fields and keeps the whole object in
+// Data, so bindings can reach properties the struct doesn't declare
+// (avatarUrl, badgeCategory.display_name, addons[0].name, ...)
+func (u *User) UnmarshalJSON(data []byte) error {
+	type plain User
+	var typed plain
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return err
+	}
+
+	// Numbers stay json.Number so identifiers and prices print as sent
+	var raw map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	*u = User(typed)
+	u.Data = raw
+	return nil
+}
+
+// Path looks up a dotted path in the user's JSON, e.g. "badgeCategory.name",
+// "addons[0].name" or "addons.0.name". A name applied to an array collects it
+// from every element, so "addons.name" lists all addon names. It reports
+// whether the path exists; values are formatted as text, with arrays joined
+// by ", ".
+func (u *User) Path(path string) (string, bool) {
+	if u.Data == nil || path == "" {
+		return "", false
+	}
+
+	var current interface{} = u.Data
+	for _, key := range splitPath(path) {
+		next, ok := step(current, key)
+		if !ok {
+			return "", false
+		}
+		current = next
+	}
+	return formatJSONValue(current), true
+}
+
+// splitPath splits "addons[0].name" into "addons", "0", "name"
+func splitPath(path string) []string {
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	var keys []string
+	for _, key := range strings.Split(path, ".") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// step follows one path segment into an object or array
+func step(value interface{}, key string) (interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if next, ok := v[key]; ok {
+			return next, true
+		}
+		// JSON keys mix camelCase and snake_case; match the key loosely. When
+		// several keys match, the first in sorted order wins, not the first
+		// the map happens to yield.
+		var match string
+		found := false
+		for k := range v {
+			if looseKey(k) == looseKey(key) && (!found || k < match) {
+				match, found = k, true
+			}
+		}
+		if !found {
+			return nil, false
+		}
+		return v[match], true
+
+	case []interface{}:
+		if i, err := strconv.Atoi(key); err == nil {
+			if i < 0 || i >= len(v) {
+				return nil, false
+			}
+			return v[i], true
+		}
+		var values []interface{}
+		for _, item := range v {
+			if next, ok := step(item, key); ok {
+				values = append(values, next)
+			}
+		}
+		return values, true
+	}
+	return nil, false
+}
+
+// looseKey folds the case and drops the underscores of a JSON key, so
+// "display_name", "displayName" and "DisplayName" are the same key
+func looseKey(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", ""))
+}
+
+// formatJSONValue renders a decoded JSON value as text. Objects have no text
+// form and are empty; null is empty.
+func formatJSONValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			if s := formatJSONValue(item); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, ", ")
+	}
+	return ""
+}
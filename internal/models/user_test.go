@@ -0,0 +1,109 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"firstName", []string{"firstName"}},
+		{"badgeCategory.name", []string{"badgeCategory", "name"}},
+		{"addons[0].name", []string{"addons", "0", "name"}},
+		{"addons.0.name", []string{"addons", "0", "name"}},
+		{"matrix[1][2]", []string{"matrix", "1", "2"}},
+		{" a . b ", []string{"a", "b"}},
+		{"..", nil},
+	}
+	for _, tt := range tests {
+		if got := splitPath(tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestStep(t *testing.T) {
+	object := map[string]interface{}{
+		"displayName":  "exact",
+		"display_name": "snake",
+		"Display_Name": "other",
+		"avatar_url":   "https://example.com/a.png",
+	}
+	array := []interface{}{
+		map[string]interface{}{"name": "Lunch"},
+		map[string]interface{}{"title": "no name"},
+		map[string]interface{}{"name": "Dinner"},
+	}
+	tests := []struct {
+		name  string
+		value interface{}
+		key   string
+		want  interface{}
+		ok    bool
+	}{
+		{"exact key", object, "display_name", "snake", true},
+		{"camelCase for snake_case", object, "avatarUrl", "https://example.com/a.png", true},
+		{"loose match is deterministic", object, "DISPLAYNAME", "other", true},
+		{"missing key", object, "email", nil, false},
+		{"index", array, "2", array[2], true},
+		{"index out of range", array, "3", nil, false},
+		{"negative index", array, "-1", nil, false},
+		{"field across an array", array, "name", []interface{}{"Lunch", "Dinner"}, true},
+		{"scalar", "text", "name", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := step(tt.value, tt.key)
+		if !reflect.DeepEqual(got, tt.want) || ok != tt.ok {
+			t.Errorf("%s: step(%q) = %v, %v; want %v, %v", tt.name, tt.key, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestUserPath(t *testing.T) {
+	var user User
+	err := json.Unmarshal([]byte(`{
+		"ticketNumber": 12345678901234567890,
+		"firstName": "Jane",
+		"price": 12.50,
+		"vip": true,
+		"badge_category": {"display_name": "Speaker", "id": 7},
+		"addons": [{"name": "Lunch"}, {"name": "Dinner"}, {"code": "X"}],
+		"tags": ["a", null, "b"],
+		"company": null
+	}`), &user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"firstName", "Jane", true},
+		{"ticketNumber", "12345678901234567890", true},
+		{"price", "12.50", true},
+		{"vip", "true", true},
+		{"badgeCategory.displayName", "Speaker", true},
+		{"badge_category.id", "7", true},
+		{"addons[1].name", "Dinner", true},
+		{"addons.0.name", "Lunch", true},
+		{"addons[5].name", "", false},
+		{"addons.name", "Lunch, Dinner", true},
+		{"tags", "a, b", true},
+		{"company", "", true},
+		{"badgeCategory", "", true},
+		{"missing", "", false},
+		{"firstName.first", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := user.Path(tt.path)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Path(%q) = %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,715 @@
+package svg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Geometry: affine matrices, paths of lines and cubic Béziers, the path data
+// parser and the basic shapes. Quadratic curves and elliptical arcs are
+// converted to cubics, which is all PDF paths have.
+
+// Point is a position in user units
+type Point struct {
+	X, Y float64
+}
+
+// Rect is an axis-aligned rectangle
+type Rect struct {
+	X, Y, W, H float64
+}
+
+// Matrix is an affine transform mapping (x, y) to
+// (A*x + C*y + E, B*x + D*y + F), the SVG and PDF convention
+type Matrix struct {
+	A, B, C, D, E, F float64
+}
+
+// Identity is the transform that changes nothing
+var Identity = Matrix{A: 1, D: 1}
+
+// Translate returns a translation by (tx, ty)
+func Translate(tx, ty float64) Matrix {
+	return Matrix{A: 1, D: 1, E: tx, F: ty}
+}
+
+// Scaling returns a scale by sx horizontally and sy vertically
+func Scaling(sx, sy float64) Matrix {
+	return Matrix{A: sx, D: sy}
+}
+
+// Rotation returns a clockwise rotation by deg degrees (y points down)
+func Rotation(deg float64) Matrix {
+	sin, cos := math.Sincos(deg * math.Pi / 180)
+	return Matrix{A: cos, B: sin, C: -sin, D: cos}
+}
+
+// Mul returns the transform that applies n, then m
+func (m Matrix) Mul(n Matrix) Matrix {
+	return Matrix{
+		A: m.A*n.A + m.C*n.B,
+		B: m.B*n.A + m.D*n.B,
+		C: m.A*n.C + m.C*n.D,
+		D: m.B*n.C + m.D*n.D,
+		E: m.A*n.E + m.C*n.F + m.E,
+		F: m.B*n.E + m.D*n.F + m.F,
+	}
+}
+
+// Apply transforms a point
+func (m Matrix) Apply(p Point) Point {
+	return Point{m.A*p.X + m.C*p.Y + m.E, m.B*p.X + m.D*p.Y + m.F}
+}
+
+// Invert returns the inverse transform, and false when m is singular
+func (m Matrix) Invert() (Matrix, bool) {
+	det := m.A*m.D - m.B*m.C
+	if math.Abs(det) < 1e-12 {
+		return Matrix{}, false
+	}
+	return Matrix{
+		A: m.D / det,
+		B: -m.B / det,
+		C: -m.C / det,
+		D: m.A / det,
+		E: (m.C*m.F - m.D*m.E) / det,
+		F: (m.B*m.E - m.A*m.F) / det,
+	}, true
+}
+
+// Scale is the average scale factor of m, the square root of how much it
+// scales areas. Stroke widths and flattening tolerances scale by it.
+func (m Matrix) Scale() float64 {
+	return math.Sqrt(math.Abs(m.A*m.D - m.B*m.C))
+}
+
+// Op is a path segment type
+type Op byte
+
+// Path segment types
+const (
+	MoveTo Op = iota // P[0]
+	LineTo           // P[0]
+	CubeTo           // control points P[0] and P[1], end point P[2]
+	Close
+)
+
+// Segment is one path command
+type Segment struct {
+	Op Op
+	P  [3]Point
+}
+
+// Path is a sequence of subpaths, each starting with MoveTo
+type Path []Segment
+
+func (p *Path) moveTo(a Point) {
+	*p = append(*p, Segment{Op: MoveTo, P: [3]Point{a}})
+}
+
+func (p *Path) lineTo(a Point) {
+	*p = append(*p, Segment{Op: LineTo, P: [3]Point{a}})
+}
+
+func (p *Path) cubeTo(c1, c2, a Point) {
+	*p = append(*p, Segment{Op: CubeTo, P: [3]Point{c1, c2, a}})
+}
+
+func (p *Path) close() {
+	*p = append(*p, Segment{Op: Close})
+}
+
+// Transform returns the path with every point transformed by m
+func (p Path) Transform(m Matrix) Path {
+	out := make(Path, len(p))
+	for i, seg := range p {
+		out[i].Op = seg.Op
+		for j := range seg.P {
+			out[i].P[j] = m.Apply(seg.P[j])
+		}
+	}
+	return out
+}
+
+// Bounds returns the tight bounding box of the path, including the extremes
+// of its curves
+func (p Path) Bounds() Rect {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	add := func(q Point) {
+		minX, maxX = math.Min(minX, q.X), math.Max(maxX, q.X)
+		minY, maxY = math.Min(minY, q.Y), math.Max(maxY, q.Y)
+	}
+
+	var cur Point
+	for _, seg := range p {
+		switch seg.Op {
+		case MoveTo, LineTo:
+			cur = seg.P[0]
+			add(cur)
+		case CubeTo:
+			for _, t := range cubicExtrema(cur, seg.P[0], seg.P[1], seg.P[2]) {
+				add(cubicAt(cur, seg.P[0], seg.P[1], seg.P[2], t))
+			}
+			cur = seg.P[2]
+			add(cur)
+		}
+	}
+	if minX > maxX {
+		return Rect{}
+	}
+	return Rect{minX, minY, maxX - minX, maxY - minY}
+}
+
+// cubicAt evaluates a cubic Bézier at t
+func cubicAt(p0, p1, p2, p3 Point, t float64) Point {
+	u := 1 - t
+	a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+	return Point{
+		a*p0.X + b*p1.X + c*p2.X + d*p3.X,
+		a*p0.Y + b*p1.Y + c*p2.Y + d*p3.Y,
+	}
+}
+
+// cubicExtrema returns the parameters in (0, 1) where a cubic Bézier turns
+// horizontally or vertically
+func cubicExtrema(p0, p1, p2, p3 Point) []float64 {
+	var ts []float64
+	for _, v := range [2][4]float64{{p0.X, p1.X, p2.X, p3.X}, {p0.Y, p1.Y, p2.Y, p3.Y}} {
+		// Derivative coefficients: a t² + b t + c
+		a := -v[0] + 3*v[1] - 3*v[2] + v[3]
+		b := 2 * (v[0] - 2*v[1] + v[2])
+		c := v[1] - v[0]
+		if math.Abs(a) < 1e-12 {
+			if math.Abs(b) > 1e-12 {
+				ts = append(ts, -c/b)
+			}
+			continue
+		}
+		disc := b*b - 4*a*c
+		if disc < 0 {
+			continue
+		}
+		sq := math.Sqrt(disc)
+		ts = append(ts, (-b+sq)/(2*a), (-b-sq)/(2*a))
+	}
+
+	out := ts[:0]
+	for _, t := range ts {
+		if t > 0 && t < 1 {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// polyline is a flattened subpath
+type polyline struct {
+	points []Point
+	closed bool
+}
+
+// flatten converts the path to polylines whose distance from the curves is
+// at most tol
+func (p Path) flatten(tol float64) []polyline {
+	var lines []polyline
+	var cur polyline
+	flush := func() {
+		if len(cur.points) > 0 {
+			lines = append(lines, cur)
+		}
+		cur = polyline{}
+	}
+
+	var pos, start Point
+	for _, seg := range p {
+		switch seg.Op {
+		case MoveTo:
+			flush()
+			pos, start = seg.P[0], seg.P[0]
+			cur.points = append(cur.points, pos)
+		case LineTo:
+			if len(cur.points) == 0 {
+				cur.points = append(cur.points, pos)
+			}
+			pos = seg.P[0]
+			cur.points = append(cur.points, pos)
+		case CubeTo:
+			if len(cur.points) == 0 {
+				cur.points = append(cur.points, pos)
+			}
+			n := cubicSteps(pos, seg.P[0], seg.P[1], seg.P[2], tol)
+			for i := 1; i <= n; i++ {
+				cur.points = append(cur.points, cubicAt(pos, seg.P[0], seg.P[1], seg.P[2], float64(i)/float64(n)))
+			}
+			pos = seg.P[2]
+		case Close:
+			if len(cur.points) > 0 {
+				cur.closed = true
+				flush()
+			}
+			// A segment after Z starts at the subpath's start
+			pos = start
+		}
+	}
+	flush()
+	return lines
+}
+
+// cubicSteps is how many line segments approximate a cubic Bézier within tol
+func cubicSteps(p0, p1, p2, p3 Point, tol float64) int {
+	dx := math.Max(math.Abs(p0.X-2*p1.X+p2.X), math.Abs(p1.X-2*p2.X+p3.X))
+	dy := math.Max(math.Abs(p0.Y-2*p1.Y+p2.Y), math.Abs(p1.Y-2*p2.Y+p3.Y))
+	n := int(math.Ceil(math.Sqrt(0.75 * math.Hypot(dx, dy) / tol)))
+	if n < 1 {
+		return 1
+	}
+	if n > 200 {
+		return 200
+	}
+	return n
+}
+
+// kappa places the control points of a cubic Bézier quarter circle
+const kappa = 0.5522847498
+
+// ellipsePath returns an ellipse centered on (cx, cy)
+func ellipsePath(cx, cy, rx, ry float64) Path {
+	kx, ky := rx*kappa, ry*kappa
+	var p Path
+	p.moveTo(Point{cx + rx, cy})
+	p.cubeTo(Point{cx + rx, cy + ky}, Point{cx + kx, cy + ry}, Point{cx, cy + ry})
+	p.cubeTo(Point{cx - kx, cy + ry}, Point{cx - rx, cy + ky}, Point{cx - rx, cy})
+	p.cubeTo(Point{cx - rx, cy - ky}, Point{cx - kx, cy - ry}, Point{cx, cy - ry})
+	p.cubeTo(Point{cx + kx, cy - ry}, Point{cx + rx, cy - ky}, Point{cx + rx, cy})
+	p.close()
+	return p
+}
+
+// rectPath returns a rectangle, with corners rounded by rx and ry
+func rectPath(x, y, w, h, rx, ry float64) Path {
+	var p Path
+	if rx <= 0 || ry <= 0 {
+		p.moveTo(Point{x, y})
+		p.lineTo(Point{x + w, y})
+		p.lineTo(Point{x + w, y + h})
+		p.lineTo(Point{x, y + h})
+		p.close()
+		return p
+	}
+
+	rx, ry = math.Min(rx, w/2), math.Min(ry, h/2)
+	kx, ky := rx*kappa, ry*kappa
+	p.moveTo(Point{x + rx, y})
+	p.lineTo(Point{x + w - rx, y})
+	p.cubeTo(Point{x + w - rx + kx, y}, Point{x + w, y + ry - ky}, Point{x + w, y + ry})
+	p.lineTo(Point{x + w, y + h - ry})
+	p.cubeTo(Point{x + w, y + h - ry + ky}, Point{x + w - rx + kx, y + h}, Point{x + w - rx, y + h})
+	p.lineTo(Point{x + rx, y + h})
+	p.cubeTo(Point{x + rx - kx, y + h}, Point{x, y + h - ry + ky}, Point{x, y + h - ry})
+	p.lineTo(Point{x, y + ry})
+	p.cubeTo(Point{x, y + ry - ky}, Point{x + rx - kx, y}, Point{x + rx, y})
+	p.close()
+	return p
+}
+
+// polyPath returns the path through a points attribute, closed for polygons
+func polyPath(points string, closed bool) Path {
+	nums := parseNumbers(points)
+	var p Path
+	for i := 0; i+1 < len(nums); i += 2 {
+		if i == 0 {
+			p.moveTo(Point{nums[i], nums[i+1]})
+		} else {
+			p.lineTo(Point{nums[i], nums[i+1]})
+		}
+	}
+	if closed && len(p) > 0 {
+		p.close()
+	}
+	return p
+}
+
+// parsePathData parses the d attribute of a path. Like browsers, it keeps
+// what was parsed up to the first error.
+func parsePathData(d string) (Path, error) {
+	s := &scanner{s: d}
+	var p Path
+	var cur, start, lastCtrl Point
+	var prev byte
+
+	for {
+		s.skipSpace()
+		if s.done() {
+			return p, nil
+		}
+
+		cmd := s.s[s.i]
+		if isCommand(cmd) {
+			s.i++
+		} else if prev != 0 && prev != 'z' && prev != 'Z' {
+			// Repeated parameters repeat the command; after a moveto they are linetos
+			cmd = prev
+			if cmd == 'M' {
+				cmd = 'L'
+			} else if cmd == 'm' {
+				cmd = 'l'
+			}
+		} else {
+			return p, fmt.Errorf("path data: unexpected %q", cmd)
+		}
+		rel := cmd >= 'a'
+
+		offset := func(q Point) Point {
+			if rel {
+				return Point{cur.X + q.X, cur.Y + q.Y}
+			}
+			return q
+		}
+
+		var err error
+		switch cmd {
+		case 'M', 'm':
+			var q Point
+			if q, err = s.point(); err != nil {
+				return p, err
+			}
+			cur = offset(q)
+			start = cur
+			p.moveTo(cur)
+			lastCtrl = cur
+
+		case 'L', 'l':
+			var q Point
+			if q, err = s.point(); err != nil {
+				return p, err
+			}
+			cur = offset(q)
+			p.lineTo(cur)
+			lastCtrl = cur
+
+		case 'H', 'h':
+			var x float64
+			if x, err = s.number(); err != nil {
+				return p, err
+			}
+			if rel {
+				x += cur.X
+			}
+			cur = Point{x, cur.Y}
+			p.lineTo(cur)
+			lastCtrl = cur
+
+		case 'V', 'v':
+			var y float64
+			if y, err = s.number(); err != nil {
+				return p, err
+			}
+			if rel {
+				y += cur.Y
+			}
+			cur = Point{cur.X, y}
+			p.lineTo(cur)
+			lastCtrl = cur
+
+		case 'C', 'c', 'S', 's':
+			var c1, c2, end Point
+			if cmd == 'C' || cmd == 'c' {
+				if c1, err = s.point(); err != nil {
+					return p, err
+				}
+				c1 = offset(c1)
+			} else {
+				// The first control point reflects the previous curve's second
+				c1 = cur
+				if prev == 'C' || prev == 'c' || prev == 'S' || prev == 's' {
+					c1 = Point{2*cur.X - lastCtrl.X, 2*cur.Y - lastCtrl.Y}
+				}
+			}
+			if c2, err = s.point(); err != nil {
+				return p, err
+			}
+			if end, err = s.point(); err != nil {
+				return p, err
+			}
+			c2, end = offset(c2), offset(end)
+			p.cubeTo(c1, c2, end)
+			cur, lastCtrl = end, c2
+
+		case 'Q', 'q', 'T', 't':
+			var ctrl, end Point
+			if cmd == 'Q' || cmd == 'q' {
+				if ctrl, err = s.point(); err != nil {
+					return p, err
+				}
+				ctrl = offset(ctrl)
+			} else {
+				ctrl = cur
+				if prev == 'Q' || prev == 'q' || prev == 'T' || prev == 't' {
+					ctrl = Point{2*cur.X - lastCtrl.X, 2*cur.Y - lastCtrl.Y}
+				}
+			}
+			if end, err = s.point(); err != nil {
+				return p, err
+			}
+			end = offset(end)
+			// Degree elevation: the cubic control points are 2/3 of the way to the quadratic one
+			p.cubeTo(
+				Point{cur.X + 2.0/3*(ctrl.X-cur.X), cur.Y + 2.0/3*(ctrl.Y-cur.Y)},
+				Point{end.X + 2.0/3*(ctrl.X-end.X), end.Y + 2.0/3*(ctrl.Y-end.Y)},
+				end)
+			cur, lastCtrl = end, ctrl
+
+		case 'A', 'a':
+			var rx, ry, rotation, large, sweep float64
+			for _, v := range []*float64{&rx, &ry, &rotation} {
+				if *v, err = s.number(); err != nil {
+					return p, err
+				}
+			}
+			// The flags can be written without separators ("a1 1 0 01 5 5")
+			for _, v := range []*float64{&large, &sweep} {
+				if *v, err = s.flag(); err != nil {
+					return p, err
+				}
+			}
+			var end Point
+			if end, err = s.point(); err != nil {
+				return p, err
+			}
+			end = offset(end)
+			arcTo(&p, cur, end, rx, ry, rotation, large != 0, sweep != 0)
+			cur, lastCtrl = end, end
+
+		case 'Z', 'z':
+			p.close()
+			cur, lastCtrl = start, start
+
+		default:
+			return p, fmt.Errorf("path data: unknown command %q", cmd)
+		}
+		prev = cmd
+	}
+}
+
+func isCommand(c byte) bool {
+	return strings.IndexByte("MmLlHhVvCcSsQqTtAaZz", c) >= 0
+}
+
+// arcTo appends an SVG elliptical arc from p0 to p1 as cubic Béziers
+// (SVG 1.1 appendix F.6: endpoint to center parameterization)
+func arcTo(p *Path, p0, p1 Point, rx, ry, rotation float64, large, sweep bool) {
+	if p0 == p1 {
+		return
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		p.lineTo(p1)
+		return
+	}
+
+	sinPhi, cosPhi := math.Sincos(rotation * math.Pi / 180)
+	dx, dy := (p0.X-p1.X)/2, (p0.Y-p1.Y)/2
+	x1 := cosPhi*dx + sinPhi*dy
+	y1 := -sinPhi*dx + cosPhi*dy
+
+	// Radii too small to reach the end point are scaled up
+	if lambda := x1*x1/(rx*rx) + y1*y1/(ry*ry); lambda > 1 {
+		s := math.Sqrt(lambda)
+		rx, ry = rx*s, ry*s
+	}
+
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	coef := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		coef = -coef
+	}
+	cx1 := coef * rx * y1 / ry
+	cy1 := -coef * ry * x1 / rx
+	cx := cosPhi*cx1 - sinPhi*cy1 + (p0.X+p1.X)/2
+	cy := sinPhi*cx1 + cosPhi*cy1 + (p0.Y+p1.Y)/2
+
+	angle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta := angle(1, 0, (x1-cx1)/rx, (y1-cy1)/ry)
+	delta := angle((x1-cx1)/rx, (y1-cy1)/ry, (-x1-cx1)/rx, (-y1-cy1)/ry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	// One cubic per quarter turn at most
+	n := int(math.Ceil(math.Abs(delta) / (math.Pi / 2)))
+	step := delta / float64(n)
+	k := 4.0 / 3 * math.Tan(step/4)
+	at := func(t float64) (Point, Point) {
+		sin, cos := math.Sincos(t)
+		pt := Point{cx + rx*cos*cosPhi - ry*sin*sinPhi, cy + rx*cos*sinPhi + ry*sin*cosPhi}
+		deriv := Point{-rx*sin*cosPhi - ry*cos*sinPhi, -rx*sin*sinPhi + ry*cos*cosPhi}
+		return pt, deriv
+	}
+	for i := 0; i < n; i++ {
+		t0 := theta + float64(i)*step
+		a, da := at(t0)
+		b, db := at(t0 + step)
+		if i == n-1 {
+			b = p1
+		}
+		p.cubeTo(Point{a.X + k*da.X, a.Y + k*da.Y}, Point{b.X - k*db.X, b.Y - k*db.Y}, b)
+	}
+}
+
+// parseTransform parses a transform attribute: a list of matrix, translate,
+// scale, rotate, skewX and skewY functions
+func parseTransform(value string) (Matrix, error) {
+	m := Identity
+	s := &scanner{s: value}
+	for {
+		s.skipSpace()
+		if s.done() {
+			return m, nil
+		}
+		open := strings.IndexByte(s.s[s.i:], '(')
+		closing := strings.IndexByte(s.s[s.i:], ')')
+		if open < 0 || closing < open {
+			return m, fmt.Errorf("transform %q: expected name(values)", value)
+		}
+		name := strings.TrimSpace(s.s[s.i : s.i+open])
+		args := parseNumbers(s.s[s.i+open+1 : s.i+closing])
+		s.i += closing + 1
+
+		var t Matrix
+		switch {
+		case name == "matrix" && len(args) == 6:
+			t = Matrix{args[0], args[1], args[2], args[3], args[4], args[5]}
+		case name == "translate" && len(args) == 1:
+			t = Translate(args[0], 0)
+		case name == "translate" && len(args) == 2:
+			t = Translate(args[0], args[1])
+		case name == "scale" && len(args) == 1:
+			t = Scaling(args[0], args[0])
+		case name == "scale" && len(args) == 2:
+			t = Scaling(args[0], args[1])
+		case name == "rotate" && len(args) == 1:
+			t = Rotation(args[0])
+		case name == "rotate" && len(args) == 3:
+			t = Translate(args[1], args[2]).Mul(Rotation(args[0])).Mul(Translate(-args[1], -args[2]))
+		case name == "skewX" && len(args) == 1:
+			t = Matrix{A: 1, C: math.Tan(args[0] * math.Pi / 180), D: 1}
+		case name == "skewY" && len(args) == 1:
+			t = Matrix{A: 1, B: math.Tan(args[0] * math.Pi / 180), D: 1}
+		default:
+			return m, fmt.Errorf("transform %q: bad %s()", value, name)
+		}
+		m = m.Mul(t)
+	}
+}
+
+// scanner reads numbers from path data and attribute lists
+type scanner struct {
+	s string
+	i int
+}
+
+func (s *scanner) done() bool {
+	return s.i >= len(s.s)
+}
+
+// skipSpace skips white space and at most one comma
+func (s *scanner) skipSpace() {
+	comma := false
+	for !s.done() {
+		c := s.s[s.i]
+		if c == ',' && !comma {
+			comma = true
+		} else if c != ' ' && c != '\t' && c != '\n' && c != '\r' && c != '\f' {
+			return
+		}
+		s.i++
+	}
+}
+
+// number reads a number: sign, digits, fraction and exponent. "1.5.5" is two
+// numbers, as in SVG path data.
+func (s *scanner) number() (float64, error) {
+	s.skipSpace()
+	start := s.i
+	if !s.done() && (s.s[s.i] == '+' || s.s[s.i] == '-') {
+		s.i++
+	}
+	digits := 0
+	for !s.done() && s.s[s.i] >= '0' && s.s[s.i] <= '9' {
+		s.i++
+		digits++
+	}
+	if !s.done() && s.s[s.i] == '.' {
+		s.i++
+		for !s.done() && s.s[s.i] >= '0' && s.s[s.i] <= '9' {
+			s.i++
+			digits++
+		}
+	}
+	if digits == 0 {
+		s.i = start
+		if s.done() {
+			return 0, fmt.Errorf("unexpected end of %q", s.s)
+		}
+		return 0, fmt.Errorf("expected a number at %q", s.s[start:])
+	}
+	if !s.done() && (s.s[s.i] == 'e' || s.s[s.i] == 'E') {
+		j := s.i + 1
+		if j < len(s.s) && (s.s[j] == '+' || s.s[j] == '-') {
+			j++
+		}
+		if j < len(s.s) && s.s[j] >= '0' && s.s[j] <= '9' {
+			for j < len(s.s) && s.s[j] >= '0' && s.s[j] <= '9' {
+				j++
+			}
+			s.i = j
+		}
+	}
+
+	return strconv.ParseFloat(s.s[start:s.i], 64)
+}
+
+// flag reads an arc flag, a single 0 or 1
+func (s *scanner) flag() (float64, error) {
+	s.skipSpace()
+	if s.done() || (s.s[s.i] != '0' && s.s[s.i] != '1') {
+		return 0, fmt.Errorf("expected an arc flag in %q", s.s)
+	}
+	s.i++
+	return float64(s.s[s.i-1] - '0'), nil
+}
+
+func (s *scanner) point() (Point, error) {
+	x, err := s.number()
+	if err != nil {
+		return Point{}, err
+	}
+	y, err := s.number()
+	return Point{x, y}, err
+}
+
+// parseNumbers parses a list of numbers separated by spaces or commas,
+// stopping at the first thing that isn't a number
+func parseNumbers(value string) []float64 {
+	s := &scanner{s: value}
+	var nums []float64
+	for {
+		s.skipSpace()
+		if s.done() {
+			return nums
+		}
+		v, err := s.number()
+		if err != nil {
+			return nums
+		}
+		nums = append(nums, v)
+	}
+}
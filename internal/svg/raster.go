@@ -0,0 +1,598 @@
+package svg
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// Rasterization of single shapes, for paint that PDF vector drawing can't
+// express: gradient strokes, reflected and repeated gradients, gradients with
+// varying opacity and radial gradients with inner stops.
+
+const (
+	rasterSubsamples = 4    // sample rows per pixel row; columns are covered exactly
+	rasterTolerance  = 0.2  // pixels a flattened curve may be off
+	maxRasterSide    = 4096 // pixels; larger shapes are drawn at a lower resolution
+)
+
+// Rasterize draws a shape into an image with its fill, stroke, clips and
+// opacity. m maps the document's user space to the page in mm, and only the
+// part of the shape inside box, an area of the page, is drawn. It returns
+// the image and the area of the page it covers, or nil when the shape draws
+// nothing there.
+func Rasterize(s *Shape, m Matrix, box Rect, dpi float64) (*image.NRGBA, Rect) {
+	full := m.Mul(s.Transform)
+	scale := full.Scale()
+	if scale == 0 || dpi <= 0 {
+		return nil, Rect{}
+	}
+	// Outlines in page mm, flattened finely enough for the resolution
+	tol := rasterTolerance * 25.4 / dpi
+
+	var fill, stroke [][]Point
+	if !s.Fill.None() {
+		for _, line := range s.Path.Transform(full).flatten(tol) {
+			fill = append(fill, line.points)
+		}
+	}
+	if !s.Stroke.None() {
+		for _, poly := range strokeOutline(s, tol/scale) {
+			stroke = append(stroke, transformPoints(poly, full))
+		}
+	}
+	clips := make([][][]Point, len(s.Clips))
+	for i, c := range s.Clips {
+		for _, line := range c.Path.Transform(m).flatten(tol) {
+			clips[i] = append(clips[i], line.points)
+		}
+	}
+
+	bounds, ok := polygonBounds(append(append([][]Point(nil), fill...), stroke...))
+	if !ok {
+		return nil, Rect{}
+	}
+	for _, c := range clips {
+		cb, ok := polygonBounds(c)
+		if !ok {
+			return nil, Rect{}
+		}
+		bounds = intersect(bounds, cb)
+	}
+	// A huge shape seen through a small layer is only drawn where it shows
+	bounds = intersect(bounds, box)
+	if bounds.W <= 0 || bounds.H <= 0 {
+		return nil, Rect{}
+	}
+
+	// Pixel grid over the bounds
+	px := dpi / 25.4
+	if side := math.Max(bounds.W, bounds.H) * px; side > maxRasterSide {
+		px *= maxRasterSide / side
+	}
+	w := int(math.Ceil(bounds.W * px))
+	h := int(math.Ceil(bounds.H * px))
+	if w <= 0 || h <= 0 {
+		return nil, Rect{}
+	}
+	toPixels := Scaling(px, px).Mul(Translate(-bounds.X, -bounds.Y))
+	area := Rect{bounds.X, bounds.Y, float64(w) / px, float64(h) / px}
+
+	clip := make([]float32, w*h)
+	for i := range clip {
+		clip[i] = 1
+	}
+	for i, c := range s.Clips {
+		cov := coverage(transformPolygons(clips[i], toPixels), w, h, c.EvenOdd)
+		for j := range clip {
+			clip[j] *= cov[j]
+		}
+	}
+
+	// Composite fill then stroke, premultiplied
+	buf := make([]float32, 4*w*h)
+	paint := func(polys [][]Point, evenOdd bool, p Paint) {
+		cov := coverage(transformPolygons(polys, toPixels), w, h, evenOdd)
+		shade := newShader(p, s.Path.Bounds(), toPixels.Mul(full))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				i := y*w + x
+				a := cov[i] * clip[i]
+				if a <= 0 {
+					continue
+				}
+				c, alpha := shade(float64(x)+0.5, float64(y)+0.5)
+				a *= float32(alpha * p.Opacity * s.Opacity)
+				o := buf[4*i : 4*i+4]
+				o[0] = float32(c.R)/255*a + o[0]*(1-a)
+				o[1] = float32(c.G)/255*a + o[1]*(1-a)
+				o[2] = float32(c.B)/255*a + o[2]*(1-a)
+				o[3] = a + o[3]*(1-a)
+			}
+		}
+	}
+	if len(fill) > 0 {
+		paint(fill, s.EvenOdd, s.Fill)
+	}
+	if len(stroke) > 0 {
+		paint(stroke, false, s.Stroke)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < w*h; i++ {
+		a := buf[4*i+3]
+		if a <= 0 {
+			continue
+		}
+		img.Pix[4*i] = toByte(buf[4*i] / a)
+		img.Pix[4*i+1] = toByte(buf[4*i+1] / a)
+		img.Pix[4*i+2] = toByte(buf[4*i+2] / a)
+		img.Pix[4*i+3] = toByte(a)
+	}
+	return img, area
+}
+
+func toByte(v float32) uint8 {
+	return uint8(math.Round(float64(math.Max(0, math.Min(1, float64(v))) * 255)))
+}
+
+func transformPoints(points []Point, m Matrix) []Point {
+	out := make([]Point, len(points))
+	for i, p := range points {
+		out[i] = m.Apply(p)
+	}
+	return out
+}
+
+func transformPolygons(polys [][]Point, m Matrix) [][]Point {
+	out := make([][]Point, len(polys))
+	for i, poly := range polys {
+		out[i] = transformPoints(poly, m)
+	}
+	return out
+}
+
+func polygonBounds(polys [][]Point) (Rect, bool) {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, poly := range polys {
+		for _, p := range poly {
+			minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+			minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+		}
+	}
+	if minX > maxX {
+		return Rect{}, false
+	}
+	return Rect{minX, minY, maxX - minX, maxY - minY}, true
+}
+
+func intersect(a, b Rect) Rect {
+	x0, y0 := math.Max(a.X, b.X), math.Max(a.Y, b.Y)
+	x1, y1 := math.Min(a.X+a.W, b.X+b.W), math.Min(a.Y+a.H, b.Y+b.H)
+	return Rect{x0, y0, x1 - x0, y1 - y0}
+}
+
+// edge is a polygon edge, top to bottom
+type edge struct {
+	x0, y0, x1, y1 float64
+	winding        int
+}
+
+// coverage rasterizes closed polygons into per-pixel coverage, 0-1, with the
+// nonzero or even-odd fill rule. Each pixel row is sampled at several
+// heights; along a row, spans are covered exactly.
+func coverage(polys [][]Point, w, h int, evenOdd bool) []float32 {
+	cov := make([]float32, w*h)
+
+	var edges []edge
+	for _, poly := range polys {
+		for i := range poly {
+			a, b := poly[i], poly[(i+1)%len(poly)]
+			switch {
+			case a.Y < b.Y:
+				edges = append(edges, edge{a.X, a.Y, b.X, b.Y, 1})
+			case a.Y > b.Y:
+				edges = append(edges, edge{b.X, b.Y, a.X, a.Y, -1})
+			}
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].y0 < edges[j].y0 })
+
+	type crossing struct {
+		x       float64
+		winding int
+	}
+	var active []*edge
+	var crossings []crossing
+	next := 0
+	const weight = 1.0 / rasterSubsamples
+
+	for row := 0; row < h; row++ {
+		line := cov[row*w : (row+1)*w]
+		for sub := 0; sub < rasterSubsamples; sub++ {
+			y := float64(row) + (float64(sub)+0.5)*weight
+
+			for next < len(edges) && edges[next].y0 <= y {
+				active = append(active, &edges[next])
+				next++
+			}
+			crossings = crossings[:0]
+			kept := active[:0]
+			for _, e := range active {
+				if e.y1 <= y {
+					continue
+				}
+				kept = append(kept, e)
+				if e.y0 <= y {
+					t := (y - e.y0) / (e.y1 - e.y0)
+					crossings = append(crossings, crossing{e.x0 + t*(e.x1-e.x0), e.winding})
+				}
+			}
+			active = kept
+			sort.Slice(crossings, func(i, j int) bool { return crossings[i].x < crossings[j].x })
+
+			winding := 0
+			for i, c := range crossings {
+				winding += c.winding
+				inside := winding != 0
+				if evenOdd {
+					inside = winding%2 != 0
+				}
+				if inside && i+1 < len(crossings) {
+					addSpan(line, c.x, crossings[i+1].x, weight)
+				}
+			}
+		}
+	}
+	for i, v := range cov {
+		if v > 1 {
+			cov[i] = 1
+		}
+	}
+	return cov
+}
+
+// addSpan adds weight times how much of each pixel the span [x0, x1] covers
+func addSpan(line []float32, x0, x1, weight float64) {
+	x0 = math.Max(0, x0)
+	x1 = math.Min(float64(len(line)), x1)
+	if x1 <= x0 {
+		return
+	}
+	i0, i1 := int(x0), int(x1)
+	if i0 == i1 {
+		line[i0] += float32((x1 - x0) * weight)
+		return
+	}
+	line[i0] += float32((float64(i0+1) - x0) * weight)
+	for i := i0 + 1; i < i1; i++ {
+		line[i] += float32(weight)
+	}
+	if i1 < len(line) {
+		line[i1] += float32((x1 - float64(i1)) * weight)
+	}
+}
+
+// shader returns the color and opacity of a paint at a pixel
+type shader func(x, y float64) (Color, float64)
+
+// newShader returns the shader for a paint. bounds is the shape's bounding
+// box in its user space and toPixels maps that space to pixels.
+func newShader(p Paint, bounds Rect, toPixels Matrix) shader {
+	g := p.Gradient
+	if g == nil {
+		return func(x, y float64) (Color, float64) { return p.Color, 1 }
+	}
+
+	inv, ok := toPixels.Mul(g.Space(bounds)).Invert()
+	if !ok {
+		// A gradient over an empty bounding box paints nothing
+		return func(x, y float64) (Color, float64) { return Color{}, 0 }
+	}
+
+	if !g.Radial {
+		dx, dy := g.X2-g.X1, g.Y2-g.Y1
+		length2 := dx*dx + dy*dy
+		return func(x, y float64) (Color, float64) {
+			if length2 == 0 {
+				last := g.Stops[len(g.Stops)-1]
+				return last.Color, last.Opacity
+			}
+			q := inv.Apply(Point{x, y})
+			return g.at(((q.X-g.X1)*dx + (q.Y-g.Y1)*dy) / length2)
+		}
+	}
+
+	fx, fy := g.focus()
+	ex, ey := g.CX-fx, g.CY-fy
+	a := ex*ex + ey*ey - g.R*g.R
+	return func(x, y float64) (Color, float64) {
+		if g.R <= 0 {
+			last := g.Stops[len(g.Stops)-1]
+			return last.Color, last.Opacity
+		}
+		// t where the point is on the circle centered at focus + t*(center - focus)
+		// with radius t*r
+		q := inv.Apply(Point{x, y})
+		dx, dy := q.X-fx, q.Y-fy
+		de := dx*ex + dy*ey
+		disc := de*de - a*(dx*dx+dy*dy)
+		return g.at((de - math.Sqrt(math.Max(0, disc))) / a)
+	}
+}
+
+// focus returns the focal point, moved just inside the end circle when it
+// is outside, as SVG 1.1 does
+func (g *Gradient) focus() (float64, float64) {
+	dx, dy := g.FX-g.CX, g.FY-g.CY
+	if d := math.Hypot(dx, dy); d > g.R*0.99 && d > 0 {
+		s := g.R * 0.99 / d
+		return g.CX + dx*s, g.CY + dy*s
+	}
+	return g.FX, g.FY
+}
+
+// at returns the color and opacity at offset t, after the spread method
+func (g *Gradient) at(t float64) (Color, float64) {
+	switch g.Spread {
+	case "repeat":
+		t -= math.Floor(t)
+	case "reflect":
+		t = math.Mod(math.Abs(t), 2)
+		if t > 1 {
+			t = 2 - t
+		}
+	}
+
+	stops := g.Stops
+	if t <= stops[0].Offset {
+		return stops[0].Color, stops[0].Opacity
+	}
+	for i := 1; i < len(stops); i++ {
+		if t < stops[i].Offset {
+			a, b := stops[i-1], stops[i]
+			f := (t - a.Offset) / (b.Offset - a.Offset)
+			mix := func(u, v uint8) uint8 {
+				return uint8(math.Round(float64(u) + f*(float64(v)-float64(u))))
+			}
+			return Color{mix(a.Color.R, b.Color.R), mix(a.Color.G, b.Color.G), mix(a.Color.B, b.Color.B)},
+				a.Opacity + f*(b.Opacity-a.Opacity)
+		}
+	}
+	last := stops[len(stops)-1]
+	return last.Color, last.Opacity
+}
+
+// strokeOutline returns the outline of a shape's stroke as convex polygons in
+// the path's user space, all wound the same way so that their union fills
+// with the nonzero rule
+func strokeOutline(s *Shape, tol float64) [][]Point {
+	hw := s.Width / 2
+	var polys [][]Point
+	add := func(poly []Point) {
+		if polygonArea(poly) < 0 {
+			for i, j := 0, len(poly)-1; i < j; i, j = i+1, j-1 {
+				poly[i], poly[j] = poly[j], poly[i]
+			}
+		}
+		polys = append(polys, poly)
+	}
+	disc := func(c Point) {
+		add(circlePolygon(c, hw, tol))
+	}
+
+	lines := s.Path.flatten(tol)
+	if len(s.Dash) > 0 {
+		lines = dashLines(lines, s.Dash, s.DashFrom)
+	}
+
+	for _, line := range lines {
+		pts := dedupe(line.points)
+		if len(pts) == 1 {
+			// Zero-length subpaths draw round and square caps as dots
+			switch s.Cap {
+			case "round":
+				disc(pts[0])
+			case "square":
+				c := pts[0]
+				add([]Point{{c.X - hw, c.Y - hw}, {c.X + hw, c.Y - hw}, {c.X + hw, c.Y + hw}, {c.X - hw, c.Y + hw}})
+			}
+			continue
+		}
+		closed := line.closed
+		if closed && pts[0] == pts[len(pts)-1] {
+			pts = pts[:len(pts)-1]
+		}
+		if closed && len(pts) < 2 {
+			continue
+		}
+
+		n := len(pts)
+		segments := n - 1
+		if closed {
+			segments = n
+		}
+		for i := 0; i < segments; i++ {
+			a, b := pts[i], pts[(i+1)%n]
+			nx, ny := normal(a, b)
+			add([]Point{
+				{a.X + nx*hw, a.Y + ny*hw}, {b.X + nx*hw, b.Y + ny*hw},
+				{b.X - nx*hw, b.Y - ny*hw}, {a.X - nx*hw, a.Y - ny*hw},
+			})
+		}
+
+		// Joins at the inner vertices, and at the start of closed subpaths
+		for i := 0; i < n; i++ {
+			if !closed && (i == 0 || i == n-1) {
+				continue
+			}
+			prev, v, next := pts[(i+n-1)%n], pts[i], pts[(i+1)%n]
+			if join := joinPolygon(s, prev, v, next, hw, tol); join != nil {
+				add(join)
+			}
+		}
+
+		if !closed {
+			for _, end := range [2][2]Point{{pts[1], pts[0]}, {pts[n-2], pts[n-1]}} {
+				from, at := end[0], end[1]
+				switch s.Cap {
+				case "round":
+					disc(at)
+				case "square":
+					nx, ny := normal(from, at)
+					dx, dy := -ny, nx // along the line, outwards
+					add([]Point{
+						{at.X + nx*hw, at.Y + ny*hw}, {at.X + nx*hw + dx*hw, at.Y + ny*hw + dy*hw},
+						{at.X - nx*hw + dx*hw, at.Y - ny*hw + dy*hw}, {at.X - nx*hw, at.Y - ny*hw},
+					})
+				}
+			}
+		}
+	}
+	return polys
+}
+
+// joinPolygon returns the polygon that fills the outside of the corner at v
+func joinPolygon(s *Shape, prev, v, next Point, hw, tol float64) []Point {
+	n0x, n0y := normal(prev, v)
+	n1x, n1y := normal(v, next)
+	cross := (v.X-prev.X)*(next.Y-v.Y) - (v.Y-prev.Y)*(next.X-v.X)
+	if math.Abs(cross) < 1e-12 {
+		return nil
+	}
+	// The outside of the turn
+	side := 1.0
+	if cross > 0 {
+		side = -1
+	}
+	a := Point{v.X + side*n0x*hw, v.Y + side*n0y*hw}
+	b := Point{v.X + side*n1x*hw, v.Y + side*n1y*hw}
+
+	switch s.Join {
+	case "round":
+		return circlePolygon(v, hw, tol)
+	case "miter":
+		mx, my := side*(n0x+n1x), side*(n0y+n1y)
+		ml := math.Hypot(mx, my)
+		if ml > 0 {
+			mx, my = mx/ml, my/ml
+			cos := mx*side*n0x + my*side*n0y
+			// The miter length over the stroke width is 1/cos
+			if cos > 0 && 1/cos <= s.Miter {
+				tip := Point{v.X + mx*hw/cos, v.Y + my*hw/cos}
+				return []Point{v, a, tip, b}
+			}
+		}
+	}
+	return []Point{v, a, b}
+}
+
+// normal returns the unit normal to the left of the direction from a to b
+func normal(a, b Point) (float64, float64) {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	l := math.Hypot(dx, dy)
+	if l == 0 {
+		return 0, 0
+	}
+	return -dy / l, dx / l
+}
+
+// circlePolygon approximates a circle within tol
+func circlePolygon(c Point, r, tol float64) []Point {
+	n := 8
+	if r > tol {
+		n = int(math.Ceil(math.Pi / math.Acos(1-tol/r)))
+	}
+	n = int(math.Max(8, math.Min(128, float64(n))))
+	poly := make([]Point, n)
+	for i := range poly {
+		sin, cos := math.Sincos(2 * math.Pi * float64(i) / float64(n))
+		poly[i] = Point{c.X + r*cos, c.Y + r*sin}
+	}
+	return poly
+}
+
+// polygonArea is the signed area of a polygon, positive when it winds
+// clockwise on a y-down page
+func polygonArea(poly []Point) float64 {
+	area := 0.0
+	for i := range poly {
+		a, b := poly[i], poly[(i+1)%len(poly)]
+		area += a.X*b.Y - b.X*a.Y
+	}
+	return area / 2
+}
+
+// dedupe drops consecutive repeated points
+func dedupe(points []Point) []Point {
+	out := points[:1]
+	for _, p := range points[1:] {
+		if p != out[len(out)-1] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// dashLines cuts polylines into dashes. Dashes don't wrap around the start
+// of closed subpaths; they end there.
+func dashLines(lines []polyline, dash []float64, offset float64) []polyline {
+	total := 0.0
+	for _, d := range dash {
+		total += d
+	}
+
+	var out []polyline
+	for _, line := range lines {
+		pts := line.points
+		if line.closed && len(pts) > 1 && pts[0] != pts[len(pts)-1] {
+			pts = append(append([]Point(nil), pts...), pts[0])
+		}
+
+		// Position in the pattern at the start of the subpath
+		idx, left := 0, 0.0
+		pos := math.Mod(offset, total)
+		if pos < 0 {
+			pos += total
+		}
+		for pos >= dash[idx] {
+			pos -= dash[idx]
+			idx = (idx + 1) % len(dash)
+		}
+		left = dash[idx] - pos
+
+		var cur []Point
+		on := idx%2 == 0
+		if on {
+			cur = []Point{pts[0]}
+		}
+		for i := 1; i < len(pts); i++ {
+			a, b := pts[i-1], pts[i]
+			segLen := math.Hypot(b.X-a.X, b.Y-a.Y)
+			done := 0.0
+			for segLen-done > left {
+				done += left
+				t := done / segLen
+				p := Point{a.X + t*(b.X-a.X), a.Y + t*(b.Y-a.Y)}
+				if on {
+					out = append(out, polyline{points: append(cur, p)})
+					cur = nil
+				} else {
+					cur = []Point{p}
+				}
+				on = !on
+				idx = (idx + 1) % len(dash)
+				left = dash[idx]
+			}
+			left -= segLen - done
+			if on {
+				cur = append(cur, b)
+			}
+		}
+		if on && len(cur) > 0 {
+			out = append(out, polyline{points: cur})
+		}
+	}
+	return out
+}
@@ -0,0 +1,419 @@
+package svg
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+// Styling: presentation attributes, <style> sheets with simple selectors,
+// style attributes, property inheritance, colors and lengths.
+
+// properties are the styling properties read from attributes and CSS
+var properties = map[string]bool{
+	"fill": true, "fill-opacity": true, "fill-rule": true,
+	"stroke": true, "stroke-width": true, "stroke-opacity": true,
+	"stroke-linecap": true, "stroke-linejoin": true, "stroke-miterlimit": true,
+	"stroke-dasharray": true, "stroke-dashoffset": true,
+	"opacity": true, "color": true, "display": true, "visibility": true,
+	"clip-path": true, "clip-rule": true, "mask": true, "filter": true,
+	"stop-color": true, "stop-opacity": true,
+	"marker-start": true, "marker-mid": true, "marker-end": true, "marker": true,
+}
+
+// inherited are the properties children take from their parent when they
+// don't set them
+var inherited = map[string]bool{
+	"fill": true, "fill-opacity": true, "fill-rule": true,
+	"stroke": true, "stroke-width": true, "stroke-opacity": true,
+	"stroke-linecap": true, "stroke-linejoin": true, "stroke-miterlimit": true,
+	"stroke-dasharray": true, "stroke-dashoffset": true,
+	"color": true, "visibility": true, "clip-rule": true,
+	"marker-start": true, "marker-mid": true, "marker-end": true,
+}
+
+// style holds computed property values
+type style map[string]string
+
+// rootStyle has the initial values of the properties that have a default
+// other than "not set"
+var rootStyle = style{
+	"color":             "black",
+	"fill":              "black",
+	"stroke":            "none",
+	"stroke-width":      "1",
+	"stroke-miterlimit": "4",
+	"fill-rule":         "nonzero",
+	"clip-rule":         "nonzero",
+}
+
+// cssRule is one selector of a style sheet rule with its declarations
+type cssRule struct {
+	tag         string // "" or "*" matches any element
+	id          string
+	classes     []string
+	specificity int
+	order       int
+	decls       [][2]string
+}
+
+func (r *cssRule) matches(n *node) bool {
+	if r.tag != "" && r.tag != "*" && r.tag != n.name {
+		return false
+	}
+	if r.id != "" && r.id != n.attrs["id"] {
+		return false
+	}
+	if len(r.classes) > 0 {
+		classes := strings.Fields(n.attrs["class"])
+		for _, want := range r.classes {
+			found := false
+			for _, c := range classes {
+				if c == want {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// parseStyleSheet parses the rules of a <style> element. Only simple
+// selectors (type, class, ID and their combinations) are supported; it
+// reports whether rules with other selectors were skipped.
+func parseStyleSheet(css string, order int) (rules []cssRule, skipped bool) {
+	css = stripComments(css)
+	for {
+		open := strings.IndexByte(css, '{')
+		if open < 0 {
+			return rules, skipped
+		}
+		selectors := strings.TrimSpace(css[:open])
+		end := matchingBrace(css, open)
+		body := css[open+1 : end]
+		if end < len(css) {
+			end++
+		}
+		css = css[end:]
+
+		// At-rules (@media, @font-face) are ignored
+		if strings.HasPrefix(selectors, "@") {
+			continue
+		}
+
+		decls := parseDeclarations(body)
+		for _, sel := range strings.Split(selectors, ",") {
+			rule, ok := parseSelector(strings.TrimSpace(sel))
+			if !ok {
+				skipped = true
+				continue
+			}
+			rule.order = order
+			order++
+			rule.decls = decls
+			rules = append(rules, rule)
+		}
+	}
+}
+
+// matchingBrace returns the index of the brace closing the one at open, or
+// len(s) when it isn't closed
+func matchingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(s)
+}
+
+func stripComments(css string) string {
+	for {
+		start := strings.Index(css, "/*")
+		if start < 0 {
+			return css
+		}
+		end := strings.Index(css[start+2:], "*/")
+		if end < 0 {
+			return css[:start]
+		}
+		css = css[:start] + css[start+2+end+2:]
+	}
+}
+
+// parseSelector parses a compound selector such as "path", ".cls-1",
+// "#logo" or "g.brand"
+func parseSelector(sel string) (cssRule, bool) {
+	if sel == "" || strings.ContainsAny(sel, " >+~:[") {
+		return cssRule{}, false
+	}
+
+	var rule cssRule
+	for len(sel) > 0 {
+		end := strings.IndexAny(sel[1:], ".#") + 1
+		if end == 0 {
+			end = len(sel)
+		}
+		part := sel[:end]
+		sel = sel[end:]
+
+		switch part[0] {
+		case '.':
+			rule.classes = append(rule.classes, part[1:])
+			rule.specificity += 10
+		case '#':
+			rule.id = part[1:]
+			rule.specificity += 100
+		default:
+			if rule.tag != "" {
+				return cssRule{}, false
+			}
+			rule.tag = part
+			if part != "*" {
+				rule.specificity++
+			}
+		}
+	}
+	return rule, true
+}
+
+// parseDeclarations parses "name: value; ..." declarations
+func parseDeclarations(body string) [][2]string {
+	var decls [][2]string
+	for _, decl := range strings.Split(body, ";") {
+		colon := strings.IndexByte(decl, ':')
+		if colon < 0 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(decl[:colon]))
+		value := strings.TrimSpace(decl[colon+1:])
+		value = strings.TrimSpace(strings.TrimSuffix(value, "!important"))
+		if name != "" && value != "" {
+			decls = append(decls, [2]string{name, value})
+		}
+	}
+	return decls
+}
+
+// computeStyle works out the properties of an element from its parent's:
+// presentation attributes, then matching style sheet rules by specificity,
+// then the style attribute, each overriding the last
+func (p *parser) computeStyle(n *node, parent style) style {
+	specified := make(style)
+	for name, value := range n.attrs {
+		if properties[name] {
+			specified[name] = strings.TrimSpace(value)
+		}
+	}
+
+	var matched []*cssRule
+	for i := range p.rules {
+		if p.rules[i].matches(n) {
+			matched = append(matched, &p.rules[i])
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].specificity != matched[j].specificity {
+			return matched[i].specificity < matched[j].specificity
+		}
+		return matched[i].order < matched[j].order
+	})
+	for _, rule := range matched {
+		for _, decl := range rule.decls {
+			specified[decl[0]] = decl[1]
+		}
+	}
+	for _, decl := range parseDeclarations(n.attrs["style"]) {
+		specified[decl[0]] = decl[1]
+	}
+
+	computed := make(style)
+	for name, value := range parent {
+		if inherited[name] {
+			computed[name] = value
+		}
+	}
+	for name, value := range specified {
+		if value == "inherit" {
+			if v, ok := parent[name]; ok {
+				computed[name] = v
+			}
+			continue
+		}
+		computed[name] = value
+	}
+	return computed
+}
+
+// number returns a numeric property, or def when it is not set or invalid
+func (s style) number(name string, def float64) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s[name]), 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return def
+	}
+	return v
+}
+
+// opacity returns an opacity property, 1 when it is not set
+func (s style) opacity(name string) float64 {
+	if value := strings.TrimSpace(s[name]); value != "" {
+		return parseOpacity(value)
+	}
+	return 1
+}
+
+// parseOpacity parses an opacity, a number or a percentage clamped to 0-1
+func parseOpacity(value string) float64 {
+	scale := 1.0
+	if strings.HasSuffix(value, "%") {
+		value, scale = strings.TrimSuffix(value, "%"), 0.01
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 1
+	}
+	return math.Max(0, math.Min(1, v*scale))
+}
+
+// Color is an RGB color
+type Color struct {
+	R, G, B uint8
+}
+
+// parseColor parses a CSS color: a name, #rgb, #rrggbb (with optional
+// alpha), rgb() or rgba(). currentColor is the value of the color property.
+func parseColor(value, currentColor string) (Color, float64, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "currentcolor" {
+		value = strings.ToLower(strings.TrimSpace(currentColor))
+		if value == "currentcolor" {
+			value = "black"
+		}
+	}
+	if value == "" || value == "none" || value == "transparent" {
+		return Color{}, 0, false
+	}
+
+	if c, ok := colornames.Map[value]; ok {
+		return Color{c.R, c.G, c.B}, 1, true
+	}
+
+	if strings.HasPrefix(value, "#") {
+		hex := value[1:]
+		if len(hex) == 3 || len(hex) == 4 {
+			long := make([]byte, 0, 8)
+			for i := range hex {
+				long = append(long, hex[i], hex[i])
+			}
+			hex = string(long)
+		}
+		if len(hex) != 6 && len(hex) != 8 {
+			return Color{}, 0, false
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return Color{}, 0, false
+		}
+		alpha := 1.0
+		if len(hex) == 8 {
+			alpha = float64(v&0xFF) / 255
+			v >>= 8
+		}
+		return Color{uint8(v >> 16), uint8(v >> 8), uint8(v)}, alpha, true
+	}
+
+	if strings.HasPrefix(value, "rgb") {
+		open := strings.IndexByte(value, '(')
+		closing := strings.LastIndexByte(value, ')')
+		if open < 0 || closing < open {
+			return Color{}, 0, false
+		}
+		parts := strings.FieldsFunc(value[open+1:closing], func(c rune) bool {
+			return c == ',' || c == ' ' || c == '/'
+		})
+		if len(parts) < 3 {
+			return Color{}, 0, false
+		}
+		var ch [3]uint8
+		for i := 0; i < 3; i++ {
+			v, err := strconv.ParseFloat(strings.TrimSuffix(parts[i], "%"), 64)
+			if err != nil {
+				return Color{}, 0, false
+			}
+			if strings.HasSuffix(parts[i], "%") {
+				v = v * 255 / 100
+			}
+			ch[i] = uint8(math.Round(math.Max(0, math.Min(255, v))))
+		}
+		alpha := 1.0
+		if len(parts) >= 4 {
+			alpha = parseOpacity(parts[3])
+		}
+		return Color{ch[0], ch[1], ch[2]}, alpha, true
+	}
+	return Color{}, 0, false
+}
+
+// Length units in px (user units), at the CSS 96 pixels per inch
+var unitPx = map[string]float64{
+	"":   1,
+	"px": 1,
+	"pt": 96.0 / 72,
+	"pc": 16,
+	"in": 96,
+	"cm": 96 / 2.54,
+	"mm": 96 / 25.4,
+	"em": 16,
+	"ex": 8,
+}
+
+// parseLength parses a length in user units. Percentages are of ref.
+func parseLength(value string, ref float64) (float64, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if strings.HasSuffix(value, "%") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value, "%")), 64)
+		if err != nil {
+			return 0, false
+		}
+		return v / 100 * ref, true
+	}
+
+	i := len(value)
+	for i > 0 && (value[i-1] >= 'a' && value[i-1] <= 'z' || value[i-1] >= 'A' && value[i-1] <= 'Z') {
+		i--
+	}
+	scale, ok := unitPx[strings.ToLower(value[i:])]
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(value[:i]), 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, false
+	}
+	return v * scale, true
+}
+
+// length parses a length attribute, def when it is missing or invalid
+func length(value string, ref, def float64) float64 {
+	if v, ok := parseLength(value, ref); ok {
+		return v
+	}
+	return def
+}
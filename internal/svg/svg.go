@@ -0,0 +1,906 @@
+// Package svg parses SVG documents into filled and stroked paths, so they can
+// be drawn as PDF vector graphics, and rasterizes single shapes whose paint
+// PDF drawing can't express.
+//
+// Supported are paths and the basic shapes; groups, <use>, <symbol> and
+// nested <svg>; transforms; fills and strokes with colors, opacities, dashes,
+// caps and joins; linear and radial gradients; clip paths; <style> sheets
+// with simple selectors; viewBox and preserveAspectRatio. Text, embedded
+// images, filters, masks, patterns and markers are left out, with a warning.
+package svg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// Namespace is the SVG XML namespace
+const Namespace = "http://www.w3.org/2000/svg"
+
+// Limits on what a document may expand to. <use> elements referencing groups
+// of <use> elements multiply, so a small file could otherwise make millions
+// of shapes.
+const (
+	maxUseDepth = 16    // <use> elements referencing <use> elements
+	maxUses     = 10000 // <use> expansions per document
+	maxShapes   = 10000 // shapes per document
+)
+
+// Document is a parsed SVG
+type Document struct {
+	Width, Height float64  // intrinsic size in mm, 0 when the SVG has none
+	ViewBox       Rect     // area of the user space that is shown
+	Align         string   // preserveAspectRatio alignment, such as "xMidYMid", or "none"
+	Slice         bool     // preserveAspectRatio slice: cover the viewport instead of fitting in it
+	Shapes        []*Shape // in painting order
+	Warnings      []string // features that are left out
+}
+
+// Shape is a path with its fill and stroke
+type Shape struct {
+	Path      Path
+	Transform Matrix // user space of the path to the document's user space
+	Clips     []Clip // in the document's user space; all of them apply
+
+	Fill     Paint
+	EvenOdd  bool // fill-rule evenodd
+	Stroke   Paint
+	Width    float64 // stroke width in the path's user units
+	Cap      string  // butt, round or square
+	Join     string  // miter, round or bevel
+	Miter    float64 // miter limit
+	Dash     []float64
+	DashFrom float64 // stroke-dashoffset
+
+	Opacity float64 // of the element and its groups
+}
+
+// Clip is a clipping path
+type Clip struct {
+	Path    Path
+	EvenOdd bool
+}
+
+// Paint is how a fill or stroke is painted
+type Paint struct {
+	Gradient *Gradient // nil for solid colors
+	Color    Color
+	Opacity  float64 // fill-opacity or stroke-opacity, times the color's alpha; 0 paints nothing
+}
+
+// None reports whether the paint draws nothing
+func (p Paint) None() bool {
+	return p.Opacity <= 0
+}
+
+// Gradient is a linear or radial gradient
+type Gradient struct {
+	Radial         bool
+	X1, Y1, X2, Y2 float64 // linear: the gradient vector
+	CX, CY, R      float64 // radial: the end circle
+	FX, FY         float64 // radial: the focal point, where offset 0 is
+	BoundingBox    bool    // coordinates are fractions of the shape's bounding box
+	Transform      Matrix  // gradientTransform
+	Spread         string  // pad, reflect or repeat
+	Stops          []Stop  // sorted by offset
+}
+
+// Stop is a gradient color stop
+type Stop struct {
+	Offset  float64
+	Color   Color
+	Opacity float64
+}
+
+// Space returns the transform from the gradient's coordinates to the user
+// space of a shape whose path has the given bounds
+func (g *Gradient) Space(bounds Rect) Matrix {
+	if g.BoundingBox {
+		return Matrix{A: bounds.W, D: bounds.H, E: bounds.X, F: bounds.Y}.Mul(g.Transform)
+	}
+	return g.Transform
+}
+
+// node is an element of the XML tree
+type node struct {
+	name     string            // local name
+	attrs    map[string]string // by local name, so xlink:href is "href"
+	children []*node
+	text     string // character data, for <style>
+}
+
+// parser holds the state of a parse
+type parser struct {
+	doc       *Document
+	ids       map[string]*node
+	rules     []cssRule
+	viewports []Rect // for percentage lengths, innermost last
+	warned    map[string]bool
+	useDepth  int
+	uses      int
+	stopped   bool // a limit was hit; nothing more is drawn
+	gradients map[*node]*Gradient
+}
+
+// Parse parses an SVG document
+func Parse(data []byte) (*Document, error) {
+	root, err := parseXML(data)
+	if err != nil {
+		return nil, err
+	}
+	if root.name != "svg" {
+		return nil, errors.New("not an SVG document")
+	}
+
+	p := &parser{
+		doc:       &Document{},
+		ids:       make(map[string]*node),
+		warned:    make(map[string]bool),
+		gradients: make(map[*node]*Gradient),
+	}
+	p.index(root)
+
+	// Intrinsic size and viewBox of the outermost <svg>
+	widthPx, hasWidth := parseLength(root.attrs["width"], 0)
+	heightPx, hasHeight := parseLength(root.attrs["height"], 0)
+	if strings.HasSuffix(strings.TrimSpace(root.attrs["width"]), "%") {
+		hasWidth = false
+	}
+	if strings.HasSuffix(strings.TrimSpace(root.attrs["height"]), "%") {
+		hasHeight = false
+	}
+	viewBox, hasViewBox := parseViewBox(root.attrs["viewBox"])
+	switch {
+	case hasViewBox:
+	case hasWidth && hasHeight && widthPx > 0 && heightPx > 0:
+		// Without a viewBox, user units are pixels of the given size
+		viewBox, hasViewBox = Rect{0, 0, widthPx, heightPx}, true
+	}
+	if hasWidth && hasHeight && widthPx > 0 && heightPx > 0 {
+		p.doc.Width, p.doc.Height = widthPx*25.4/96, heightPx*25.4/96
+	} else if hasViewBox {
+		// A viewBox alone gives the aspect ratio; the size is in pixels
+		w, h := viewBox.W, viewBox.H
+		if hasWidth && widthPx > 0 {
+			w, h = widthPx, widthPx*viewBox.H/viewBox.W
+		} else if hasHeight && heightPx > 0 {
+			w, h = heightPx*viewBox.W/viewBox.H, heightPx
+		}
+		p.doc.Width, p.doc.Height = w*25.4/96, h*25.4/96
+	}
+	p.doc.ViewBox = viewBox
+	p.doc.Align, p.doc.Slice = parseAspectRatio(root.attrs["preserveAspectRatio"])
+
+	ref := viewBox
+	if !hasViewBox {
+		// Percentages need a reference; browsers use 300 x 150
+		ref = Rect{0, 0, 300, 150}
+	}
+	p.viewports = []Rect{ref}
+	p.children(root, p.computeStyle(root, rootStyle), Identity, 1, nil)
+
+	if !hasViewBox {
+		// Show everything that was drawn
+		p.doc.ViewBox = p.drawnBounds()
+		if p.doc.Width == 0 {
+			p.doc.Width, p.doc.Height = p.doc.ViewBox.W*25.4/96, p.doc.ViewBox.H*25.4/96
+		}
+	}
+	return p.doc, nil
+}
+
+// parseXML reads the document into a tree of SVG elements. Elements in
+// other namespaces (editor metadata) are dropped.
+func parseXML(data []byte) (*node, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	// Editors write entities declared in a DOCTYPE (&ns_svg;) and non-UTF-8
+	// encodings; path data and styles are ASCII either way
+	dec.Strict = false
+	dec.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) {
+		return r, nil
+	}
+
+	var root *node
+	var stack []*node
+	skip := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid SVG: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 || (t.Name.Space != "" && t.Name.Space != Namespace) {
+				skip++
+				continue
+			}
+			n := &node{name: t.Name.Local, attrs: make(map[string]string, len(t.Attr))}
+			for _, a := range t.Attr {
+				// Attributes of other namespaces don't override SVG ones, except xlink:href
+				if a.Name.Space != "" && a.Name.Space != Namespace && a.Name.Local != "href" {
+					continue
+				}
+				n.attrs[a.Name.Local] = a.Value
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if root == nil {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if skip == 0 && len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, errors.New("not an SVG document")
+	}
+	return root, nil
+}
+
+// index records the elements with an id and the style sheets
+func (p *parser) index(n *node) {
+	if id := n.attrs["id"]; id != "" {
+		if _, dup := p.ids[id]; !dup {
+			p.ids[id] = n
+		}
+	}
+	if n.name == "style" {
+		rules, skipped := parseStyleSheet(n.text, len(p.rules))
+		p.rules = append(p.rules, rules...)
+		if skipped {
+			p.warn("CSS rules with complex selectors are ignored")
+		}
+	}
+	for _, child := range n.children {
+		p.index(child)
+	}
+}
+
+func (p *parser) warn(msg string) {
+	if !p.warned[msg] {
+		p.warned[msg] = true
+		p.doc.Warnings = append(p.doc.Warnings, msg)
+	}
+}
+
+// stop ends the walk of the document with a warning
+func (p *parser) stop(msg string) {
+	p.stopped = true
+	p.warn(msg)
+}
+
+func (p *parser) viewport() Rect {
+	return p.viewports[len(p.viewports)-1]
+}
+
+// lengthX, lengthY and lengthR parse lengths whose percentages are of the
+// viewport's width, height and normalized diagonal
+func (p *parser) lengthX(value string, def float64) float64 {
+	return length(value, p.viewport().W, def)
+}
+
+func (p *parser) lengthY(value string, def float64) float64 {
+	return length(value, p.viewport().H, def)
+}
+
+func (p *parser) lengthR(value string, def float64) float64 {
+	vp := p.viewport()
+	return length(value, math.Hypot(vp.W, vp.H)/math.Sqrt2, def)
+}
+
+// children walks the children of a container element
+func (p *parser) children(n *node, st style, m Matrix, opacity float64, clips []Clip) {
+	for _, child := range n.children {
+		p.element(child, st, m, opacity, clips)
+	}
+}
+
+// element walks an element with its parent's computed style, transform to
+// the document's user space, opacity and clip paths
+func (p *parser) element(n *node, parent style, m Matrix, opacity float64, clips []Clip) {
+	if p.stopped {
+		return
+	}
+	switch n.name {
+	case "defs", "style", "title", "desc", "metadata", "symbol", "clipPath", "mask",
+		"linearGradient", "radialGradient", "pattern", "marker", "filter", "script":
+		// Not rendered where they are; <use>, url() references and index read them
+		return
+	}
+
+	st := p.computeStyle(n, parent)
+	if strings.TrimSpace(st["display"]) == "none" {
+		return
+	}
+	if t, ok := n.attrs["transform"]; ok {
+		tm, err := parseTransform(t)
+		if err != nil {
+			p.warn(err.Error())
+		}
+		m = m.Mul(tm)
+	}
+	opacity *= st.opacity("opacity")
+	if opacity <= 0 {
+		return
+	}
+	if v := st["filter"]; v != "" && v != "none" {
+		p.warn("filter effects are not supported and are left out")
+	}
+	if v := st["mask"]; v != "" && v != "none" {
+		p.warn("masks are not supported and are left out")
+	}
+
+	switch n.name {
+	case "g", "a":
+		clips = p.clipPath(n, st, m, clips, nil)
+		p.children(n, st, m, opacity, clips)
+
+	case "switch":
+		// The first child meant for any renderer; editors put their own
+		// formats first with requiredExtensions
+		clips = p.clipPath(n, st, m, clips, nil)
+		for _, child := range n.children {
+			if _, ok := child.attrs["requiredExtensions"]; !ok && child.name != "foreignObject" {
+				p.element(child, st, m, opacity, clips)
+				break
+			}
+		}
+
+	case "svg":
+		p.nestedSVG(n, st, m, opacity, clips)
+
+	case "use":
+		p.use(n, st, m, opacity, clips)
+
+	case "path", "rect", "circle", "ellipse", "line", "polyline", "polygon":
+		p.shape(n, st, m, opacity, clips)
+
+	case "text", "image", "foreignObject", "video", "audio", "iframe", "canvas":
+		p.warn(fmt.Sprintf("<%s> elements are not supported and are left out", n.name))
+
+	default:
+		// Unknown elements and their children aren't rendered, as in browsers
+	}
+}
+
+// nestedSVG walks an <svg> inside the document: a group with its own
+// viewport and viewBox
+func (p *parser) nestedSVG(n *node, st style, m Matrix, opacity float64, clips []Clip) {
+	x := p.lengthX(n.attrs["x"], 0)
+	y := p.lengthY(n.attrs["y"], 0)
+	w := p.lengthX(n.attrs["width"], p.viewport().W)
+	h := p.lengthY(n.attrs["height"], p.viewport().H)
+	if w <= 0 || h <= 0 {
+		return
+	}
+
+	// The viewport clips its content
+	clips = append(append([]Clip(nil), clips...), Clip{Path: rectPath(x, y, w, h, 0, 0).Transform(m)})
+
+	if viewBox, ok := parseViewBox(n.attrs["viewBox"]); ok {
+		align, slice := parseAspectRatio(n.attrs["preserveAspectRatio"])
+		m = m.Mul(ViewBoxTransform(viewBox, align, slice, Rect{x, y, w, h}))
+		p.viewports = append(p.viewports, viewBox)
+	} else {
+		m = m.Mul(Translate(x, y))
+		p.viewports = append(p.viewports, Rect{0, 0, w, h})
+	}
+	p.children(n, st, m, opacity, clips)
+	p.viewports = p.viewports[:len(p.viewports)-1]
+}
+
+// use walks the element a <use> references, as if it were a child of the
+// <use> moved by x and y
+func (p *parser) use(n *node, st style, m Matrix, opacity float64, clips []Clip) {
+	ref := p.reference(n.attrs["href"])
+	if ref == nil || p.useDepth >= maxUseDepth {
+		return
+	}
+	if p.uses++; p.uses > maxUses {
+		p.stop(fmt.Sprintf("more than %d <use> expansions; the rest of the document is left out", maxUses))
+		return
+	}
+	p.useDepth++
+	defer func() { p.useDepth-- }()
+
+	clips = p.clipPath(n, st, m, clips, nil)
+	x := p.lengthX(n.attrs["x"], 0)
+	y := p.lengthY(n.attrs["y"], 0)
+	m = m.Mul(Translate(x, y))
+
+	if ref.name != "symbol" {
+		p.element(ref, st, m, opacity, clips)
+		return
+	}
+
+	// A symbol is drawn like a nested <svg> sized by the <use>
+	symStyle := p.computeStyle(ref, st)
+	w := p.lengthX(n.attrs["width"], p.viewport().W)
+	h := p.lengthY(n.attrs["height"], p.viewport().H)
+	if viewBox, ok := parseViewBox(ref.attrs["viewBox"]); ok && w > 0 && h > 0 {
+		align, slice := parseAspectRatio(ref.attrs["preserveAspectRatio"])
+		m = m.Mul(ViewBoxTransform(viewBox, align, slice, Rect{0, 0, w, h}))
+		p.viewports = append(p.viewports, viewBox)
+		defer func() { p.viewports = p.viewports[:len(p.viewports)-1] }()
+	}
+	p.children(ref, symStyle, m, opacity*symStyle.opacity("opacity"), clips)
+}
+
+// reference returns the element an "#id" or "url(#id)" reference points to
+func (p *parser) reference(ref string) *node {
+	ref = strings.TrimSpace(ref)
+	if strings.HasPrefix(ref, "url(") {
+		end := strings.IndexByte(ref, ')')
+		if end < 0 {
+			return nil
+		}
+		ref = strings.Trim(strings.TrimSpace(ref[4:end]), `"'`)
+	}
+	if !strings.HasPrefix(ref, "#") {
+		return nil
+	}
+	return p.ids[ref[1:]]
+}
+
+// shapePath returns the geometry of a basic shape or path element
+func (p *parser) shapePath(n *node) Path {
+	a := n.attrs
+	switch n.name {
+	case "path":
+		path, err := parsePathData(a["d"])
+		if err != nil {
+			p.warn(err.Error())
+		}
+		return path
+	case "rect":
+		w, h := p.lengthX(a["width"], 0), p.lengthY(a["height"], 0)
+		if w <= 0 || h <= 0 {
+			return nil
+		}
+		rx, hasRX := parseLength(a["rx"], p.viewport().W)
+		ry, hasRY := parseLength(a["ry"], p.viewport().H)
+		if !hasRX {
+			rx = ry
+		}
+		if !hasRY {
+			ry = rx
+		}
+		return rectPath(p.lengthX(a["x"], 0), p.lengthY(a["y"], 0), w, h, rx, ry)
+	case "circle":
+		r := p.lengthR(a["r"], 0)
+		if r <= 0 {
+			return nil
+		}
+		return ellipsePath(p.lengthX(a["cx"], 0), p.lengthY(a["cy"], 0), r, r)
+	case "ellipse":
+		rx, ry := p.lengthX(a["rx"], 0), p.lengthY(a["ry"], 0)
+		if rx <= 0 || ry <= 0 {
+			return nil
+		}
+		return ellipsePath(p.lengthX(a["cx"], 0), p.lengthY(a["cy"], 0), rx, ry)
+	case "line":
+		var path Path
+		path.moveTo(Point{p.lengthX(a["x1"], 0), p.lengthY(a["y1"], 0)})
+		path.lineTo(Point{p.lengthX(a["x2"], 0), p.lengthY(a["y2"], 0)})
+		return path
+	case "polyline":
+		return polyPath(a["points"], false)
+	case "polygon":
+		return polyPath(a["points"], true)
+	}
+	return nil
+}
+
+// shape adds a path or basic shape to the document
+func (p *parser) shape(n *node, st style, m Matrix, opacity float64, clips []Clip) {
+	path := p.shapePath(n)
+	if len(path) == 0 {
+		return
+	}
+	if v := strings.TrimSpace(st["visibility"]); v == "hidden" || v == "collapse" {
+		return
+	}
+	for _, marker := range []string{"marker-start", "marker-mid", "marker-end", "marker"} {
+		if v := st[marker]; v != "" && v != "none" {
+			p.warn("markers are not supported and are left out")
+		}
+	}
+
+	bounds := path.Bounds()
+	clips = p.clipPath(n, st, m, clips, &bounds)
+	for _, c := range clips {
+		if len(c.Path) == 0 {
+			return // clipped away entirely
+		}
+	}
+
+	s := &Shape{
+		Path:      path,
+		Transform: m,
+		Clips:     clips,
+		EvenOdd:   strings.TrimSpace(st["fill-rule"]) == "evenodd",
+		Width:     p.lengthR(st["stroke-width"], 1),
+		Cap:       strings.TrimSpace(st["stroke-linecap"]),
+		Join:      strings.TrimSpace(st["stroke-linejoin"]),
+		Miter:     st.number("stroke-miterlimit", 4),
+		DashFrom:  p.lengthR(st["stroke-dashoffset"], 0),
+		Opacity:   opacity,
+	}
+	if s.Cap != "round" && s.Cap != "square" {
+		s.Cap = "butt"
+	}
+	if s.Join != "round" && s.Join != "bevel" {
+		s.Join = "miter"
+	}
+	if s.Miter < 1 {
+		s.Miter = 1
+	}
+	s.Dash = p.dashArray(st["stroke-dasharray"])
+
+	// Lines have no inside to fill
+	if n.name != "line" {
+		s.Fill = p.paint(st["fill"], st, st.opacity("fill-opacity"))
+	}
+	if s.Width > 0 {
+		s.Stroke = p.paint(st["stroke"], st, st.opacity("stroke-opacity"))
+	}
+	if s.Fill.None() && s.Stroke.None() {
+		return
+	}
+	if len(p.doc.Shapes) >= maxShapes {
+		p.stop(fmt.Sprintf("more than %d shapes; the rest of the document is left out", maxShapes))
+		return
+	}
+	p.doc.Shapes = append(p.doc.Shapes, s)
+}
+
+// dashArray parses stroke-dasharray. Odd lists are repeated, and arrays
+// that are all zeros or have a negative value draw solid lines.
+func (p *parser) dashArray(value string) []float64 {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "none" {
+		return nil
+	}
+	var dash []float64
+	total := 0.0
+	for _, part := range strings.FieldsFunc(value, func(c rune) bool { return c == ',' || c == ' ' }) {
+		v, ok := parseLength(part, math.Hypot(p.viewport().W, p.viewport().H)/math.Sqrt2)
+		if !ok || v < 0 {
+			return nil
+		}
+		dash = append(dash, v)
+		total += v
+	}
+	if total <= 0 {
+		return nil
+	}
+	if len(dash)%2 == 1 {
+		dash = append(dash, dash...)
+	}
+	return dash
+}
+
+// paint resolves a fill or stroke value: none, a color or url(#gradient)
+// with an optional fallback color
+func (p *parser) paint(value string, st style, opacity float64) Paint {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "url(") {
+		fallback := ""
+		if end := strings.IndexByte(value, ')'); end >= 0 {
+			fallback = strings.TrimSpace(value[end+1:])
+		}
+		if ref := p.reference(value); ref != nil {
+			switch ref.name {
+			case "linearGradient", "radialGradient":
+				if g := p.gradient(ref); g != nil {
+					if len(g.Stops) == 1 {
+						// One stop paints its color
+						return Paint{Color: g.Stops[0].Color, Opacity: opacity * g.Stops[0].Opacity}
+					}
+					return Paint{Gradient: g, Opacity: opacity}
+				}
+				return Paint{}
+			case "pattern":
+				p.warn("patterns are not supported; their fallback color is used")
+			}
+		}
+		value = fallback
+	}
+
+	c, alpha, ok := parseColor(value, st["color"])
+	if !ok {
+		return Paint{}
+	}
+	return Paint{Color: c, Opacity: opacity * alpha}
+}
+
+// gradient reads a gradient element, with the attributes and stops it
+// inherits through href. It returns nil when the gradient has no stops,
+// which paints nothing.
+func (p *parser) gradient(n *node) *Gradient {
+	if g, ok := p.gradients[n]; ok {
+		return g
+	}
+	p.gradients[n] = nil // guards against href cycles
+
+	// The chain of templates, this gradient first
+	chain := []*node{n}
+	seen := map[*node]bool{n: true}
+	for ref := p.reference(chain[len(chain)-1].attrs["href"]); ref != nil && !seen[ref]; ref = p.reference(ref.attrs["href"]) {
+		if ref.name != "linearGradient" && ref.name != "radialGradient" {
+			break
+		}
+		chain = append(chain, ref)
+		seen[ref] = true
+	}
+	attr := func(name string) (string, bool) {
+		for _, c := range chain {
+			if v, ok := c.attrs[name]; ok {
+				return v, true
+			}
+		}
+		return "", false
+	}
+
+	g := &Gradient{Radial: n.name == "radialGradient", Transform: Identity, Spread: "pad"}
+	units, _ := attr("gradientUnits")
+	g.BoundingBox = strings.TrimSpace(units) != "userSpaceOnUse"
+	if v, ok := attr("gradientTransform"); ok {
+		tm, err := parseTransform(v)
+		if err != nil {
+			p.warn(err.Error())
+		}
+		g.Transform = tm
+	}
+	if v, ok := attr("spreadMethod"); ok && (v == "reflect" || v == "repeat") {
+		g.Spread = v
+	}
+
+	// Bounding box units are fractions, "50%" is 0.5
+	coord := func(name, def string, ref float64) float64 {
+		v, ok := attr(name)
+		if !ok {
+			v = def
+		}
+		if g.BoundingBox {
+			ref = 1
+		}
+		return length(v, ref, length(def, ref, 0))
+	}
+	vp := p.viewport()
+	diag := math.Hypot(vp.W, vp.H) / math.Sqrt2
+	if g.Radial {
+		g.CX = coord("cx", "50%", vp.W)
+		g.CY = coord("cy", "50%", vp.H)
+		g.R = coord("r", "50%", diag)
+		g.FX, g.FY = g.CX, g.CY
+		if _, ok := attr("fx"); ok {
+			g.FX = coord("fx", "50%", vp.W)
+		}
+		if _, ok := attr("fy"); ok {
+			g.FY = coord("fy", "50%", vp.H)
+		}
+		if _, ok := attr("fr"); ok {
+			p.warn("radial gradient focal radius (fr) is not supported")
+		}
+	} else {
+		g.X1 = coord("x1", "0%", vp.W)
+		g.Y1 = coord("y1", "0%", vp.H)
+		g.X2 = coord("x2", "100%", vp.W)
+		g.Y2 = coord("y2", "0%", vp.H)
+	}
+
+	// Stops come from the first gradient in the chain that has any
+	for _, c := range chain {
+		if g.Stops = p.stops(c); len(g.Stops) > 0 {
+			break
+		}
+	}
+	if len(g.Stops) == 0 {
+		return nil
+	}
+	p.gradients[n] = g
+	return g
+}
+
+// stops reads the <stop> children of a gradient. Offsets are clamped to 0-1
+// and made increasing.
+func (p *parser) stops(n *node) []Stop {
+	var stops []Stop
+	st := p.computeStyle(n, rootStyle)
+	last := 0.0
+	for _, child := range n.children {
+		if child.name != "stop" {
+			continue
+		}
+		cs := p.computeStyle(child, st)
+		offset := 0.0
+		if v := strings.TrimSpace(child.attrs["offset"]); v != "" {
+			offset = parseOpacity(v)
+		}
+		offset = math.Max(offset, last)
+		last = offset
+
+		color := cs["stop-color"]
+		if color == "" {
+			color = "black"
+		}
+		c, alpha, ok := parseColor(color, cs["color"])
+		if !ok {
+			c, alpha = Color{}, 0
+		}
+		stops = append(stops, Stop{Offset: offset, Color: c, Opacity: alpha * cs.opacity("stop-opacity")})
+	}
+	return stops
+}
+
+// clipPath resolves the clip-path property of an element to a clip in the
+// document's user space, added to the clips of its ancestors. bounds is the
+// element's bounding box in its user space, for objectBoundingBox units; it
+// is nil for groups.
+func (p *parser) clipPath(n *node, st style, m Matrix, clips []Clip, bounds *Rect) []Clip {
+	value := strings.TrimSpace(st["clip-path"])
+	if value == "" || value == "none" {
+		return clips
+	}
+	ref := p.reference(value)
+	if ref == nil || ref.name != "clipPath" {
+		if strings.HasPrefix(value, "url(") {
+			// A reference to nothing clips everything away, but a bad
+			// reference shouldn't blank the artwork: ignore it
+			p.warn(fmt.Sprintf("clip-path %s not found", value))
+		} else {
+			p.warn("clip-path shapes are not supported")
+		}
+		return clips
+	}
+
+	cm := m
+	if t, ok := ref.attrs["transform"]; ok {
+		tm, _ := parseTransform(t)
+		cm = cm.Mul(tm)
+	}
+	if strings.TrimSpace(ref.attrs["clipPathUnits"]) == "objectBoundingBox" {
+		if bounds == nil {
+			p.warn("objectBoundingBox clip paths on groups are not supported")
+			return clips
+		}
+		cm = cm.Mul(Matrix{A: bounds.W, D: bounds.H, E: bounds.X, F: bounds.Y})
+	}
+
+	// The clip is the union of the clip path's shapes, in one path
+	clipStyle := p.computeStyle(ref, rootStyle)
+	var path Path
+	evenOdd := true
+	for _, child := range ref.children {
+		target := child
+		childM := cm
+		if child.name == "use" {
+			target = p.reference(child.attrs["href"])
+			if target == nil {
+				continue
+			}
+			childM = childM.Mul(Translate(p.lengthX(child.attrs["x"], 0), p.lengthY(child.attrs["y"], 0)))
+		}
+		cs := p.computeStyle(target, clipStyle)
+		if strings.TrimSpace(cs["display"]) == "none" {
+			continue
+		}
+		if t, ok := target.attrs["transform"]; ok {
+			tm, _ := parseTransform(t)
+			childM = childM.Mul(tm)
+		}
+		if target.name == "text" {
+			p.warn("text clip paths are not supported")
+			continue
+		}
+		shape := p.shapePath(target)
+		if len(shape) == 0 {
+			continue
+		}
+		path = append(path, shape.Transform(childM)...)
+		if strings.TrimSpace(cs["clip-rule"]) != "evenodd" {
+			evenOdd = false
+		}
+	}
+	// An empty clip path hides the element: clip to an empty path
+	return append(append([]Clip(nil), clips...), Clip{Path: path, EvenOdd: evenOdd && len(path) > 0})
+}
+
+// drawnBounds returns the bounds of everything drawn, in the document's user
+// space, for documents without a viewBox or size
+func (p *parser) drawnBounds() Rect {
+	var all Path
+	for _, s := range p.doc.Shapes {
+		all = append(all, s.Path.Transform(s.Transform)...)
+	}
+	b := all.Bounds()
+	if b.W <= 0 || b.H <= 0 {
+		return Rect{0, 0, 300, 150}
+	}
+	return b
+}
+
+// parseViewBox parses a viewBox attribute
+func parseViewBox(value string) (Rect, bool) {
+	nums := parseNumbers(value)
+	if len(nums) != 4 || nums[2] <= 0 || nums[3] <= 0 {
+		return Rect{}, false
+	}
+	return Rect{nums[0], nums[1], nums[2], nums[3]}, true
+}
+
+// parseAspectRatio parses preserveAspectRatio into its alignment and
+// whether it slices
+func parseAspectRatio(value string) (string, bool) {
+	fields := strings.Fields(value)
+	if len(fields) > 0 && fields[0] == "defer" {
+		fields = fields[1:]
+	}
+	align := "xMidYMid"
+	if len(fields) > 0 {
+		switch fields[0] {
+		case "none", "xMinYMin", "xMidYMin", "xMaxYMin", "xMinYMid", "xMidYMid",
+			"xMaxYMid", "xMinYMax", "xMidYMax", "xMaxYMax":
+			align = fields[0]
+		}
+	}
+	return align, len(fields) > 1 && fields[1] == "slice"
+}
+
+// ViewBoxTransform returns the transform that maps a viewBox into a viewport
+// with the given preserveAspectRatio alignment
+func ViewBoxTransform(viewBox Rect, align string, slice bool, viewport Rect) Matrix {
+	sx, sy := viewport.W/viewBox.W, viewport.H/viewBox.H
+	if align == "none" {
+		return Matrix{A: sx, D: sy, E: viewport.X - viewBox.X*sx, F: viewport.Y - viewBox.Y*sy}
+	}
+
+	s := math.Min(sx, sy)
+	if slice {
+		s = math.Max(sx, sy)
+	}
+	tx := viewport.X - viewBox.X*s
+	ty := viewport.Y - viewBox.Y*s
+	extraX, extraY := viewport.W-viewBox.W*s, viewport.H-viewBox.H*s
+	switch {
+	case strings.HasPrefix(align, "xMid"):
+		tx += extraX / 2
+	case strings.HasPrefix(align, "xMax"):
+		tx += extraX
+	}
+	switch {
+	case strings.HasSuffix(align, "YMid"):
+		ty += extraY / 2
+	case strings.HasSuffix(align, "YMax"):
+		ty += extraY
+	}
+	return Matrix{A: s, D: s, E: tx, F: ty}
+}
+
+// Transform returns the transform from the document's user space to a
+// viewport, honoring preserveAspectRatio
+func (d *Document) Transform(viewport Rect) Matrix {
+	return ViewBoxTransform(d.ViewBox, d.Align, d.Slice, viewport)
+}
@@ -0,0 +1,269 @@
+package svg
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fanOut builds a document where every level is a group of ten <use>
+// elements referencing the level below, so it expands to 10^levels shapes
+func fanOut(levels int, shape string) string {
+	var sb strings.Builder
+	sb.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><defs>`)
+	sb.WriteString(`<g id="l0">` + shape + `</g>`)
+	for i := 1; i <= levels; i++ {
+		fmt.Fprintf(&sb, `<g id="l%d">`, i)
+		for j := 0; j < 10; j++ {
+			fmt.Fprintf(&sb, `<use href="#l%d"/>`, i-1)
+		}
+		sb.WriteString(`</g>`)
+	}
+	fmt.Fprintf(&sb, `</defs><use href="#l%d"/></svg>`, levels)
+	return sb.String()
+}
+
+func TestParseUseFanOut(t *testing.T) {
+	tests := []struct {
+		name  string
+		shape string
+	}{
+		{"filled shapes", `<rect width="1" height="1"/>`},
+		{"shapes that draw nothing", `<rect width="1" height="1" fill="none"/>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			doc, err := Parse([]byte(fanOut(maxUseDepth-1, tt.shape)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Errorf("parse took %v", elapsed)
+			}
+			if len(doc.Shapes) > maxShapes {
+				t.Errorf("got %d shapes, want at most %d", len(doc.Shapes), maxShapes)
+			}
+			found := false
+			for _, w := range doc.Warnings {
+				found = found || strings.Contains(w, "the rest of the document is left out")
+			}
+			if !found {
+				t.Errorf("no warning about the limit in %q", doc.Warnings)
+			}
+		})
+	}
+}
+
+func TestParseShapeLimit(t *testing.T) {
+	src := `<svg xmlns="http://www.w3.org/2000/svg">` +
+		strings.Repeat(`<rect width="1" height="1"/>`, maxShapes+5) + `</svg>`
+	doc, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Shapes) != maxShapes {
+		t.Errorf("got %d shapes, want %d", len(doc.Shapes), maxShapes)
+	}
+	if len(doc.Warnings) != 1 || !strings.Contains(doc.Warnings[0], "shapes") {
+		t.Errorf("warnings %q", doc.Warnings)
+	}
+}
+
+func TestParseShapes(t *testing.T) {
+	tests := []struct {
+		name     string
+		svg      string
+		shapes   int
+		width    float64 // mm
+		warnings int
+	}{
+		{
+			name:   "basic shapes",
+			svg:    `<svg xmlns="http://www.w3.org/2000/svg" width="96" height="48"><rect width="10" height="10"/><circle cx="5" cy="5" r="2"/><path d="M0 0h4v4z"/></svg>`,
+			shapes: 3,
+			width:  25.4,
+		},
+		{
+			name:   "use of a symbol",
+			svg:    `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><symbol id="s" viewBox="0 0 1 1"><rect width="1" height="1"/></symbol><use href="#s" width="5" height="5"/><use href="#s" x="5" width="5" height="5"/></svg>`,
+			shapes: 2,
+		},
+		{
+			name:   "display none and no paint",
+			svg:    `<svg xmlns="http://www.w3.org/2000/svg"><g display="none"><rect width="1" height="1"/></g><rect width="1" height="1" fill="none"/></svg>`,
+			shapes: 0,
+			width:  300 * 25.4 / 96,
+		},
+		{
+			name:     "unsupported elements",
+			svg:      `<svg xmlns="http://www.w3.org/2000/svg"><text>Hi</text><image href="x.png"/><rect width="1" height="1" filter="url(#f)"/></svg>`,
+			shapes:   1,
+			warnings: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse([]byte(tt.svg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(doc.Shapes) != tt.shapes {
+				t.Errorf("got %d shapes, want %d", len(doc.Shapes), tt.shapes)
+			}
+			if tt.width != 0 && !near(doc.Width, tt.width) {
+				t.Errorf("width %.3f mm, want %.3f", doc.Width, tt.width)
+			}
+			if len(doc.Warnings) != tt.warnings {
+				t.Errorf("warnings %q, want %d", doc.Warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{"", "<html></html>", "not xml"} {
+		if _, err := Parse([]byte(src)); err == nil {
+			t.Errorf("Parse(%q) succeeded", src)
+		}
+	}
+}
+
+func TestParsePathData(t *testing.T) {
+	tests := []struct {
+		d      string
+		ops    []Op
+		end    Point
+		hasErr bool
+	}{
+		{d: "M1 2L3 4", ops: []Op{MoveTo, LineTo}, end: Point{3, 4}},
+		{d: "m1 2l3 4z", ops: []Op{MoveTo, LineTo, Close}, end: Point{1, 2}},
+		{d: "M0,0H5V5h-5", ops: []Op{MoveTo, LineTo, LineTo, LineTo}, end: Point{0, 5}},
+		{d: "M0 0Q5 5 10 0", ops: []Op{MoveTo, CubeTo}, end: Point{10, 0}},
+		{d: "M0 0C1 1 2 1 3 0S5-1 6 0", ops: []Op{MoveTo, CubeTo, CubeTo}, end: Point{6, 0}},
+		{d: "M.5.5-1-1", ops: []Op{MoveTo, LineTo}, end: Point{-1, -1}},
+		{d: "M0 0 L", ops: []Op{MoveTo}, end: Point{0, 0}, hasErr: true},
+	}
+	for _, tt := range tests {
+		path, err := parsePathData(tt.d)
+		if (err != nil) != tt.hasErr {
+			t.Errorf("%q: error %v", tt.d, err)
+		}
+		var ops []Op
+		for _, seg := range path {
+			ops = append(ops, seg.Op)
+		}
+		if fmt.Sprint(ops) != fmt.Sprint(tt.ops) {
+			t.Errorf("%q: ops %v, want %v", tt.d, ops, tt.ops)
+			continue
+		}
+		if end := lastPoint(path); !near(end.X, tt.end.X) || !near(end.Y, tt.end.Y) {
+			t.Errorf("%q: ends at %v, want %v", tt.d, end, tt.end)
+		}
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Color
+		alpha float64
+		ok    bool
+	}{
+		{"red", Color{255, 0, 0}, 1, true},
+		{"#0f0", Color{0, 255, 0}, 1, true},
+		{"#0000ff80", Color{0, 0, 255}, 128.0 / 255, true},
+		{"rgb(10, 20, 30)", Color{10, 20, 30}, 1, true},
+		{"rgba(100%,0%,0%,0.5)", Color{255, 0, 0}, 0.5, true},
+		{"currentColor", Color{0, 0, 128}, 1, true},
+		{"none", Color{}, 0, false},
+		{"#12345", Color{}, 0, false},
+	}
+	for _, tt := range tests {
+		got, alpha, ok := parseColor(tt.value, "navy")
+		if got != tt.want || !near(alpha, tt.alpha) || ok != tt.ok {
+			t.Errorf("parseColor(%q) = %v, %v, %v; want %v, %v, %v", tt.value, got, alpha, ok, tt.want, tt.alpha, tt.ok)
+		}
+	}
+}
+
+func TestViewBoxTransform(t *testing.T) {
+	viewBox := Rect{0, 0, 100, 50}
+	viewport := Rect{10, 10, 100, 100}
+	tests := []struct {
+		align string
+		slice bool
+		want  Matrix
+	}{
+		{"xMidYMid", false, Matrix{A: 1, D: 1, E: 10, F: 35}},
+		{"xMinYMin", false, Matrix{A: 1, D: 1, E: 10, F: 10}},
+		{"xMidYMid", true, Matrix{A: 2, D: 2, E: -40, F: 10}},
+		{"none", false, Matrix{A: 1, D: 2, E: 10, F: 10}},
+	}
+	for _, tt := range tests {
+		if got := ViewBoxTransform(viewBox, tt.align, tt.slice, viewport); got != tt.want {
+			t.Errorf("%s slice=%v: got %+v, want %+v", tt.align, tt.slice, got, tt.want)
+		}
+	}
+}
+
+func lastPoint(p Path) Point {
+	start, cur := Point{}, Point{}
+	for _, seg := range p {
+		switch seg.Op {
+		case MoveTo:
+			start, cur = seg.P[0], seg.P[0]
+		case LineTo:
+			cur = seg.P[0]
+		case CubeTo:
+			cur = seg.P[2]
+		case Close:
+			cur = start
+		}
+	}
+	return cur
+}
+
+func near(a, b float64) bool {
+	d := a - b
+	return d < 1e-6 && d > -1e-6
+}
+
+func TestRasterizeBox(t *testing.T) {
+	doc, err := Parse([]byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1000 1000">
+		<linearGradient id="g" spreadMethod="reflect" x2="0.1"><stop stop-color="red"/><stop offset="1" stop-color="blue"/></linearGradient>
+		<rect width="1000" height="1000" fill="url(#g)"/></svg>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Shapes) != 1 {
+		t.Fatalf("got %d shapes", len(doc.Shapes))
+	}
+	shape := doc.Shapes[0]
+
+	// One pixel per mm
+	tests := []struct {
+		name  string
+		box   Rect
+		want  Rect
+		drawn bool
+	}{
+		{"inside", Rect{10, 10, 20, 30}, Rect{10, 10, 20, 30}, true},
+		{"over the edge", Rect{990, -10, 50, 20}, Rect{990, 0, 10, 10}, true},
+		{"outside", Rect{2000, 0, 10, 10}, Rect{}, false},
+	}
+	for _, tt := range tests {
+		img, area := Rasterize(shape, Scaling(1, 1), tt.box, 25.4)
+		if (img != nil) != tt.drawn {
+			t.Errorf("%s: drawn = %v, want %v", tt.name, img != nil, tt.drawn)
+			continue
+		}
+		if area != tt.want {
+			t.Errorf("%s: area = %+v, want %+v", tt.name, area, tt.want)
+		}
+		if img != nil && (img.Bounds().Dx() != int(tt.want.W) || img.Bounds().Dy() != int(tt.want.H)) {
+			t.Errorf("%s: image is %v", tt.name, img.Bounds())
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package token
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Signed badge tokens: a compact, tamper-evident QR payload that scanners can
+// check with /api/badge/verify (or offline, with the public key).
+//
+// A token is "<keyId>.<payload>.<signature>", both parts base64url without
+// padding. The payload is the JSON array [identifier, eventId,
+// badgeCategoryId, issuedAt] with issuedAt in Unix seconds, and the signature
+// covers "<keyId>.<payload>" with HMAC-SHA256 or Ed25519.
+
+// Claims are what a badge token carries
+type Claims struct {
+	Identifier      string    `json:"identifier"`
+	EventID         string    `json:"eventId,omitempty"`
+	BadgeCategoryID string    `json:"badgeCategoryId,omitempty"`
+	IssuedAt        time.Time `json:"issuedAt"`
+	KeyID           string    `json:"keyId,omitempty"` // key that signed the token, set by Verify
+}
+
+// Errors returned by Sign and Verify
+var (
+	ErrNoKeys     = errors.New("no badge token keys are configured")
+	ErrMalformed  = errors.New("malformed badge token")
+	ErrUnknownKey = errors.New("unknown badge token key")
+	ErrSignature  = errors.New("invalid badge token signature")
+	ErrExpired    = errors.New("badge token has expired")
+)
+
+// key is a configured signing or verification key
+type key struct {
+	id      string
+	secret  []byte             // HMAC
+	private ed25519.PrivateKey // Ed25519 signing key
+	public  ed25519.PublicKey  // Ed25519 verification key
+}
+
+func (k *key) canSign() bool {
+	return k.secret != nil || k.private != nil
+}
+
+func (k *key) sign(data []byte) []byte {
+	if k.secret != nil {
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(data)
+		return mac.Sum(nil)
+	}
+	return ed25519.Sign(k.private, data)
+}
+
+func (k *key) verify(data, sig []byte) bool {
+	if k.secret != nil {
+		return hmac.Equal(k.sign(data), sig)
+	}
+	return ed25519.Verify(k.public, data, sig)
+}
+
+var (
+	mu     sync.RWMutex
+	keys   map[string]*key
+	signer *key
+	maxAge time.Duration
+)
+
+// Init configures the keys from a comma separated list of "id:algorithm:key"
+// entries. Algorithms are:
+//
+//	hmac            key is the shared secret, as text
+//	ed25519         key is the base64 32-byte seed or 64-byte private key
+//	ed25519-public  key is the base64 32-byte public key (verification only)
+//
+// The first key that can sign signs new tokens; every key verifies, so old
+// keys can stay listed while tokens signed with them are in circulation.
+// Tokens older than ttl fail verification; 0 means they don't expire.
+func Init(spec string, ttl time.Duration) error {
+	parsed := make(map[string]*key)
+	var first *key
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		k, err := parseKey(entry)
+		if err != nil {
+			return err
+		}
+		if _, dup := parsed[k.id]; dup {
+			return fmt.Errorf("duplicate badge token key id %q", k.id)
+		}
+		parsed[k.id] = k
+		if first == nil && k.canSign() {
+			first = k
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	keys, signer, maxAge = parsed, first, ttl
+	return nil
+}
+
+// parseKey parses one "id:algorithm:key" entry
+func parseKey(entry string) (*key, error) {
+	parts := strings.SplitN(entry, ":", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("badge token key %q: expected id:algorithm:key", entry)
+	}
+	id, alg, value := parts[0], strings.ToLower(parts[1]), parts[2]
+	if strings.Contains(id, ".") {
+		return nil, fmt.Errorf("badge token key id %q must not contain '.'", id)
+	}
+
+	if alg == "hmac" || alg == "hs256" {
+		return &key{id: id, secret: []byte(value)}, nil
+	}
+
+	raw, err := decodeBase64(value)
+	if err != nil {
+		return nil, fmt.Errorf("badge token key %q: %w", id, err)
+	}
+	switch {
+	case alg == "ed25519" && len(raw) == ed25519.SeedSize:
+		private := ed25519.NewKeyFromSeed(raw)
+		return &key{id: id, private: private, public: private.Public().(ed25519.PublicKey)}, nil
+	case alg == "ed25519" && len(raw) == ed25519.PrivateKeySize:
+		private := ed25519.PrivateKey(raw)
+		return &key{id: id, private: private, public: private.Public().(ed25519.PublicKey)}, nil
+	case alg == "ed25519-public" && len(raw) == ed25519.PublicKeySize:
+		return &key{id: id, public: ed25519.PublicKey(raw)}, nil
+	case alg == "ed25519" || alg == "ed25519-public":
+		return nil, fmt.Errorf("badge token key %q: wrong length %d for %s", id, len(raw), alg)
+	}
+	return nil, fmt.Errorf("badge token key %q: unknown algorithm %q", id, alg)
+}
+
+// decodeBase64 accepts standard and URL-safe base64, with or without padding
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.TrimSpace(s), "=")
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+// CanSign reports whether a key that can sign new tokens is configured
+func CanSign() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return signer != nil
+}
+
+// Sign returns a token for the claims, issued now unless IssuedAt is set
+func Sign(claims Claims) (string, error) {
+	mu.RLock()
+	k := signer
+	mu.RUnlock()
+	if k == nil {
+		return "", ErrNoKeys
+	}
+
+	issued := claims.IssuedAt
+	if issued.IsZero() {
+		issued = time.Now()
+	}
+	payload, err := json.Marshal([]interface{}{claims.Identifier, claims.EventID, claims.BadgeCategoryID, issued.Unix()})
+	if err != nil {
+		return "", err
+	}
+
+	signed := k.id + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(k.sign([]byte(signed))), nil
+}
+
+// Verify checks a token's signature and age and returns its claims
+func Verify(tok string) (Claims, error) {
+	parts := strings.Split(strings.TrimSpace(tok), ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrMalformed
+	}
+
+	mu.RLock()
+	k, ok := keys[parts[0]]
+	ttl := maxAge
+	noKeys := len(keys) == 0
+	mu.RUnlock()
+	if noKeys {
+		return Claims{}, ErrNoKeys
+	}
+	if !ok {
+		return Claims{}, ErrUnknownKey
+	}
+
+	sig, err := base64.RawURLEncoding.Strict().DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+	if !k.verify([]byte(parts[0]+"."+parts[1]), sig) {
+		return Claims{}, ErrSignature
+	}
+
+	claims, err := decodePayload(parts[1])
+	if err != nil {
+		return Claims{}, err
+	}
+	claims.KeyID = k.id
+
+	if ttl > 0 && time.Since(claims.IssuedAt) > ttl {
+		return claims, ErrExpired
+	}
+	return claims, nil
+}
+
+// decodePayload decodes the base64url JSON array of a token
+func decodePayload(part string) (Claims, error) {
+	payload, err := base64.RawURLEncoding.Strict().DecodeString(part)
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+
+	var fields []interface{}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil || len(fields) != 4 {
+		return Claims{}, ErrMalformed
+	}
+
+	var claims Claims
+	var ok bool
+	if claims.Identifier, ok = fields[0].(string); !ok {
+		return Claims{}, ErrMalformed
+	}
+	if claims.EventID, ok = fields[1].(string); !ok {
+		return Claims{}, ErrMalformed
+	}
+	if claims.BadgeCategoryID, ok = fields[2].(string); !ok {
+		return Claims{}, ErrMalformed
+	}
+	issued, ok := fields[3].(json.Number)
+	if !ok {
+		return Claims{}, ErrMalformed
+	}
+	secs, err := issued.Int64()
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+	claims.IssuedAt = time.Unix(secs, 0).UTC()
+	return claims, nil
+}
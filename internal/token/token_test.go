@@ -0,0 +1,129 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	private := ed25519.NewKeyFromSeed(seed)
+	public := base64.StdEncoding.EncodeToString(private.Public().(ed25519.PublicKey))
+
+	tests := []struct {
+		name string
+		spec string
+		key  string
+	}{
+		{"hmac", "k1:hmac:secret", "k1"},
+		{"ed25519 seed", "e1:ed25519:" + base64.RawURLEncoding.EncodeToString(seed), "e1"},
+		{"ed25519 private key", "e2:ed25519:" + base64.StdEncoding.EncodeToString(private), "e2"},
+		{"first signing key signs", "p1:ed25519-public:" + public + ", k2:hmac:other", "k2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Init(tt.spec, 0); err != nil {
+				t.Fatal(err)
+			}
+			issued := time.Unix(1741356309, 0).UTC()
+			tok, err := Sign(Claims{Identifier: "A-1", EventID: "7", BadgeCategoryID: "vip", IssuedAt: issued})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(tok, tt.key+".") {
+				t.Errorf("token %q not signed with %s", tok, tt.key)
+			}
+			claims, err := Verify(tok)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Claims{Identifier: "A-1", EventID: "7", BadgeCategoryID: "vip", IssuedAt: issued, KeyID: tt.key}
+			if claims != want {
+				t.Errorf("claims %+v, want %+v", claims, want)
+			}
+		})
+	}
+}
+
+func TestVerifyPublicKeyOnly(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	private := ed25519.NewKeyFromSeed(seed)
+	if err := Init("e1:ed25519:"+base64.StdEncoding.EncodeToString(seed), 0); err != nil {
+		t.Fatal(err)
+	}
+	tok, err := Sign(Claims{Identifier: "A-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Scanners only hold the public key
+	public := base64.StdEncoding.EncodeToString(private.Public().(ed25519.PublicKey))
+	if err := Init("e1:ed25519-public:"+public, 0); err != nil {
+		t.Fatal(err)
+	}
+	if CanSign() {
+		t.Error("a public key can sign")
+	}
+	if _, err := Sign(Claims{Identifier: "A-1"}); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("Sign error %v, want %v", err, ErrNoKeys)
+	}
+	if _, err := Verify(tok); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestVerifyErrors(t *testing.T) {
+	if err := Init("k1:hmac:secret", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	fresh, _ := Sign(Claims{Identifier: "A-1"})
+	old, _ := Sign(Claims{Identifier: "A-1", IssuedAt: time.Now().Add(-2 * time.Hour)})
+	parts := strings.Split(fresh, ".")
+
+	tests := []struct {
+		name string
+		tok  string
+		err  error
+	}{
+		{"not a token", "hello", ErrMalformed},
+		{"unknown key", "k9." + parts[1] + "." + parts[2], ErrUnknownKey},
+		{"tampered payload", parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`["B-2","","",0]`)) + "." + parts[2], ErrSignature},
+		{"bad signature encoding", parts[0] + "." + parts[1] + ".!!", ErrMalformed},
+		{"expired", old, ErrExpired},
+	}
+	for _, tt := range tests {
+		if _, err := Verify(tt.tok); !errors.Is(err, tt.err) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	if err := Init("", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(fresh); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("no keys: error %v, want %v", err, ErrNoKeys)
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	for _, spec := range []string{
+		"k1",
+		"k1:hmac:",
+		"k.1:hmac:secret",
+		"k1:hmac:a,k1:hmac:b",
+		"k1:rsa:abc",
+		"k1:ed25519:AAAA",
+		"k1:ed25519:not base64!",
+	} {
+		if err := Init(spec, 0); err == nil {
+			t.Errorf("Init(%q) succeeded", spec)
+		}
+	}
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"badge-service/internal/cache"
+	"badge-service/internal/fonts"
 	"badge-service/internal/handlers"
+	"badge-service/internal/token"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -30,6 +33,36 @@ func main() {
 	// Initialize cache
 	cache.Init(cacheDir)
 	
+	// Get fonts directory from environment (system font directories are always scanned too)
+	fontsDir := os.Getenv("FONTS_DIR")
+	if fontsDir == "" {
+		fontsDir = "fonts"
+	}
+	
+	// Optional comma separated list of fallback families for missing glyphs
+	if fallbacks := os.Getenv("FONT_FALLBACKS"); fallbacks != "" {
+		fonts.FallbackFamilies = strings.Split(fallbacks, ",")
+	}
+	
+	// Index available fonts
+	fonts.Init(fontsDir)
+	
+	// Keys for signed QR badge tokens, e.g. "k1:hmac:secret" (see token.Init)
+	if keys := os.Getenv("BADGE_TOKEN_KEYS"); keys != "" {
+		var ttl time.Duration
+		if maxAge := os.Getenv("BADGE_TOKEN_MAX_AGE"); maxAge != "" {
+			var err error
+			if ttl, err = time.ParseDuration(maxAge); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid BADGE_TOKEN_MAX_AGE: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if err := token.Init(keys, ttl); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid BADGE_TOKEN_KEYS: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	
 	// Create Fiber app with optimized config
 	app := fiber.New(fiber.Config{
 		Prefork:       false, // Set to true for multi-process (Railway doesn't need this)
@@ -65,6 +98,7 @@ func main() {
 	// Start server
 	fmt.Fprintf(os.Stderr, "Badge Service starting on port %s\n", port)
 	fmt.Fprintf(os.Stderr, "Cache directory: %s\n", cacheDir)
+	fmt.Fprintf(os.Stderr, "Fonts: %d faces indexed (fonts directory: %s)\n", fonts.Count(), fontsDir)
 	
 	if err := app.Listen(":" + port); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to start server: %v\n", err)
@@ -91,6 +125,10 @@ func setupRoutes(app *fiber.App) {
 	api.Post("/badge/generate", handlers.GenerateBadge)
 	api.Post("/badge/batch", handlers.GenerateBadgeBatch)
 	
+	// Signed QR token verification for scanners
+	api.Get("/badge/verify", handlers.VerifyBadge)
+	api.Post("/badge/verify", handlers.VerifyBadge)
+	
 	// Template management
 	api.Post("/template/preload", handlers.PreloadTemplate)
 	